@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/hashicorp/go-hclog"
-	"gopkg.in/yaml.v2"
 	"log"
 	"os"
 	"strings"
@@ -21,9 +20,29 @@ var (
 	defaultProfileDir             = "."
 	defaultVoteBufferAfterPenalty = uint64(3600 * 24) // The buffering time window in blocks to continue vote after the last penalty event.
 
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
 	ConfidenceStrategyLinear  = 0
 	ConfidenceStrategyFixed   = 1
-	defaultConfidenceStrategy = ConfidenceStrategyLinear // 0: linear, 1: fixed.
+	ConfidenceStrategyModel   = 2
+	defaultConfidenceStrategy = ConfidenceStrategyLinear // 0: linear, 1: fixed, 2: model.
+
+	PenaltyPolicyVote   = "vote"   // an outlier penalty postpones the whole vote for the vote buffer.
+	PenaltyPolicySymbol = "symbol" // an outlier penalty suppresses only the penalized symbol for the vote buffer.
+
+	SuppressionInvalid       = "invalid"       // the suppressed symbol is reported with the invalid price.
+	SuppressionLowConfidence = "lowConfidence" // the suppressed symbol is reported with the lowest confidence.
+
+	DecayCurveStep        = "step"        // full confidence under a period, half under 60 periods, the lowest after.
+	DecayCurveLinear      = "linear"      // the confidence decays linearly to 0 in a period.
+	DecayCurveExponential = "exponential" // the confidence halves in each period.
+
+	PricingModeVWAP = "vwap" // the AMM price is the volume weighted average of the recent swaps.
+	PricingModeTWAP = "twap" // the AMM price is the time weighted average of the cumulative price of the pair.
+
+	RESTModeSymbol = "symbol" // the REST JSON plugin requests the price of each symbol by itself.
+	RESTModeBatch  = "batch"  // the REST JSON plugin requests the prices of all the symbols at once.
 )
 
 // Version number of the oracle server in uint8. It is required
@@ -49,6 +68,57 @@ var DefaultConfig = ServerConfig{
 	ConfidenceStrategy: defaultConfidenceStrategy,
 	PluginConfigs:      nil,
 	MetricConfigs:      DefaultMetricConfig,
+	Logging:            DefaultLoggingConfig,
+	Journal:            DefaultJournalConfig,
+	Penalty:            DefaultPenaltyConfig,
+	ConfidenceModel:    DefaultConfidenceModelConfig,
+	Fallback:           DefaultFallbackConfig,
+	Staleness:          DefaultStalenessConfig,
+}
+
+// DefaultStalenessConfig is the default staleness limits of the samples, it takes no limit.
+var DefaultStalenessConfig = StalenessConfig{}
+
+// DefaultDecayConfig is the default confidence decay of the historic fallback prices: the full confidence under 1
+// minute, the half under 1 hour, and the lowest confidence after that.
+var DefaultDecayConfig = DecayConfig{
+	Curve:  DecayCurveStep,
+	Period: 60,
+}
+
+// DefaultFallbackConfig is the default config of the historic fallback prices, it takes the in-memory rounds only.
+var DefaultFallbackConfig = FallbackConfig{
+	Decay: DefaultDecayConfig,
+}
+
+// DefaultConfidenceModelConfig is the default config of the confidence model, it takes full confidence with 3 fresh and
+// consistent sources.
+var DefaultConfidenceModelConfig = ConfidenceModelConfig{
+	ExpectedSources: 3,
+	DispersionScale: 0.5,
+	MaxSampleAge:    60,
+}
+
+// DefaultPenaltyConfig is the default config of the outlier penalty handling, it postpones the whole vote.
+var DefaultPenaltyConfig = PenaltyConfig{
+	Policy:         PenaltyPolicyVote,
+	Suppression:    SuppressionLowConfidence,
+	RecoveryRounds: 10,
+}
+
+// DefaultJournalConfig is the default config of the round journal, the rotated journals are kept for 90 days.
+var DefaultJournalConfig = JournalConfig{
+	MaxSizeMB:     100,
+	RetentionDays: 90,
+}
+
+// DefaultLoggingConfig is the default config of logging, the logs are written to stdout in text.
+var DefaultLoggingConfig = LoggingConfig{
+	Format:        LogFormatText,
+	MaxSizeMB:     100,
+	RotationHours: 24,
+	MaxBackups:    7,
+	MaxAgeDays:    7,
 }
 
 // DefaultMetricConfig is the default config for metrics used in oracle-server.
@@ -89,104 +159,291 @@ type MetricConfig struct {
 	InfluxDBOrganization string `json:"influxDBOrganization" yaml:"influxDBOrganization"`
 }
 
+// LoggingConfig contains the configuration of the logging of oracle-server.
+type LoggingConfig struct {
+	Format string    `json:"format" yaml:"format"` // The log format: text or json.
+	Levels LogLevels `json:"levels" yaml:"levels"` // The per-component log levels, 0 takes the logLevel.
+
+	// The log file, the logs are written to stdout if it is empty.
+	File          string `json:"file" yaml:"file"`
+	MaxSizeMB     int    `json:"maxSizeMB" yaml:"maxSizeMB"`         // The size in MB to rotate the log file, 0 disables it.
+	RotationHours int    `json:"rotationHours" yaml:"rotationHours"` // The interval in hours to rotate the log file, 0 disables it.
+	MaxBackups    int    `json:"maxBackups" yaml:"maxBackups"`       // The number of rotated log files to be kept, 0 keeps all.
+	MaxAgeDays    int    `json:"maxAgeDays" yaml:"maxAgeDays"`       // The days to keep the rotated log files, 0 keeps them forever.
+}
+
+// LogLevels are the per-component log levels: 0: logLevel, 1: Trace, 2: Debug, 3: Info, 4: Warn, 5: Error
+type LogLevels struct {
+	Server  int            `json:"server" yaml:"server"`
+	Monitor int            `json:"monitor" yaml:"monitor"`
+	Plugins map[string]int `json:"plugins" yaml:"plugins"` // The log levels of plugins by plugin name.
+}
+
+// JournalConfig contains the configuration of the round journal which is saved in the profile directory.
+type JournalConfig struct {
+	MaxSizeMB     int `json:"maxSizeMB" yaml:"maxSizeMB"`         // The size in MB to rotate the journal, 0 disables it.
+	RetentionDays int `json:"retentionDays" yaml:"retentionDays"` // The days to keep the rotated journals, 0 keeps them forever.
+}
+
+// PenaltyConfig contains the configuration of how the oracle server reacts to the outlier penalties.
+type PenaltyConfig struct {
+	Policy      string `json:"policy" yaml:"policy"`           // The penalty policy: vote or symbol.
+	Suppression string `json:"suppression" yaml:"suppression"` // The report of a suppressed symbol: invalid or lowConfidence.
+	// The consecutive healthy rounds after the vote buffer for a suppressed symbol to regain its full confidence.
+	RecoveryRounds int `json:"recoveryRounds" yaml:"recoveryRounds"`
+}
+
+// ConfidenceModelConfig contains the configuration of the confidence model of the confidence strategy 2.
+type ConfidenceModelConfig struct {
+	ExpectedSources int     `json:"expectedSources" yaml:"expectedSources"` // The reliable sources for full coverage.
+	DispersionScale float64 `json:"dispersionScale" yaml:"dispersionScale"` // The dispersion in percent that halves the confidence.
+	MaxSampleAge    int     `json:"maxSampleAge" yaml:"maxSampleAge"`       // The sample age in seconds that zeros its freshness.
+	// The volume of a symbol for full confidence by symbol, in the volume unit reported by the plugins. The symbols
+	// without a target are not weighted by volume.
+	VolumeTargets map[string]float64 `json:"volumeTargets" yaml:"volumeTargets"`
+	// The reliability of the plugins in [0, 1] by plugin name, the plugins without a reliability take 1.
+	Reliability map[string]float64 `json:"reliability" yaml:"reliability"`
+}
+
+// DecayConfig contains the confidence decay curve of a historic fallback price by its age.
+type DecayConfig struct {
+	Curve  string `json:"curve" yaml:"curve"`   // The decay curve: step, linear or exponential.
+	Period int    `json:"period" yaml:"period"` // The time scale of the curve in seconds.
+	MaxAge int    `json:"maxAge" yaml:"maxAge"` // The age in seconds after which nothing is reported, 0 disables it.
+}
+
+// FallbackConfig contains the configuration of the historic fallback prices of the symbols without samples.
+type FallbackConfig struct {
+	Decay   DecayConfig            `json:"decay" yaml:"decay"`     // The decay curve of all the symbols.
+	Symbols map[string]DecayConfig `json:"symbols" yaml:"symbols"` // The decay curves by symbol, they override the decay.
+	OnChain bool                   `json:"onChain" yaml:"onChain"` // Fall back to the on-chain latest round data.
+}
+
+// SymbolDecay returns the decay curve of a symbol, the omitted curve takes the default one.
+func (f *FallbackConfig) SymbolDecay(symbol string) DecayConfig {
+	if d, ok := f.Symbols[symbol]; ok {
+		return d
+	}
+	if f.Decay.Curve == "" {
+		return DefaultDecayConfig
+	}
+	return f.Decay
+}
+
+// StalenessConfig contains the max ages of the samples to the round timestamp, the stale samples are excluded from the
+// aggregation. The strictest limit of the symbol and of the plugin is taken.
+type StalenessConfig struct {
+	MaxAge  int            `json:"maxAge" yaml:"maxAge"`   // The max age in seconds of all the samples, 0 disables it.
+	Symbols map[string]int `json:"symbols" yaml:"symbols"` // The max ages in seconds by symbol.
+}
+
 // ServerConfig is the schema of oracle-server's config.
 type ServerConfig struct {
-	LoggingLevel       int            `json:"logLevel" yaml:"logLevel"`
-	GasTipCap          uint64         `json:"gasTipCap" yaml:"gasTipCap"`
-	VoteBuffer         uint64         `json:"voteBuffer" yaml:"voteBuffer"`
-	KeyFile            string         `json:"keyFile" yaml:"keyFile"`
-	KeyPassword        string         `json:"keyPassword" yaml:"keyPassword"`
-	AutonityWSUrl      string         `json:"autonityWSUrl" yaml:"autonityWSUrl"`
-	PluginDIR          string         `json:"pluginDir" yaml:"pluginDir"`
-	ProfileDir         string         `json:"profileDir" yaml:"profileDir"`
-	ConfidenceStrategy int            `json:"confidenceStrategy" yaml:"confidenceStrategy"`
-	PluginConfigs      []PluginConfig `json:"pluginConfigs" yaml:"pluginConfigs"`
-	MetricConfigs      MetricConfig   `json:"metricConfigs" yaml:"metricConfigs"`
+	LoggingLevel       int    `json:"logLevel" yaml:"logLevel"`
+	GasTipCap          uint64 `json:"gasTipCap" yaml:"gasTipCap"`
+	VoteBuffer         uint64 `json:"voteBuffer" yaml:"voteBuffer"`
+	KeyFile            string `json:"keyFile" yaml:"keyFile"`
+	KeyPassword        string `json:"keyPassword" yaml:"keyPassword"`
+	AutonityWSUrl      string `json:"autonityWSUrl" yaml:"autonityWSUrl"`
+	PluginDIR          string `json:"pluginDir" yaml:"pluginDir"`
+	ProfileDir         string `json:"profileDir" yaml:"profileDir"`
+	ConfidenceStrategy int    `json:"confidenceStrategy" yaml:"confidenceStrategy"`
+	// Reveal the valid reports of a round with missing symbols, the missing symbols are revealed with the invalid price.
+	PartialReveal   bool                  `json:"partialReveal" yaml:"partialReveal"`
+	PluginConfigs   []PluginConfig        `json:"pluginConfigs" yaml:"pluginConfigs"`
+	MetricConfigs   MetricConfig          `json:"metricConfigs" yaml:"metricConfigs"`
+	Logging         LoggingConfig         `json:"logging" yaml:"logging"`
+	Journal         JournalConfig         `json:"journal" yaml:"journal"`
+	Penalty         PenaltyConfig         `json:"penalty" yaml:"penalty"`
+	ConfidenceModel ConfidenceModelConfig `json:"confidenceModel" yaml:"confidenceModel"`
+	Fallback        FallbackConfig        `json:"fallback" yaml:"fallback"`
+	Staleness       StalenessConfig       `json:"staleness" yaml:"staleness"`
+
+	lines map[string]int // the line of each field path presented in the config file.
 }
 
 // PluginConfig is the schema of plugins' config.
 type PluginConfig struct {
-	Name               string `json:"name" yaml:"name"`                         // The name of the plugin binary.
-	Key                string `json:"key" yaml:"key"`                           // The API key granted by your data provider to access their data API.
-	Scheme             string `json:"scheme" yaml:"scheme"`                     // The data service scheme, http or https.
-	Endpoint           string `json:"endpoint" yaml:"endpoint"`                 // The data service endpoint url of the data provider.
-	Timeout            int    `json:"timeout" yaml:"timeout"`                   // The timeout period in seconds that an API request is lasting for.
-	DataUpdateInterval int    `json:"refresh" yaml:"refresh"`                   // The interval in seconds to fetch data from data provider due to rate limit.
-	NTNTokenAddress    string `json:"ntnTokenAddress" yaml:"ntnTokenAddress"`   // The NTN erc20 token address on the target blockchain.
-	ATNTokenAddress    string `json:"atnTokenAddress" yaml:"atnTokenAddress"`   // The Wrapped ATN erc20 token address on the target blockchain.
-	USDCTokenAddress   string `json:"usdcTokenAddress" yaml:"usdcTokenAddress"` // The USDC erc20 token address on the target blockchain.
-	SwapAddress        string `json:"swapAddress" yaml:"swapAddress"`           // The UniSwap factory contract address or AirSwap SwapERC20 contract address on the target blockchain.
-	Disabled           bool   `json:"disabled" yaml:"disabled"`                 // The flag to disable a plugin.
+	Name               string        `json:"name" yaml:"name"`                         // The name of the plugin binary.
+	Key                string        `json:"key" yaml:"key"`                           // The API key granted by your data provider to access their data API.
+	Scheme             string        `json:"scheme" yaml:"scheme"`                     // The data service scheme, http or https.
+	Endpoint           string        `json:"endpoint" yaml:"endpoint"`                 // The data service endpoint url of the data provider.
+	Timeout            int           `json:"timeout" yaml:"timeout"`                   // The timeout period in seconds that an API request is lasting for.
+	DataUpdateInterval int           `json:"refresh" yaml:"refresh"`                   // The interval in seconds to fetch data from data provider due to rate limit.
+	NTNTokenAddress    string        `json:"ntnTokenAddress" yaml:"ntnTokenAddress"`   // The NTN erc20 token address on the target blockchain.
+	ATNTokenAddress    string        `json:"atnTokenAddress" yaml:"atnTokenAddress"`   // The Wrapped ATN erc20 token address on the target blockchain.
+	USDCTokenAddress   string        `json:"usdcTokenAddress" yaml:"usdcTokenAddress"` // The USDC erc20 token address on the target blockchain.
+	SwapAddress        string        `json:"swapAddress" yaml:"swapAddress"`           // The UniSwap factory contract address or AirSwap SwapERC20 contract address on the target blockchain.
+	Disabled           bool          `json:"disabled" yaml:"disabled"`                 // The flag to disable a plugin.
+	MaxStaleness       int           `json:"maxStaleness" yaml:"maxStaleness"`         // The max age in seconds of the samples of the plugin, 0 disables it.
+	Pairs              []PairConfig  `json:"pairs" yaml:"pairs"`                       // The token pairs of the AMM plugins, they are the ATN-USDC and NTN-USDC pairs of the token addresses if omitted.
+	PricingMode        string        `json:"pricingMode" yaml:"pricingMode"`           // The pricing mode of the AMM plugins, vwap or twap, it is vwap if omitted.
+	TWAPWindow         int           `json:"twapWindow" yaml:"twapWindow"`             // The window in seconds of the twap pricing mode, it is 1800 if omitted.
+	StateDir           string        `json:"stateDir" yaml:"stateDir"`                 // The directory where the plugin persists its state, it is set by the oracle server if omitted.
+	MinReserveUSD      float64       `json:"minReserveUSD" yaml:"minReserveUSD"`       // The min USDC reserve of the pools of the AMM plugins, the thinner pools are excluded, 0 disables it.
+	MaxPriceImpact     float64       `json:"maxPriceImpact" yaml:"maxPriceImpact"`     // The max price impact ratio of a swap of the AMM plugins, i.e. 0.02 for 2%, 0 disables it.
+	MaxOrderShare      float64       `json:"maxOrderShare" yaml:"maxOrderShare"`       // The max share of a swap in the window volume of the AMM plugins, i.e. 0.5 for 50%, 0 disables it.
+	BackfillBlocks     int           `json:"backfillBlocks" yaml:"backfillBlocks"`     // The blocks of the swap history backfilled by the AMM plugins on start and on re-subscription, it is 1800 if omitted.
+	Routes             []RouteConfig `json:"routes" yaml:"routes"`                     // The multi-hop routes of the AMM plugins to price the symbols without a direct pair, the routes of a symbol are taken in order.
+	Calls              []CallConfig  `json:"calls" yaml:"calls"`                       // The view calls of the EVM call plugin, the calls of a symbol are taken in order.
+	REST               RESTConfig    `json:"rest" yaml:"rest"`                         // The requests and the extraction rules of the REST JSON plugin.
+	Symbols            []string      `json:"symbols" yaml:"symbols"`                   // The protocol symbols of the streaming plugins, i.e. USDC-USD, it is the default symbols of the plugin if omitted.
+	RateLimit          float64       `json:"rateLimit" yaml:"rateLimit"`               // The max requests per second to the endpoint host of the plugin, 0 disables it.
+	RateBurst          int           `json:"rateBurst" yaml:"rateBurst"`               // The burst of the requests to the endpoint host of the plugin, it is 1 if omitted.
+	Retries            int           `json:"retries" yaml:"retries"`                   // The retries of a failed GET request of the plugin, it is 2 if omitted, -1 disables them.
+}
+
+// PairConfig is a token pair of an AMM plugin, the price of the symbol is the exchange ratio of the base token to the
+// quote token, and the volume is in the quote token.
+type PairConfig struct {
+	Symbol        string `json:"symbol" yaml:"symbol"`               // The symbol of the pair, i.e. ATN-USDC.
+	Base          string `json:"base" yaml:"base"`                   // The base erc20 token address on the target blockchain.
+	Quote         string `json:"quote" yaml:"quote"`                 // The quote erc20 token address on the target blockchain.
+	BaseDecimals  uint8  `json:"baseDecimals" yaml:"baseDecimals"`   // The decimals of the base token, it is read from the token contract if it is 0.
+	QuoteDecimals uint8  `json:"quoteDecimals" yaml:"quoteDecimals"` // The decimals of the quote token, it is read from the token contract if it is 0.
+}
+
+// RouteConfig is a multi-hop route of an AMM plugin, the price of the symbol is the exchange ratio of the first token to
+// the last token of the path, it is combined from the pairs of the consecutive tokens of the path.
+type RouteConfig struct {
+	Symbol string   `json:"symbol" yaml:"symbol"` // The symbol of the route, i.e. NTN-USDC.
+	Path   []string `json:"path" yaml:"path"`     // The erc20 token addresses from the base token to the quote token, i.e. NTN, WATN and USDC.
+}
+
+// CallConfig is a view call of the EVM call plugin, the price of the symbol is the output field of the view method of
+// the contract scaled by the decimals, i.e. the answer of the latestRoundData of a Chainlink aggregator.
+type CallConfig struct {
+	Symbol    string   `json:"symbol" yaml:"symbol"`       // The protocol symbol of the call, i.e. ATN-USD.
+	Contract  string   `json:"contract" yaml:"contract"`   // The contract address on the target blockchain.
+	ABI       string   `json:"abi" yaml:"abi"`             // The JSON ABI fragment of the view method, a method object or an array of them.
+	Method    string   `json:"method" yaml:"method"`       // The name of the view method, it is the only method of the ABI fragment if omitted.
+	Args      []string `json:"args" yaml:"args"`           // The arguments of the view method, they are parsed by the types of its inputs.
+	Output    string   `json:"output" yaml:"output"`       // The output field of the price by its name or index, it is the first output if omitted.
+	Decimals  uint8    `json:"decimals" yaml:"decimals"`   // The decimals of the output field of the price.
+	UpdatedAt string   `json:"updatedAt" yaml:"updatedAt"` // The optional output field of the update time in Unix seconds by its name or index.
+	MaxAge    int      `json:"maxAge" yaml:"maxAge"`       // The max age in seconds of the update time, the older data is not reported, 0 disables it.
+}
+
+// RESTConfig is the declarative config of the REST JSON plugin, a provider is onboarded by the URL template of its API,
+// the placement of its API key, and the JSONPath of the price, the volume and the timestamp in its responses. The
+// templates take the placeholders {symbol}, {base} and {quote} of a symbol, and {symbols} of all the symbols.
+type RESTConfig struct {
+	Path       string             `json:"path" yaml:"path"`             // The URL template of the path and the query on the endpoint, i.e. /0/public/Ticker?pair={symbol}.
+	Mode       string             `json:"mode" yaml:"mode"`             // The request mode, symbol or batch, it is symbol if omitted.
+	Separator  string             `json:"separator" yaml:"separator"`   // The separator of the provider symbols in {symbols}, it is "," if omitted.
+	AuthHeader string             `json:"authHeader" yaml:"authHeader"` // The header of the API key, i.e. Authorization.
+	AuthPrefix string             `json:"authPrefix" yaml:"authPrefix"` // The prefix of the API key in the header, i.e. "Bearer ".
+	AuthQuery  string             `json:"authQuery" yaml:"authQuery"`   // The query parameter of the API key, i.e. apikey.
+	Price      string             `json:"price" yaml:"price"`           // The JSONPath template of the price, i.e. $.result.{symbol}.c[0].
+	Volume     string             `json:"volume" yaml:"volume"`         // The optional JSONPath template of the volume, the default volume is taken if omitted.
+	Timestamp  string             `json:"timestamp" yaml:"timestamp"`   // The optional JSONPath template of the quote time in Unix seconds, milliseconds or RFC3339.
+	Symbols    []RESTSymbolConfig `json:"symbols" yaml:"symbols"`       // The protocol symbols of the plugin and their mapping to the provider.
+}
+
+// RESTSymbolConfig maps a protocol symbol to the symbol of a provider, the price of the provider is inverted if it is
+// quoted the other way around, i.e. the USD-EUR rate for EUR-USD.
+type RESTSymbolConfig struct {
+	Symbol string `json:"symbol" yaml:"symbol"` // The protocol symbol, i.e. EUR-USD, its base and quote are the {base} and {quote} placeholders.
+	Remote string `json:"remote" yaml:"remote"` // The provider symbol of the {symbol} placeholder, i.e. EURUSD, it is the protocol symbol if omitted.
+	Invert bool   `json:"invert" yaml:"invert"` // The flag to invert the price of the provider.
 }
 
 // Config is the resolved configuration of the oracle-server.
 type Config struct {
 	ConfigFile         string
+	Args               []string // the CLI args which override the config file, they are re-applied on config reload.
 	LoggingLevel       hclog.Level
 	GasTipCap          uint64
 	VoteBuffer         uint64
+	KeyFile            string
 	Key                *keystore.Key
 	AutonityWSUrl      string
 	PluginDIR          string
 	ProfileDir         string
 	ConfidenceStrategy int
+	PartialReveal      bool
 	PluginConfigs      map[string]PluginConfig
 	MetricConfigs      MetricConfig
+	Logging            LoggingConfig
+	Journal            JournalConfig
+	Penalty            PenaltyConfig
+	ConfidenceModel    ConfidenceModelConfig
+	Fallback           FallbackConfig
+	Staleness          StalenessConfig
 }
 
 func MakeConfig() *Config {
-	if len(os.Args) != 2 {
-		log.SetFlags(0)
-		printUsage()
-		os.Exit(1)
-	}
-
-	if os.Args[1] == "version" {
+	// the config file can be given by the AUTORACLE_CONFIG_FILE environment variable, thus the args can be empty.
+	args := os.Args[1:]
+	if len(args) == 1 && args[0] == "version" {
 		log.SetFlags(0)
 		log.Println(VersionString(Version))
 		os.Exit(0)
 	}
 
-	oracleConfFile := os.Args[1]
-	config, err := LoadServerConfig(oracleConfFile)
+	// the config sub command prints the effective config.
+	printConf := len(args) > 0 && args[0] == "config"
+	if printConf {
+		args = args[1:]
+	}
+
+	oracleConfFile, err := ResolveConfigFile(args)
 	if err != nil {
 		log.SetFlags(0)
-		log.Printf("could not load oracle_server config: %s, err: %s", oracleConfFile, err.Error())
+		log.Printf("could not resolve oracle_server config file, err: %s", err.Error())
 		printUsage()
 		os.Exit(1)
 	}
 
-	key, err := LoadKey(config.KeyFile, config.KeyPassword)
+	config, err := LoadLayeredConfig(oracleConfFile, args)
 	if err != nil {
 		log.SetFlags(0)
-		log.Printf("could not load key from key store: %s with password, err: %s", config.KeyFile, err.Error())
+		log.Printf("could not load oracle_server config: %s, err: %s", oracleConfFile, err.Error())
+		printUsage()
 		os.Exit(1)
 	}
 
-	if config.MetricConfigs.EnableInfluxDB && config.MetricConfigs.EnableInfluxDBV2 {
-		log.SetFlags(0)
-		log.Println("There are two metrics engine enabled, please select one: influxDB or influxDBV2")
-		os.Exit(1)
+	if printConf {
+		if err = PrintConfig(os.Stdout, config); err != nil {
+			log.Printf("could not print oracle_server config: %s", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	pluginConfigs := make(map[string]PluginConfig)
-	for _, conf := range config.PluginConfigs {
-		c := conf
-		pluginConfigs[c.Name] = c
+	// plugin binaries can be added into the plugin directory on runtime, thus the mismatches are warned only.
+	if err = config.CheckPluginDir(); err != nil {
+		log.Printf("plugin configs in oracle_server config: %s mismatch the plugin directory, warn: %s", oracleConfFile, err.Error())
+	}
+
+	key, err := LoadKey(config.KeyFile, config.KeyPassword)
+	if err != nil {
+		log.SetFlags(0)
+		log.Printf("could not load key from key store: %s with password, err: %s", config.KeyFile, err.Error())
+		os.Exit(1)
 	}
 
 	return &Config{
 		VoteBuffer:         config.VoteBuffer,
 		GasTipCap:          config.GasTipCap,
+		KeyFile:            config.KeyFile,
 		Key:                key,
 		AutonityWSUrl:      config.AutonityWSUrl,
 		PluginDIR:          config.PluginDIR,
 		ProfileDir:         config.ProfileDir,
 		LoggingLevel:       hclog.Level(config.LoggingLevel), //nolint
 		ConfidenceStrategy: config.ConfidenceStrategy,
+		PartialReveal:      config.PartialReveal,
 		ConfigFile:         oracleConfFile,
-		PluginConfigs:      pluginConfigs,
+		Args:               args,
+		PluginConfigs:      config.PluginConfigMap(),
 		MetricConfigs:      config.MetricConfigs,
+		Logging:            config.Logging,
+		Journal:            config.Journal,
+		Penalty:            config.Penalty,
+		ConfidenceModel:    config.ConfidenceModel,
+		Fallback:           config.Fallback,
+		Staleness:          config.Staleness,
 	}
 }
 
@@ -205,17 +462,34 @@ func LoadKey(keyFile, password string) (*keystore.Key, error) {
 	return key, nil
 }
 
+// LoadServerConfig loads the config file on top of the DefaultConfig, it rejects unknown fields and invalid values.
 func LoadServerConfig(file string) (*ServerConfig, error) {
+	config, err := loadServerConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func loadServerConfig(file string) (*ServerConfig, error) {
 	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 
 	config := DefaultConfig
-	if err = yaml.Unmarshal(data, &config); err != nil {
+	lines, err := decodeStrict(data, &config)
+	if err != nil {
+		if _, ok := err.(FieldErrors); ok {
+			return nil, err
+		}
 		return nil, fmt.Errorf("error unmarshalling YAML: %v", err)
 	}
-
+	config.lines = lines
 	return &config, nil
 }
 
@@ -225,13 +499,17 @@ func LoadPluginsConfig(file string) (map[string]PluginConfig, error) {
 		return nil, err
 	}
 
+	return serverConf.PluginConfigMap(), nil
+}
+
+// PluginConfigMap returns the plugin configs indexed by the plugin names.
+func (c *ServerConfig) PluginConfigMap() map[string]PluginConfig {
 	pluginConfigs := make(map[string]PluginConfig)
-	for _, conf := range serverConf.PluginConfigs {
-		c := conf
-		pluginConfigs[c.Name] = c
+	for _, conf := range c.PluginConfigs {
+		pc := conf
+		pluginConfigs[pc.Name] = pc
 	}
-
-	return pluginConfigs, nil
+	return pluginConfigs
 }
 
 func VersionString(version uint8) string {
@@ -260,6 +538,11 @@ func SplitTagsFlag(tagsFlag string) map[string]string {
 
 func printUsage() {
 	fmt.Print("Usage of Autonity Oracle Server:\n")
-	fmt.Printf("%s <oracle_config.yml>\n", os.Args[0])
+	fmt.Printf("%s [flags] <oracle_config.yml>\n", os.Args[0])
 	fmt.Print("Sub commands: \n  version: print the version of the oracle server.\n")
+	fmt.Print("  config [flags] <oracle_config.yml>: print the effective config with the secrets redacted.\n")
+	fmt.Print("  plugin probe [flags] <plugin binary>: run a plugin in isolation and print the prices it fetches.\n")
+	fmt.Print("  replay [flags]: re-run the aggregation of the recorded rounds of the journal and print the changed reports.\n")
+	fmt.Print("Flags, they take precedence over the environment variables, which take precedence over the config file:\n")
+	PrintLayeredFlags(os.Stdout)
 }
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/namsral/flag"
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the system environment variables which override the config file, the variable of a flag is
+// the upper case of the flag name with `-` replaced by `_`, for example: AUTORACLE_AUTONITY_WS_URL.
+const EnvPrefix = "AUTORACLE"
+
+const (
+	configFileFlag   = "config-file"
+	redactedSecret   = "<redacted>"
+	redactedUserinfo = "redacted"
+)
+
+var ErrNoConfigFile = errors.New("the oracle server config file is missing")
+
+// jsonValue is the flag value of a config field in JSON, it replaces the whole field of the config file.
+type jsonValue struct {
+	field interface{}
+}
+
+func (v *jsonValue) String() string {
+	if v.field == nil {
+		return ""
+	}
+	data, err := json.Marshal(v.field)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (v *jsonValue) Set(s string) error {
+	// reset the field, thus a map is replaced rather than merged.
+	field := reflect.ValueOf(v.field).Elem()
+	field.Set(reflect.Zero(field.Type()))
+	if err := json.Unmarshal([]byte(s), v.field); err != nil {
+		return fmt.Errorf("invalid JSON value: %v", err)
+	}
+	return nil
+}
+
+// layeredFlag is a flag which overrides a field of the config file, the path is the field path in the config file.
+type layeredFlag struct {
+	name string
+	path string
+	bind func(fs *flag.FlagSet, c *ServerConfig, name, usage string)
+	doc  string
+}
+
+var layeredFlags = []layeredFlag{
+	{"log-level", "logLevel", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.LoggingLevel, name, c.LoggingLevel, usage)
+	}, "Logging verbosity: 0: NoLevel, 1: Trace, 2: Debug, 3: Info, 4: Warn, 5: Error"},
+	{"gas-tip-cap", "gasTipCap", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Uint64Var(&c.GasTipCap, name, c.GasTipCap, usage)
+	}, "The gas priority fee cap to issue the oracle data report transactions"},
+	{"vote-buffer", "voteBuffer", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Uint64Var(&c.VoteBuffer, name, c.VoteBuffer, usage)
+	}, "The buffering time window in blocks to continue vote after the last penalty event"},
+	{"key-file", "keyFile", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.KeyFile, name, c.KeyFile, usage)
+	}, "The oracle server key file"},
+	{"key-password", "keyPassword", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.KeyPassword, name, c.KeyPassword, usage)
+	}, "The password to decrypt the oracle server key file"},
+	{"autonity-ws-url", "autonityWSUrl", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.AutonityWSUrl, name, c.AutonityWSUrl, usage)
+	}, "The WS-RPC server listening interface and port of the connected Autonity Client node"},
+	{"plugin-dir", "pluginDir", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.PluginDIR, name, c.PluginDIR, usage)
+	}, "The directory of the data plugins"},
+	{"profile-dir", "profileDir", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.ProfileDir, name, c.ProfileDir, usage)
+	}, "The profiling report directory"},
+	{"confidence-strategy", "confidenceStrategy", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.ConfidenceStrategy, name, c.ConfidenceStrategy, usage)
+	}, "The confidence strategy: 0: linear, 1: fixed, 2: model"},
+	{"confidence-model-expected-sources", "confidenceModel.expectedSources", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.ConfidenceModel.ExpectedSources, name, c.ConfidenceModel.ExpectedSources, usage)
+	}, "The reliable sources of a symbol for the full coverage of the confidence model"},
+	{"confidence-model-dispersion-scale", "confidenceModel.dispersionScale", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Float64Var(&c.ConfidenceModel.DispersionScale, name, c.ConfidenceModel.DispersionScale, usage)
+	}, "The dispersion in percent of the source prices that halves the confidence"},
+	{"confidence-model-max-sample-age", "confidenceModel.maxSampleAge", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.ConfidenceModel.MaxSampleAge, name, c.ConfidenceModel.MaxSampleAge, usage)
+	}, "The sample age in seconds that zeros the freshness of a source"},
+	{"confidence-model-volume-targets", "confidenceModel.volumeTargets", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.ConfidenceModel.VolumeTargets}, name, usage)
+	}, "The volume of the symbols for full confidence in a JSON object, for example: {\"NTN-USDC\":1000}"},
+	{"confidence-model-reliability", "confidenceModel.reliability", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.ConfidenceModel.Reliability}, name, usage)
+	}, "The reliability of the plugins in [0, 1] in a JSON object, for example: {\"forex_currencyfreaks\":0.8}"},
+	{"fallback-decay-curve", "fallback.decay.curve", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.Fallback.Decay.Curve, name, c.Fallback.Decay.Curve, usage)
+	}, "The confidence decay curve of the historic fallback prices: step, linear or exponential"},
+	{"fallback-decay-period", "fallback.decay.period", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Fallback.Decay.Period, name, c.Fallback.Decay.Period, usage)
+	}, "The time scale in seconds of the confidence decay curve"},
+	{"fallback-decay-max-age", "fallback.decay.maxAge", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Fallback.Decay.MaxAge, name, c.Fallback.Decay.MaxAge, usage)
+	}, "The age in seconds after which a historic fallback price is not reported, 0 disables it"},
+	{"fallback-symbols", "fallback.symbols", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.Fallback.Symbols}, name, usage)
+	}, "The decay curves by symbol in a JSON object, for example: {\"EUR-USD\":{\"curve\":\"linear\",\"period\":3600}}"},
+	{"fallback-on-chain", "fallback.onChain", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.BoolVar(&c.Fallback.OnChain, name, c.Fallback.OnChain, usage)
+	}, "Fall back to the on-chain latest round data if there is no historic round in memory"},
+	{"staleness-max-age", "staleness.maxAge", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Staleness.MaxAge, name, c.Staleness.MaxAge, usage)
+	}, "The max age in seconds of the samples to the round timestamp, 0 disables it"},
+	{"staleness-symbols", "staleness.symbols", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.Staleness.Symbols}, name, usage)
+	}, "The max ages in seconds of the samples by symbol in a JSON object, for example: {\"EUR-USD\":3600}"},
+	{"partial-reveal", "partialReveal", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.BoolVar(&c.PartialReveal, name, c.PartialReveal, usage)
+	}, "Reveal the valid reports of a round with missing symbols rather than discarding all of them"},
+	{"plugin-configs", "pluginConfigs", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.PluginConfigs}, name, usage)
+	}, "The plugin configs in a JSON array, it replaces all the plugin configs of the config file"},
+	{"log-format", "logging.format", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.Logging.Format, name, c.Logging.Format, usage)
+	}, "The log format: text or json"},
+	{"log-level-server", "logging.levels.server", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.Levels.Server, name, c.Logging.Levels.Server, usage)
+	}, "The log level of the oracle server, 0 takes the log-level"},
+	{"log-level-monitor", "logging.levels.monitor", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.Levels.Monitor, name, c.Logging.Levels.Monitor, usage)
+	}, "The log level of the system monitor, 0 takes the log-level"},
+	{"log-level-plugins", "logging.levels.plugins", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.Var(&jsonValue{field: &c.Logging.Levels.Plugins}, name, usage)
+	}, "The log levels of plugins by plugin name in a JSON object, for example: {\"crypto_uniswap\":2}"},
+	{"log-file", "logging.file", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.Logging.File, name, c.Logging.File, usage)
+	}, "The log file, the logs are written to stdout if it is empty"},
+	{"log-max-size-mb", "logging.maxSizeMB", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.MaxSizeMB, name, c.Logging.MaxSizeMB, usage)
+	}, "The size in MB to rotate the log file, 0 disables it"},
+	{"log-rotation-hours", "logging.rotationHours", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.RotationHours, name, c.Logging.RotationHours, usage)
+	}, "The interval in hours to rotate the log file, 0 disables it"},
+	{"log-max-backups", "logging.maxBackups", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.MaxBackups, name, c.Logging.MaxBackups, usage)
+	}, "The number of rotated log files to be kept, 0 keeps all"},
+	{"log-max-age-days", "logging.maxAgeDays", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Logging.MaxAgeDays, name, c.Logging.MaxAgeDays, usage)
+	}, "The days to keep the rotated log files, 0 keeps them forever"},
+	{"journal-max-size-mb", "journal.maxSizeMB", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Journal.MaxSizeMB, name, c.Journal.MaxSizeMB, usage)
+	}, "The size in MB to rotate the round journal, 0 disables it"},
+	{"journal-retention-days", "journal.retentionDays", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Journal.RetentionDays, name, c.Journal.RetentionDays, usage)
+	}, "The days to keep the rotated round journals, 0 keeps them forever"},
+	{"penalty-policy", "penalty.policy", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.Penalty.Policy, name, c.Penalty.Policy, usage)
+	}, "The outlier penalty policy: vote postpones the whole vote, symbol suppresses only the penalized symbol"},
+	{"penalty-suppression", "penalty.suppression", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.Penalty.Suppression, name, c.Penalty.Suppression, usage)
+	}, "The report of a suppressed symbol: invalid or lowConfidence"},
+	{"penalty-recovery-rounds", "penalty.recoveryRounds", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.IntVar(&c.Penalty.RecoveryRounds, name, c.Penalty.RecoveryRounds, usage)
+	}, "The healthy rounds for a suppressed symbol to regain its full confidence"},
+	{"influxdb-endpoint", "metricConfigs.influxDBEndpoint", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBEndpoint, name, c.MetricConfigs.InfluxDBEndpoint, usage)
+	}, "The influxDB endpoint"},
+	{"influxdb-tags", "metricConfigs.influxDBTags", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBTags, name, c.MetricConfigs.InfluxDBTags, usage)
+	}, "The comma separated influxDB tags, for example: host=localhost"},
+	{"enable-influxdb", "metricConfigs.enableInfluxDB", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.BoolVar(&c.MetricConfigs.EnableInfluxDB, name, c.MetricConfigs.EnableInfluxDB, usage)
+	}, "Enable the metrics export to influxDB v1"},
+	{"influxdb-database", "metricConfigs.influxDBDatabase", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBDatabase, name, c.MetricConfigs.InfluxDBDatabase, usage)
+	}, "The influxDB v1 database"},
+	{"influxdb-username", "metricConfigs.influxDBUsername", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBUsername, name, c.MetricConfigs.InfluxDBUsername, usage)
+	}, "The influxDB v1 username"},
+	{"influxdb-password", "metricConfigs.influxDBPassword", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBPassword, name, c.MetricConfigs.InfluxDBPassword, usage)
+	}, "The influxDB v1 password"},
+	{"enable-influxdb-v2", "metricConfigs.enableInfluxDBV2", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.BoolVar(&c.MetricConfigs.EnableInfluxDBV2, name, c.MetricConfigs.EnableInfluxDBV2, usage)
+	}, "Enable the metrics export to influxDB v2"},
+	{"influxdb-token", "metricConfigs.influxDBToken", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBToken, name, c.MetricConfigs.InfluxDBToken, usage)
+	}, "The influxDB v2 token"},
+	{"influxdb-bucket", "metricConfigs.influxDBBucket", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBBucket, name, c.MetricConfigs.InfluxDBBucket, usage)
+	}, "The influxDB v2 bucket"},
+	{"influxdb-organization", "metricConfigs.influxDBOrganization", func(fs *flag.FlagSet, c *ServerConfig, name, usage string) {
+		fs.StringVar(&c.MetricConfigs.InfluxDBOrganization, name, c.MetricConfigs.InfluxDBOrganization, usage)
+	}, "The influxDB v2 organization"},
+}
+
+// newLayeredFlagSet binds the flags onto the fields of the config, thus the values loaded from the config file are kept
+// unless they are overridden by the flags or by the environment variables.
+func newLayeredFlagSet(c *ServerConfig, configFile *string, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSetWithEnvPrefix("autoracle", EnvPrefix, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {}
+	fs.StringVar(configFile, configFileFlag, "", "The oracle server config file, it can be given as the last argument too")
+	for _, f := range layeredFlags {
+		f.bind(fs, c, f.name, f.doc)
+	}
+	return fs
+}
+
+// ResolveConfigFile resolves the config file from the last positional argument, from the -config-file flag, or from
+// the AUTORACLE_CONFIG_FILE environment variable.
+func ResolveConfigFile(args []string) (string, error) {
+	var configFile string
+	fs := newLayeredFlagSet(&ServerConfig{}, &configFile, io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+
+	if fs.NArg() > 1 {
+		return "", fmt.Errorf("unexpected arguments: %s, flags should be put before the config file", strings.Join(fs.Args()[1:], " "))
+	}
+	// the positional argument takes precedence over the flag and the environment variable.
+	if fs.NArg() == 1 {
+		configFile = fs.Arg(0)
+	}
+	if configFile == "" {
+		return "", ErrNoConfigFile
+	}
+	return configFile, nil
+}
+
+// LoadLayeredConfig loads the config with the precedence: CLI flags > AUTORACLE_* environment variables > config file >
+// DefaultConfig. The layered config is validated as a whole.
+func LoadLayeredConfig(file string, args []string) (*ServerConfig, error) {
+	config, err := loadServerConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var configFile string
+	fs := newLayeredFlagSet(config, &configFile, io.Discard)
+	if err = fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// the overridden fields are no longer pinned to the lines of the config file.
+	paths := make(map[string]string)
+	for _, f := range layeredFlags {
+		paths[f.name] = f.path
+	}
+	fs.Visit(func(f *flag.Flag) {
+		path, ok := paths[f.Name]
+		if !ok {
+			return
+		}
+		for p := range config.lines {
+			if p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+				delete(config.lines, p)
+			}
+		}
+	})
+
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// PrintLayeredFlags prints the flags and their environment variables which override the config file.
+func PrintLayeredFlags(w io.Writer) {
+	fmt.Fprintf(w, "  -%s\n    \t%s\n", configFileFlag, "The oracle server config file, it can be given as the last argument too")
+	fmt.Fprintf(w, "    \tenv: %s\n", envName(configFileFlag))
+	for _, f := range layeredFlags {
+		fmt.Fprintf(w, "  -%s\n    \t%s\n    \tenv: %s\n", f.name, f.doc, envName(f.name))
+	}
+}
+
+func envName(flagName string) string {
+	return EnvPrefix + "_" + strings.ReplaceAll(strings.ToUpper(flagName), "-", "_")
+}
+
+// Redacted returns a copy of the config with the secrets replaced, including the credentials of the URLs, it is safe to
+// be printed.
+func (c *ServerConfig) Redacted() ServerConfig {
+	redacted := *c
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redactedSecret
+		}
+	}
+	redact(&redacted.KeyPassword)
+	redact(&redacted.MetricConfigs.InfluxDBPassword)
+	redact(&redacted.MetricConfigs.InfluxDBToken)
+	redacted.AutonityWSUrl = redactURL(c.AutonityWSUrl, "")
+	redacted.MetricConfigs.InfluxDBEndpoint = redactURL(c.MetricConfigs.InfluxDBEndpoint, "")
+	redacted.PluginConfigs = make([]PluginConfig, len(c.PluginConfigs))
+	for i, p := range c.PluginConfigs {
+		redacted.PluginConfigs[i] = p
+		redact(&redacted.PluginConfigs[i].Key)
+		redacted.PluginConfigs[i].Endpoint = redactURL(p.Endpoint, p.REST.AuthQuery)
+		redacted.PluginConfigs[i].REST.Path = redactURL(p.REST.Path, p.REST.AuthQuery)
+	}
+	redacted.lines = nil
+	return redacted
+}
+
+// credentialParams are the query parameters which carry the API keys of the data providers, in lower case.
+var credentialParams = map[string]bool{"apikey": true, "api_key": true, "key": true, "access_key": true, "app_id": true,
+	"token": true, "access_token": true}
+
+// redactURL redacts the userinfo and the values of the credential query parameters of an URL, including the auth query
+// parameter of the REST plugin. The endpoints of the plugins are configured without a scheme, and the REST paths are
+// templates, thus the URL is redacted in place without being re-encoded.
+func redactURL(s string, authQuery string) string {
+	scheme, rest := "", s
+	if i := strings.Index(rest, "://"); i >= 0 {
+		scheme, rest = rest[:i+3], rest[i+3:]
+	}
+
+	authority := rest
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority = authority[:i]
+	}
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		// the redacted userinfo keeps the URL valid.
+		rest = redactedUserinfo + rest[i:]
+	}
+
+	if i := strings.Index(rest, "?"); i >= 0 {
+		params := strings.Split(rest[i+1:], "&")
+		for j, param := range params {
+			name, _, found := strings.Cut(param, "=")
+			if found && (credentialParams[strings.ToLower(name)] || (authQuery != "" && name == authQuery)) {
+				params[j] = name + "=" + redactedSecret
+			}
+		}
+		rest = rest[:i+1] + strings.Join(params, "&")
+	}
+	return scheme + rest
+}
+
+// PrintConfig prints the effective config in yaml with the secrets redacted.
+func PrintConfig(w io.Writer, c *ServerConfig) error {
+	redacted := c.Redacted()
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redacted); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,507 @@
+package config
+
+import (
+	"autonity-oracle/helpers"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	maxLogVerbosity = 5 // 0: NoLevel, 1: Trace, 2:Debug, 3: Info, 4: Warn, 5: Error
+
+	autonityWSSchemes = []string{"ws", "wss"}
+	pluginSchemes     = []string{"http", "https", "ws", "wss"}
+	influxDBSchemes   = []string{"http", "https"}
+)
+
+// FieldError is a config error pinned to the line and the field path of the config file where it was detected.
+type FieldError struct {
+	Line int    // the line of the field in the config file, 0 if the field is omitted from the file.
+	Path string // the field path, for example: pluginConfigs[1].refresh
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Msg)
+}
+
+// FieldErrors collects all the errors detected from a config file, thus they can be fixed at once.
+type FieldErrors []*FieldError
+
+func (es FieldErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// decodeStrict decodes the yaml document on top of the config, it rejects the keys which are not in the config schema,
+// and it returns the line of each field path presented in the document.
+func decodeStrict(data []byte, config *ServerConfig) (map[string]int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]int)
+	// an empty document takes all the default values.
+	if len(doc.Content) == 0 {
+		return lines, nil
+	}
+
+	root := doc.Content[0]
+	var errs FieldErrors
+	walkSchema(root, reflect.TypeOf(*config), "", lines, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := root.Decode(config); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// walkSchema checks the node against the yaml schema of type t, it records the line of each field and it reports the
+// unknown keys with a suggestion of the nearest known key.
+func walkSchema(node *yaml.Node, t reflect.Type, path string, lines map[string]int, errs *FieldErrors) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := joinPath(path, key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := "unknown field"
+				if s := suggestField(key.Value, fields); s != "" {
+					msg = fmt.Sprintf("unknown field, did you mean %q?", s)
+				}
+				*errs = append(*errs, &FieldError{Line: key.Line, Path: fieldPath, Msg: msg})
+				continue
+			}
+			lines[fieldPath] = key.Line
+			walkSchema(value, field.Type, fieldPath, lines, errs)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			lines[keyPath] = key.Line
+			walkSchema(value, t.Elem(), keyPath, lines, errs)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			lines[itemPath] = item.Line
+			walkSchema(item, t.Elem(), itemPath, lines, errs)
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// suggestField returns the known field which is the closest to the unknown key, if it is close enough to be a typo.
+func suggestField(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", len(key)/2+1
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Validate checks the ranges, the enums, the URLs and the addresses of the loaded config, all the violations are
+// returned at once.
+func (c *ServerConfig) Validate() error {
+	var errs FieldErrors
+	report := func(path, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Line: c.lines[path], Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if c.LoggingLevel < 0 || c.LoggingLevel > maxLogVerbosity {
+		report("logLevel", "%d is out of range [0, %d]", c.LoggingLevel, maxLogVerbosity)
+	}
+	if c.ConfidenceStrategy != ConfidenceStrategyLinear && c.ConfidenceStrategy != ConfidenceStrategyFixed &&
+		c.ConfidenceStrategy != ConfidenceStrategyModel {
+		report("confidenceStrategy", "%d is not a supported strategy, use %d: linear, %d: fixed or %d: model",
+			c.ConfidenceStrategy, ConfidenceStrategyLinear, ConfidenceStrategyFixed, ConfidenceStrategyModel)
+	}
+	cm := c.ConfidenceModel
+	if cm.ExpectedSources < 1 {
+		report("confidenceModel.expectedSources", "%d should be at least 1", cm.ExpectedSources)
+	}
+	if cm.DispersionScale <= 0 {
+		report("confidenceModel.dispersionScale", "%v should be positive", cm.DispersionScale)
+	}
+	if cm.MaxSampleAge < 1 {
+		report("confidenceModel.maxSampleAge", "%d should be at least 1", cm.MaxSampleAge)
+	}
+	for symbol, v := range cm.VolumeTargets {
+		if v < 0 {
+			report("confidenceModel.volumeTargets."+symbol, "%v cannot be negative", v)
+		}
+	}
+	for plugin, r := range cm.Reliability {
+		if r < 0 || r > 1 {
+			report("confidenceModel.reliability."+plugin, "%v is out of range [0, 1]", r)
+		}
+	}
+	if c.KeyFile == "" {
+		report("keyFile", "the key file is required")
+	}
+	if c.PluginDIR == "" {
+		report("pluginDir", "the plugin directory is required")
+	}
+	if err := checkURL(c.AutonityWSUrl, autonityWSSchemes); err != nil {
+		report("autonityWSUrl", "%s", err.Error())
+	}
+
+	names := make(map[string]int)
+	for i, p := range c.PluginConfigs {
+		path := "pluginConfigs[" + strconv.Itoa(i) + "]"
+		if p.Name == "" {
+			report(path+".name", "the plugin name is required")
+		} else if j, ok := names[p.Name]; ok {
+			report(path+".name", "plugin %s is already configured by pluginConfigs[%d]", p.Name, j)
+		} else {
+			names[p.Name] = i
+		}
+
+		if p.Scheme != "" && !contains(pluginSchemes, p.Scheme) {
+			report(path+".scheme", "%q is not a supported scheme, use one of: %s", p.Scheme, strings.Join(pluginSchemes, ", "))
+		}
+		if p.Endpoint != "" {
+			scheme := p.Scheme
+			if scheme == "" {
+				scheme = "https"
+			}
+			if u, err := url.Parse(scheme + "://" + p.Endpoint); err != nil || u.Host == "" {
+				report(path+".endpoint", "%q is not a valid endpoint, it should be a host with an optional path and without scheme", p.Endpoint)
+			}
+		}
+		if p.Timeout < 0 {
+			report(path+".timeout", "%d cannot be negative", p.Timeout)
+		}
+		if p.DataUpdateInterval < 0 {
+			report(path+".refresh", "%d cannot be negative", p.DataUpdateInterval)
+		}
+		if p.MaxStaleness < 0 {
+			report(path+".maxStaleness", "%d cannot be negative", p.MaxStaleness)
+		}
+		if p.PricingMode != "" && p.PricingMode != PricingModeVWAP && p.PricingMode != PricingModeTWAP {
+			report(path+".pricingMode", "%q is not a supported pricing mode, use %s or %s", p.PricingMode, PricingModeVWAP, PricingModeTWAP)
+		}
+		if p.Name == "crypto_uniswap_v3" && p.PricingMode == PricingModeTWAP {
+			report(path+".pricingMode", "the %s pricing mode is not supported by %s", PricingModeTWAP, p.Name)
+		}
+		if p.TWAPWindow < 0 {
+			report(path+".twapWindow", "%d cannot be negative", p.TWAPWindow)
+		}
+		if p.MinReserveUSD < 0 {
+			report(path+".minReserveUSD", "%v cannot be negative", p.MinReserveUSD)
+		}
+		if p.MaxPriceImpact < 0 {
+			report(path+".maxPriceImpact", "%v cannot be negative", p.MaxPriceImpact)
+		}
+		if p.MaxOrderShare < 0 || p.MaxOrderShare > 1 {
+			report(path+".maxOrderShare", "%v is out of range [0, 1]", p.MaxOrderShare)
+		}
+		if p.BackfillBlocks < 0 {
+			report(path+".backfillBlocks", "%d cannot be negative", p.BackfillBlocks)
+		}
+		if p.RateLimit < 0 {
+			report(path+".rateLimit", "%v cannot be negative", p.RateLimit)
+		}
+		if p.RateBurst < 0 {
+			report(path+".rateBurst", "%d cannot be negative", p.RateBurst)
+		}
+		if p.Retries < -1 {
+			report(path+".retries", "%d is out of range, use -1 to disable the retries", p.Retries)
+		}
+
+		addresses := []struct {
+			field, value string
+		}{
+			{"ntnTokenAddress", p.NTNTokenAddress},
+			{"atnTokenAddress", p.ATNTokenAddress},
+			{"usdcTokenAddress", p.USDCTokenAddress},
+			{"swapAddress", p.SwapAddress},
+		}
+		for j, pair := range p.Pairs {
+			pairField := "pairs[" + strconv.Itoa(j) + "]"
+			if pair.Symbol == "" {
+				report(path+"."+pairField+".symbol", "the symbol of the pair is required")
+			}
+			if pair.Base == "" || pair.Quote == "" {
+				report(path+"."+pairField, "both the base and the quote token addresses of the pair are required")
+			}
+			addresses = append(addresses, struct{ field, value string }{pairField + ".base", pair.Base},
+				struct{ field, value string }{pairField + ".quote", pair.Quote})
+		}
+		if len(p.Routes) > 0 && (p.Name == "crypto_uniswap_v3" || p.Name == "crypto_airswap") {
+			report(path+".routes", "the routes are not supported by %s", p.Name)
+		}
+		for j, route := range p.Routes {
+			routeField := "routes[" + strconv.Itoa(j) + "]"
+			if route.Symbol == "" {
+				report(path+"."+routeField+".symbol", "the symbol of the route is required")
+			}
+			if len(route.Path) < 2 {
+				report(path+"."+routeField+".path", "at least two token addresses of the route are required")
+			}
+			for k, token := range route.Path {
+				addresses = append(addresses, struct{ field, value string }{routeField + ".path[" + strconv.Itoa(k) + "]", token})
+			}
+		}
+		for j, call := range p.Calls {
+			callField := "calls[" + strconv.Itoa(j) + "]"
+			if call.Symbol == "" {
+				report(path+"."+callField+".symbol", "the symbol of the call is required")
+			}
+			if call.Contract == "" {
+				report(path+"."+callField+".contract", "the contract address of the call is required")
+			}
+			if call.ABI == "" {
+				report(path+"."+callField+".abi", "the ABI fragment of the view method is required")
+			}
+			if call.MaxAge < 0 {
+				report(path+"."+callField+".maxAge", "%d cannot be negative", call.MaxAge)
+			}
+			if call.MaxAge > 0 && call.UpdatedAt == "" {
+				report(path+"."+callField+".maxAge", "the updatedAt output field is required to check the max age")
+			}
+			addresses = append(addresses, struct{ field, value string }{callField + ".contract", call.Contract})
+		}
+		for j, symbol := range p.Symbols {
+			if parts := strings.Split(symbol, "-"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				report(path+".symbols["+strconv.Itoa(j)+"]", "%q is not a symbol of BASE-QUOTE", symbol)
+			}
+		}
+		if r := p.REST; r.Path != "" || len(r.Symbols) > 0 {
+			if r.Path == "" {
+				report(path+".rest.path", "the URL template of the REST API is required")
+			}
+			if r.Price == "" {
+				report(path+".rest.price", "the JSONPath of the price is required")
+			}
+			if r.Mode != "" && r.Mode != RESTModeSymbol && r.Mode != RESTModeBatch {
+				report(path+".rest.mode", "%q is not a supported request mode, use %s or %s", r.Mode, RESTModeSymbol, RESTModeBatch)
+			}
+			if r.AuthHeader != "" && r.AuthQuery != "" {
+				report(path+".rest.authQuery", "the API key is placed in either the header or the query, not both")
+			}
+			if len(r.Symbols) == 0 {
+				report(path+".rest.symbols", "at least one symbol is required")
+			}
+			for j, symbol := range r.Symbols {
+				if symbol.Symbol == "" {
+					report(path+".rest.symbols["+strconv.Itoa(j)+"].symbol", "the symbol is required")
+				}
+			}
+		}
+		for _, a := range addresses {
+			if a.value != "" && !common.IsHexAddress(a.value) {
+				report(path+"."+a.field, "%q is not a valid hex address", a.value)
+			}
+		}
+	}
+
+	l := c.Logging
+	if l.Format != LogFormatText && l.Format != LogFormatJSON {
+		report("logging.format", "%q is not a supported format, use %s or %s", l.Format, LogFormatText, LogFormatJSON)
+	}
+	levels := map[string]int{"logging.levels.server": l.Levels.Server, "logging.levels.monitor": l.Levels.Monitor}
+	for name, level := range l.Levels.Plugins {
+		levels["logging.levels.plugins."+name] = level
+	}
+	for path, level := range levels {
+		if level < 0 || level > maxLogVerbosity {
+			report(path, "%d is out of range [0, %d]", level, maxLogVerbosity)
+		}
+	}
+	// the sizes, the intervals and the counts of the logging, the journal, the penalty and the staleness sections.
+	nonNegative := map[string]int{"logging.maxSizeMB": l.MaxSizeMB, "logging.rotationHours": l.RotationHours,
+		"logging.maxBackups": l.MaxBackups, "logging.maxAgeDays": l.MaxAgeDays,
+		"journal.maxSizeMB": c.Journal.MaxSizeMB, "journal.retentionDays": c.Journal.RetentionDays,
+		"penalty.recoveryRounds": c.Penalty.RecoveryRounds, "staleness.maxAge": c.Staleness.MaxAge}
+	for symbol, v := range c.Staleness.Symbols {
+		nonNegative["staleness.symbols."+symbol] = v
+	}
+	for path, v := range nonNegative {
+		if v < 0 {
+			report(path, "%d cannot be negative", v)
+		}
+	}
+
+	decays := map[string]DecayConfig{"fallback.decay": c.Fallback.Decay}
+	for symbol, d := range c.Fallback.Symbols {
+		decays["fallback.symbols."+symbol] = d
+	}
+	for path, d := range decays {
+		if d.Curve != DecayCurveStep && d.Curve != DecayCurveLinear && d.Curve != DecayCurveExponential {
+			report(path+".curve", "%q is not a supported curve, use %s, %s or %s", d.Curve, DecayCurveStep,
+				DecayCurveLinear, DecayCurveExponential)
+		}
+		if d.Period < 1 {
+			report(path+".period", "%d should be at least 1", d.Period)
+		}
+		if d.MaxAge < 0 {
+			report(path+".maxAge", "%d cannot be negative", d.MaxAge)
+		}
+	}
+
+	if c.Penalty.Policy != PenaltyPolicyVote && c.Penalty.Policy != PenaltyPolicySymbol {
+		report("penalty.policy", "%q is not a supported policy, use %s or %s", c.Penalty.Policy, PenaltyPolicyVote,
+			PenaltyPolicySymbol)
+	}
+	if c.Penalty.Suppression != SuppressionInvalid && c.Penalty.Suppression != SuppressionLowConfidence {
+		report("penalty.suppression", "%q is not a supported suppression, use %s or %s", c.Penalty.Suppression,
+			SuppressionInvalid, SuppressionLowConfidence)
+	}
+
+	m := c.MetricConfigs
+	if m.EnableInfluxDB && m.EnableInfluxDBV2 {
+		report("metricConfigs.enableInfluxDBV2", "there are two metrics engine enabled, please select one: influxDB or influxDBV2")
+	}
+	if m.EnableInfluxDB || m.EnableInfluxDBV2 {
+		if err := checkURL(m.InfluxDBEndpoint, influxDBSchemes); err != nil {
+			report("metricConfigs.influxDBEndpoint", "%s", err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			if errs[i].Line != errs[j].Line {
+				return errs[i].Line < errs[j].Line
+			}
+			return errs[i].Path < errs[j].Path
+		})
+		return errs
+	}
+	return nil
+}
+
+// CheckPluginDir cross-checks the plugin configs against the plugin binaries in the plugin directory, a config of an
+// enabled plugin without a binary is most likely a typo of the plugin name.
+func (c *ServerConfig) CheckPluginDir() error {
+	binaries, err := helpers.ListPlugins(c.PluginDIR)
+	if err != nil {
+		return FieldErrors{{Line: c.lines["pluginDir"], Path: "pluginDir", Msg: fmt.Sprintf("cannot list plugins: %s", err.Error())}}
+	}
+
+	var errs FieldErrors
+	for i, p := range c.PluginConfigs {
+		if p.Disabled || p.Name == "" {
+			continue
+		}
+		if _, ok := binaries[p.Name]; !ok {
+			path := "pluginConfigs[" + strconv.Itoa(i) + "].name"
+			errs = append(errs, &FieldError{Line: c.lines[path], Path: path,
+				Msg: fmt.Sprintf("no executable plugin binary %s in plugin directory %s", p.Name, c.PluginDIR)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkURL(rawURL string, schemes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL: %s", rawURL, err.Error())
+	}
+	if !contains(schemes, u.Scheme) {
+		return fmt.Errorf("%q has an unsupported scheme, use one of: %s", rawURL, strings.Join(schemes, ", "))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", rawURL)
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
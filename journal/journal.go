@@ -0,0 +1,233 @@
+package journal
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/logging"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the journal file in the profile directory, the rotated files are suffixed with their rotation
+// time.
+const FileName = "round_journal.jsonl"
+
+const megaBytes = 1024 * 1024
+
+// Kind is the type of journal record, a round is journaled by a vote record on the voting, followed by a receipt
+// record once the vote tx is mined, and an outcome record once the on-chain round data which aggregates the reports of
+// the vote is finalized.
+type Kind string
+
+const (
+	KindVote    Kind = "vote"
+	KindReceipt Kind = "receipt"
+	KindOutcome Kind = "outcome"
+	KindPenalty Kind = "penalty"
+)
+
+// Record is a line of the journal.
+type Record struct {
+	Kind     Kind      `json:"kind"`
+	Round    uint64    `json:"round"`
+	LoggedAt time.Time `json:"loggedAt"`
+
+	Vote    *Vote    `json:"vote,omitempty"`
+	Receipt *Receipt `json:"receipt,omitempty"`
+	Outcome *Outcome `json:"outcome,omitempty"`
+	Penalty *Penalty `json:"penalty,omitempty"`
+}
+
+// Vote records the inputs and the outputs of the round vote: the samples of the plugins, the aggregation, the
+// commitment of the current round and the tx which reveals the reports of the last round.
+type Vote struct {
+	SampleTS     int64   `json:"sampleTS"`     // the round's target sampling TS.
+	SampleHeight uint64  `json:"sampleHeight"` // the height on which the round rotation happens.
+	SampleEvents []int64 `json:"sampleEvents"` // the TS of the sample events sent to the plugins for this round.
+
+	Plugins []PluginSamples `json:"plugins"`
+	Symbols []SymbolReport  `json:"symbols"`
+
+	MissingData    bool        `json:"missingData"`
+	CommitmentHash common.Hash `json:"commitmentHash"`
+	// SaltHash references the salt of the commitment without disclosing it before the reveal, the salt is revealed
+	// on-chain by the vote of the next round.
+	SaltHash           common.Hash `json:"saltHash"`
+	RevealRound        uint64      `json:"revealRound,omitempty"` // the round of which the reports are revealed by this vote, 0 for none.
+	TxHash             common.Hash `json:"txHash,omitempty"`
+	Error              string      `json:"error,omitempty"`
+	ConfidenceStrategy int         `json:"confidenceStrategy"`
+
+	// the aggregation settings of the round, they are taken by the replay of the round.
+	Staleness       config.StalenessConfig `json:"staleness"`
+	PluginStaleness map[string]int         `json:"pluginStaleness,omitempty"` // the max staleness by plugin.
+	Fallback        config.FallbackConfig  `json:"fallback"`
+	Penalty         config.PenaltyConfig   `json:"penalty"`
+}
+
+// PluginSamples records the raw samples and the aggregated price per symbol of a plugin.
+type PluginSamples struct {
+	Plugin     string              `json:"plugin"`
+	SourceType int                 `json:"sourceType"` // 0: AMM, 1: CEX, 2: AFQ
+	Samples    map[string][]Sample `json:"samples"`
+	Aggregated map[string]Sample   `json:"aggregated"`
+}
+
+// Sample is a data point of a symbol keyed by the TS of the sample event.
+type Sample struct {
+	TS     int64           `json:"ts"`
+	Price  decimal.Decimal `json:"price"`
+	Volume *big.Int        `json:"volume,omitempty"`
+
+	// the provenance of the sample on the data source side.
+	SourceTS int64            `json:"sourceTS,omitempty"`
+	Venue    string           `json:"venue,omitempty"`
+	Bid      *decimal.Decimal `json:"bid,omitempty"`
+	Ask      *decimal.Decimal `json:"ask,omitempty"`
+}
+
+// SymbolReport records the final report of a protocol symbol.
+type SymbolReport struct {
+	Symbol     string          `json:"symbol"`
+	Price      decimal.Decimal `json:"price"`
+	Report     *big.Int        `json:"report"` // the price scaled by the oracle decimals, as reported on-chain.
+	Confidence uint8           `json:"confidence"`
+	Sources    []string        `json:"sources"` // the plugins which contributed samples, or the symbols it is derived from.
+	Missing    bool            `json:"missing"`
+	// the suppression state of a symbol penalized as an outlier: suppressed or recovering, it is empty if not suppressed.
+	Suppression   string `json:"suppression,omitempty"`
+	HealthyRounds int    `json:"healthyRounds,omitempty"` // the healthy rounds of a recovering symbol.
+}
+
+// Receipt records the result of a mined vote tx.
+type Receipt struct {
+	TxHash      common.Hash `json:"txHash"`
+	BlockNumber uint64      `json:"blockNumber"`
+	GasUsed     uint64      `json:"gasUsed"`
+	Status      uint64      `json:"status"` // 1: success, 0: failure.
+}
+
+// Outcome records the on-chain round data that aggregates the reports revealed in that round, the reports were
+// committed by the vote of the previous round, i.e. CommitRound.
+type Outcome struct {
+	CommitRound uint64          `json:"commitRound"`
+	Symbols     []SymbolOutcome `json:"symbols"`
+	// the partial reveal of the committed reports is not counted by the oracle contract.
+	PartialRevealRejected bool `json:"partialRevealRejected,omitempty"`
+}
+
+// SymbolOutcome compares the on-chain aggregated price of a symbol with our report.
+type SymbolOutcome struct {
+	Symbol   string   `json:"symbol"`
+	Price    *big.Int `json:"price"`
+	Success  bool     `json:"success"`
+	Reported *big.Int `json:"reported,omitempty"`
+	Error    string   `json:"error,omitempty"` // the error of reading the round data, the outcome of the symbol is unknown.
+}
+
+// Penalty records the outlier penalty of the oracle server.
+type Penalty struct {
+	Symbol      string   `json:"symbol"`
+	Median      *big.Int `json:"median"`
+	Reported    *big.Int `json:"reported"`
+	BlockNumber uint64   `json:"blockNumber"`
+}
+
+// Writer appends the records to the journal file, each record is flushed to disk once it is appended.
+type Writer struct {
+	lock sync.Mutex
+	file *logging.RotatingWriter
+	now  func() time.Time
+}
+
+// NewWriter opens the journal in the directory, the journal is rotated by size and the rotated files are kept for the
+// retention period, 0 disables the rotation or keeps the rotated files forever.
+func NewWriter(dir string, maxSizeMB, retentionDays int) (*Writer, error) {
+	file, err := logging.NewRotatingWriter(filepath.Join(dir, FileName), int64(maxSizeMB)*megaBytes, 0, 0,
+		time.Duration(retentionDays)*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{file: file, now: time.Now}, nil
+}
+
+// Append writes the record as a JSON line, the record's log time is set if it is not presented.
+func (w *Writer) Append(r *Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if r.LoggedAt.IsZero() {
+		r.LoggedAt = w.now().UTC()
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("cannot encode journal record: %v", err)
+	}
+	if _, err = w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("cannot write journal record: %v", err)
+	}
+	return w.file.Sync()
+}
+
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Read reads all the records of the journal in the directory, from the oldest rotated file to the current one.
+func Read(dir string) ([]*Record, error) {
+	path := filepath.Join(dir, FileName)
+	files, err := logging.Backups(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+
+	var records []*Record
+	for _, f := range files {
+		rs, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+	}
+	return records, nil
+}
+
+func readFile(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Decode decodes the JSON lines of journal records.
+func Decode(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*megaBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("line %d: cannot decode journal record: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
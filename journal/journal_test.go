@@ -0,0 +1,78 @@
+package journal
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, 0, 0)
+	require.NoError(t, err)
+	defer w.Close() //nolint
+
+	vote := &Vote{
+		SampleTS:     1700000000,
+		SampleEvents: []int64{1699999995, 1699999996},
+		Plugins: []PluginSamples{{
+			Plugin:     "crypto_uniswap",
+			Samples:    map[string][]Sample{"NTN-USDC": {{TS: 1699999995, Price: decimal.RequireFromString("1.01"), Volume: big.NewInt(10)}}},
+			Aggregated: map[string]Sample{"NTN-USDC": {TS: 1700000000, Price: decimal.RequireFromString("1.01"), Volume: big.NewInt(10)}},
+		}},
+		Symbols: []SymbolReport{{Symbol: "NTN-USD", Price: decimal.RequireFromString("1.01"), Report: big.NewInt(1010000000000000000),
+			Confidence: 100, Sources: []string{"NTN-USDC/crypto_uniswap", "USDC-USD/crypto_coinbase"}}},
+		CommitmentHash: common.HexToHash("0x01"),
+		TxHash:         common.HexToHash("0x02"),
+	}
+	require.NoError(t, w.Append(&Record{Kind: KindVote, Round: 10, Vote: vote}))
+	require.NoError(t, w.Append(&Record{Kind: KindReceipt, Round: 10, Receipt: &Receipt{TxHash: vote.TxHash, GasUsed: 21000, Status: 1}}))
+
+	// the records of the rotated journals are read in order.
+	require.NoError(t, w.file.Rotate())
+	require.NoError(t, w.Append(&Record{Kind: KindOutcome, Round: 11, Outcome: &Outcome{CommitRound: 10,
+		Symbols: []SymbolOutcome{{Symbol: "NTN-USD", Price: big.NewInt(1000000000000000000), Success: true}}}}))
+
+	records, err := Read(dir)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, []Kind{KindVote, KindReceipt, KindOutcome}, []Kind{records[0].Kind, records[1].Kind, records[2].Kind})
+	require.False(t, records[0].LoggedAt.IsZero())
+	require.Equal(t, vote.Symbols, records[0].Vote.Symbols)
+	require.Equal(t, vote.Plugins, records[0].Vote.Plugins)
+	require.Equal(t, uint64(10), records[2].Outcome.CommitRound)
+
+	_, err = Decode(strings.NewReader("{\"kind\":\"vote\"}\n{broken\n"))
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, 1, 1)
+	require.NoError(t, err)
+	defer w.Close() //nolint
+
+	// records are rotated by size.
+	symbols := make([]SymbolReport, 10000)
+	for i := range symbols {
+		symbols[i] = SymbolReport{Symbol: "EUR-USD", Report: big.NewInt(int64(i))}
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.Append(&Record{Kind: KindVote, Round: uint64(i), Vote: &Vote{Symbols: symbols}}))
+	}
+	backups, err := w.file.Backups()
+	require.NoError(t, err)
+	require.NotEmpty(t, backups)
+
+	records, err := Read(dir)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for i, r := range records {
+		require.Equal(t, uint64(i), r.Round)
+		require.True(t, r.LoggedAt.Before(time.Now().Add(time.Second)))
+	}
+}
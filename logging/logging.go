@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"autonity-oracle/config"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/go-hclog"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// The consistent keys of the log fields, thus the logs can be parsed downstream.
+const (
+	KeyRound     = "round"
+	KeySymbol    = "symbol"
+	KeyPlugin    = "plugin"
+	KeyTxHash    = "txHash"
+	KeyComponent = "component"
+	KeyError     = "error"
+)
+
+// The components which have their own log levels, the plugins' log levels are configured by their names.
+const (
+	ComponentServer  = "server"
+	ComponentMonitor = "monitor"
+	ComponentPlugin  = "plugin"
+)
+
+const megaBytes = 1024 * 1024
+
+// registry keeps the loggers of the components, thus their levels can be changed on runtime.
+type registry struct {
+	lock sync.Mutex
+
+	output io.Writer
+	mutex  *sync.Mutex // the mutex shared by all the loggers that write into the output.
+	json   bool
+	file   *RotatingWriter
+
+	baseLevel hclog.Level
+	levels    config.LogLevels
+
+	servers  []hclog.Logger
+	monitors []hclog.Logger
+	plugins  map[string]hclog.Logger
+}
+
+var root = &registry{
+	output:    os.Stdout,
+	mutex:     new(sync.Mutex),
+	baseLevel: hclog.Info,
+	plugins:   make(map[string]hclog.Logger),
+}
+
+// Setup configures the format, the sinks and the levels of the loggers to be created. It is called once on startup,
+// before the creation of the components.
+func Setup(level hclog.Level, conf *config.LoggingConfig) error {
+	root.lock.Lock()
+	defer root.lock.Unlock()
+
+	output := io.Writer(os.Stdout)
+	var file *RotatingWriter
+	if conf.File != "" {
+		var err error
+		file, err = NewRotatingWriter(conf.File, int64(conf.MaxSizeMB)*megaBytes, time.Duration(conf.RotationHours)*time.Hour,
+			conf.MaxBackups, time.Duration(conf.MaxAgeDays)*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		output = file
+	}
+
+	if root.file != nil {
+		root.file.Close() //nolint
+	}
+	root.output = output
+	root.file = file
+	root.json = conf.Format == config.LogFormatJSON
+	root.baseLevel = level
+	root.levels = conf.Levels
+
+	// the go-ethereum logs are mainly from the system monitor.
+	geth := root.newLogger(ComponentMonitor, ComponentMonitor, root.componentLevel(ComponentMonitor, ""))
+	root.monitors = append(root.monitors, geth)
+	log.Root().SetHandler(GethHandler(geth))
+	return nil
+}
+
+// SetLevels updates the levels of all the created loggers on-the-fly.
+func SetLevels(level hclog.Level, levels config.LogLevels) {
+	root.lock.Lock()
+	defer root.lock.Unlock()
+
+	root.baseLevel = level
+	root.levels = levels
+	for _, l := range root.servers {
+		l.SetLevel(root.componentLevel(ComponentServer, ""))
+	}
+	for _, l := range root.monitors {
+		l.SetLevel(root.componentLevel(ComponentMonitor, ""))
+	}
+	for name, l := range root.plugins {
+		l.SetLevel(root.componentLevel(ComponentPlugin, name))
+	}
+}
+
+// Close closes the log file if there is one, the logs are written to stdout since then.
+func Close() error {
+	root.lock.Lock()
+	defer root.lock.Unlock()
+	root.output = os.Stdout
+	if root.file == nil {
+		return nil
+	}
+	err := root.file.Close()
+	root.file = nil
+	return err
+}
+
+// Server creates a logger for the oracle server.
+func Server(name string) hclog.Logger {
+	root.lock.Lock()
+	defer root.lock.Unlock()
+	l := root.newLogger(ComponentServer, name, root.componentLevel(ComponentServer, ""))
+	root.servers = append(root.servers, l)
+	return l
+}
+
+// Plugin creates a logger for the plugin wrapper, the logs of the plugin process are forwarded by this logger too. The
+// logger replaces the one created by the previous instance of the same plugin.
+func Plugin(name string) hclog.Logger {
+	root.lock.Lock()
+	defer root.lock.Unlock()
+	l := root.newLogger(ComponentPlugin, name, root.componentLevel(ComponentPlugin, name)).With(KeyPlugin, name)
+	root.plugins[name] = l
+	return l
+}
+
+func (r *registry) newLogger(component, name string, level hclog.Level) hclog.Logger {
+	l := hclog.New(&hclog.LoggerOptions{
+		Name:       name,
+		Level:      level,
+		Output:     r.output,
+		Mutex:      r.mutex,
+		JSONFormat: r.json,
+	})
+	if r.json {
+		return l.With(KeyComponent, component)
+	}
+	return l
+}
+
+func (r *registry) componentLevel(component, plugin string) hclog.Level {
+	level := 0
+	switch component {
+	case ComponentServer:
+		level = r.levels.Server
+	case ComponentMonitor:
+		level = r.levels.Monitor
+	case ComponentPlugin:
+		level = r.levels.Plugins[plugin]
+	}
+	if level == 0 {
+		return r.baseLevel
+	}
+	return hclog.Level(level) //nolint
+}
+
+// GethHandler forwards the records of the go-ethereum logger to the hclog logger.
+func GethHandler(logger hclog.Logger) log.Handler {
+	return log.FuncHandler(func(r *log.Record) error {
+		switch r.Lvl {
+		case log.LvlCrit, log.LvlError:
+			logger.Error(r.Msg, r.Ctx...)
+		case log.LvlWarn:
+			logger.Warn(r.Msg, r.Ctx...)
+		case log.LvlInfo:
+			logger.Info(r.Msg, r.Ctx...)
+		case log.LvlDebug:
+			logger.Debug(r.Msg, r.Ctx...)
+		default:
+			logger.Trace(r.Msg, r.Ctx...)
+		}
+		return nil
+	})
+}
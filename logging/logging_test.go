@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"autonity-oracle/config"
+	"bytes"
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	t.Run("rotate by size and keep max backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "oracle.log")
+		w, err := NewRotatingWriter(path, 10, 0, 2, 0)
+		require.NoError(t, err)
+		defer w.Close() //nolint
+
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		w.now = func() time.Time { return now }
+		for i := 0; i < 4; i++ {
+			now = now.Add(time.Second)
+			_, err = w.Write([]byte("0123456789"))
+			require.NoError(t, err)
+		}
+
+		backups, err := w.Backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		require.Equal(t, path+".20240101T000004.000", backups[1])
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(data))
+	})
+
+	t.Run("rotate by interval and prune by age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "oracle.log")
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		w, err := NewRotatingWriter(path, 0, time.Hour, 0, 2*time.Hour)
+		require.NoError(t, err)
+		defer w.Close() //nolint
+		w.now = func() time.Time { return now }
+		w.openedAt = now
+
+		_, err = w.Write([]byte("first"))
+		require.NoError(t, err)
+		now = now.Add(30 * time.Minute)
+		_, err = w.Write([]byte("second"))
+		require.NoError(t, err)
+		backups, err := w.Backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 0)
+
+		now = now.Add(time.Hour)
+		_, err = w.Write([]byte("third"))
+		require.NoError(t, err)
+		backups, err = w.Backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+
+		// the first backup is expired on the next rotation.
+		now = now.Add(3 * time.Hour)
+		_, err = w.Write([]byte("fourth"))
+		require.NoError(t, err)
+		backups, err = w.Backups()
+		require.NoError(t, err)
+		require.Equal(t, []string{path + ".20240101T043000.000"}, backups)
+	})
+}
+
+func TestLevels(t *testing.T) {
+	var out bytes.Buffer
+	root.output = &out
+	root.json = true
+	defer func() {
+		root.output = os.Stdout
+		root.json = false
+		SetLevels(hclog.Info, config.LogLevels{})
+	}()
+
+	SetLevels(hclog.Warn, config.LogLevels{Server: int(hclog.Debug), Plugins: map[string]int{"forex_currencyfreaks": int(hclog.Trace)}})
+	server := Server("server")
+	plugin := Plugin("forex_currencyfreaks")
+	other := Plugin("forex_openexchange")
+
+	server.Debug("vote", KeyRound, 10)
+	plugin.Trace("fetch", KeySymbol, "EUR-USD")
+	other.Info("dropped")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, ComponentServer, record[KeyComponent])
+	require.Equal(t, float64(10), record[KeyRound])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	require.Equal(t, ComponentPlugin, record[KeyComponent])
+	require.Equal(t, "forex_currencyfreaks", record[KeyPlugin])
+	require.Equal(t, "EUR-USD", record[KeySymbol])
+
+	// the levels are changed on-the-fly.
+	out.Reset()
+	SetLevels(hclog.Info, config.LogLevels{})
+	server.Debug("dropped")
+	other.Info("kept")
+	require.Contains(t, out.String(), "kept")
+	require.NotContains(t, out.String(), "dropped")
+}
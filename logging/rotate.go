@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingWriter is a file writer which rotates the file once it exceeds the max size or once the rotation interval is
+// passed. The rotated files are renamed with the rotation time as a suffix, and they are pruned by count and by age.
+type RotatingWriter struct {
+	lock sync.Mutex
+
+	path       string
+	maxSize    int64         // 0 disables the size based rotation.
+	interval   time.Duration // 0 disables the time based rotation.
+	maxBackups int           // 0 keeps all the rotated files.
+	maxAge     time.Duration // 0 keeps the rotated files forever.
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func NewRotatingWriter(path string, maxSize int64, interval time.Duration, maxBackups int, maxAge time.Duration) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+		now:        time.Now,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0774); err != nil {
+		return nil, fmt.Errorf("cannot create log directory: %v", err)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes p into the file, the file is rotated before the write if p exceeds the max size or the rotation
+// interval is passed.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file on demand.
+func (w *RotatingWriter) Rotate() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.rotate()
+}
+
+// Sync commits the written data of the current file to the disk.
+func (w *RotatingWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.file.Sync()
+}
+
+func (w *RotatingWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotatingWriter) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && w.size+n > w.maxSize {
+		return true
+	}
+	return w.interval > 0 && w.now().Sub(w.openedAt) >= w.interval
+}
+
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot stat log file: %v", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = w.now()
+	return nil
+}
+
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	backup := w.path + "." + w.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot rotate log file: %v", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.prune()
+}
+
+// Backups returns the rotated files from the oldest to the newest.
+func (w *RotatingWriter) Backups() ([]string, error) {
+	return Backups(w.path)
+}
+
+// Backups returns the rotated files of the file path from the oldest to the newest.
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	prefix := filepath.Base(path) + "."
+	for _, m := range matches {
+		if _, err = time.Parse(backupTimeFormat, strings.TrimPrefix(filepath.Base(m), prefix)); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	// the timestamp suffix sorts in the time order.
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (w *RotatingWriter) prune() error {
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+
+	var expired []string
+	if w.maxBackups > 0 && len(backups) > w.maxBackups {
+		expired = append(expired, backups[:len(backups)-w.maxBackups]...)
+		backups = backups[len(backups)-w.maxBackups:]
+	}
+
+	if w.maxAge > 0 {
+		prefix := filepath.Base(w.path) + "."
+		for _, b := range backups {
+			rotatedAt, _ := time.Parse(backupTimeFormat, strings.TrimPrefix(filepath.Base(b), prefix))
+			if w.now().UTC().Sub(rotatedAt) > w.maxAge {
+				expired = append(expired, b)
+			}
+		}
+	}
+
+	for _, e := range expired {
+		if err = os.Remove(e); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove expired log file: %v", err)
+		}
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"autonity-oracle/config"
 	contract "autonity-oracle/contract_binder/contract"
+	"autonity-oracle/logging"
 	"autonity-oracle/monitor"
 	"autonity-oracle/oracle_server"
+	pluginprobe "autonity-oracle/plugin_probe"
+	"autonity-oracle/replay"
 	"autonity-oracle/types"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
@@ -15,7 +18,20 @@ import (
 )
 
 func main() { //nolint
+	if len(os.Args) > 2 && os.Args[1] == "plugin" && os.Args[2] == "probe" {
+		os.Exit(probePlugin(os.Args[3:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replayRounds(os.Args[2:]))
+	}
+
 	conf := config.MakeConfig()
+	if err := logging.Setup(conf.LoggingLevel, &conf.Logging); err != nil {
+		log.Printf("cannot setup logging: %s", err.Error())
+		os.Exit(1)
+	}
+	defer logging.Close() //nolint
+
 	log.Printf("\n\n\n \tRunning autonity oracle server %s\n\twith plugin directory: %s\n "+
 		"\tby connecting to L1 node: %s\n \ton oracle contract address: %s \n\n\n",
 		config.VersionString(config.Version), conf.PluginDIR, conf.AutonityWSUrl, types.OracleContractAddress)
@@ -78,7 +94,57 @@ func main() { //nolint
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be caught, so don't need to add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// SIGHUP reloads the runtime safe settings from the config file.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			oracle.ReloadConfig()
+		}
+	}()
+
 	<-quit
 	ms.Stop()
 	log.Println("shutting down oracle server...")
 }
+
+// probePlugin runs a single plugin in isolation without the connectivity of the Autonity L1 network.
+func probePlugin(args []string) int {
+	log.SetFlags(0)
+	conf, err := pluginprobe.MakeConfig(args)
+	if err != nil {
+		log.Printf("cannot resolve plugin probe config: %s", err.Error())
+		return 1
+	}
+
+	done := make(chan struct{})
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-quit
+		close(done)
+	}()
+
+	if err = pluginprobe.New(conf, os.Stdout).Run(done); err != nil {
+		log.Printf("plugin probe failed: %s", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// replayRounds replays the recorded rounds of the round journal offline with alternative aggregation settings.
+func replayRounds(args []string) int {
+	log.SetFlags(0)
+	conf, err := replay.MakeConfig(args)
+	if err != nil {
+		log.Printf("cannot resolve replay config: %s", err.Error())
+		return 1
+	}
+
+	if err = replay.Run(conf, os.Stdout); err != nil {
+		log.Printf("replay failed: %s", err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/helpers"
+	"autonity-oracle/types"
+	"github.com/shopspring/decimal"
+	"math"
+	"math/big"
+)
+
+// sourceSample is the aggregated price of a symbol from a data source.
+type sourceSample struct {
+	Source string
+	types.Price
+}
+
+// computeConfidence resolves the confidence of a symbol by the confidence strategy from the samples of its sources.
+func (os *OracleServer) computeConfidence(symbol string, target int64, samples []sourceSample) uint8 {
+	if os.conf.ConfidenceStrategy == config.ConfidenceStrategyModel {
+		return ModelConfidence(symbol, target, samples, &os.conf.ConfidenceModel)
+	}
+	return ComputeConfidence(symbol, len(samples), os.conf.ConfidenceStrategy)
+}
+
+// ModelConfidence computes the confidence of a symbol of any asset class from the samples of its sources. Each source
+// i has a reliability r_i in [0, 1] from the config, 1 by default. The confidence is the product of four factors in
+// [0, 1] scaled to MaxConfidence, and it is clamped to [1, MaxConfidence] to keep the report valid:
+//
+//	coverage   = min(1, sum(r_i) / expectedSources)
+//	dispersion = 1 / (1 + (mad / dispersionScale)^2), mad is the median absolute deviation of the prices in percent of
+//	             the median price
+//	freshness  = sum(r_i * max(0, 1 - age_i / maxSampleAge)) / sum(r_i), age_i is the seconds from the source side
+//	             quote of the sample to the round timestamp
+//	volume     = min(1, sum(volume_i) / volumeTarget), it is 1 if the symbol has no volume target
+//
+// A single reliable and fresh source thus has 1/3 of the confidence with the default 3 expected sources, and the
+// dispersion of 0.5% between the sources halves the confidence with the default dispersion scale.
+func ModelConfidence(symbol string, target int64, samples []sourceSample, model *config.ConfidenceModelConfig) uint8 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var reliability, freshness float64
+	for _, s := range samples {
+		r := sourceReliability(s.Source, model)
+		reliability += r
+		freshness += r * sampleFreshness(s.SourceTime(), target, model.MaxSampleAge)
+	}
+	if reliability == 0 {
+		return minConfidence
+	}
+	freshness /= reliability
+
+	coverage := math.Min(1, reliability/float64(model.ExpectedSources))
+	confidence := float64(MaxConfidence) * coverage * priceDispersion(samples, model.DispersionScale) * freshness *
+		volumeFactor(symbol, samples, model.VolumeTargets)
+
+	if confidence < float64(minConfidence) {
+		return minConfidence
+	}
+	return uint8(math.Min(math.Floor(confidence), MaxConfidence))
+}
+
+func sourceReliability(source string, model *config.ConfidenceModelConfig) float64 {
+	if r, ok := model.Reliability[source]; ok {
+		return r
+	}
+	return 1
+}
+
+// sampleFreshness decays linearly from 1 for a sample taken at the round timestamp to 0 for a sample of maxAge.
+func sampleFreshness(sampleTS, target int64, maxAge int) float64 {
+	age := target - sampleTS
+	if age <= 0 {
+		return 1
+	}
+	return math.Max(0, 1-float64(age)/float64(maxAge))
+}
+
+// priceDispersion is the dispersion factor from the median absolute deviation of the prices, it is 1 for a single
+// source as there is no dispersion to be measured.
+func priceDispersion(samples []sourceSample, scale float64) float64 {
+	if len(samples) < 2 {
+		return 1
+	}
+
+	prices := samplePrices(samples)
+	median, err := helpers.Median(prices)
+	if err != nil || median.IsZero() {
+		return 0
+	}
+
+	deviations := make([]decimal.Decimal, len(prices))
+	for i, p := range prices {
+		deviations[i] = deviationPercent(p, median)
+	}
+	mad, err := helpers.Median(deviations)
+	if err != nil {
+		return 0
+	}
+
+	ratio := mad.InexactFloat64() / scale
+	return 1 / (1 + ratio*ratio)
+}
+
+func volumeFactor(symbol string, samples []sourceSample, targets map[string]float64) float64 {
+	target, ok := targets[symbol]
+	if !ok || target <= 0 {
+		return 1
+	}
+
+	total := new(big.Int)
+	for _, s := range samples {
+		if s.Volume != nil {
+			total.Add(total, s.Volume)
+		}
+	}
+	return math.Min(1, decimal.NewFromBigInt(total, 0).InexactFloat64()/target)
+}
+
+// bridgedConfidence scales the confidence of a bridged price with the confidence of the bridge price.
+func bridgedConfidence(confidence, bridgeConfidence uint8) uint8 {
+	scaled := uint8(uint64(confidence) * uint64(bridgeConfidence) / MaxConfidence)
+	if scaled < minConfidence {
+		return minConfidence
+	}
+	return scaled
+}
@@ -0,0 +1,64 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestModelConfidence(t *testing.T) {
+	target := int64(1000)
+	sample := func(source, price string, age int64, volume int64) sourceSample {
+		return sourceSample{Source: source, Price: types.Price{Timestamp: target - age,
+			Price: decimal.RequireFromString(price), Volume: big.NewInt(volume)}}
+	}
+	fresh := []sourceSample{sample("a", "100", 0, 10), sample("b", "100", 0, 20), sample("c", "100", 0, 20)}
+
+	model := config.DefaultConfidenceModelConfig
+	model.VolumeTargets = map[string]float64{"NTN-USDC": 100}
+	model.Reliability = map[string]float64{"unreliable": 0.5, "broken": 0}
+
+	tests := []struct {
+		name     string
+		symbol   string
+		samples  []sourceSample
+		expected uint8
+	}{
+		{"no sample", "EUR-USD", nil, 0},
+		{"fresh and consistent sources", "EUR-USD", fresh, MaxConfidence},
+		{"single source covers 1/3", "EUR-USD", fresh[:1], 33},
+		{"dispersion of the scale halves", "EUR-USD", []sourceSample{sample("a", "99.5", 0, 1),
+			sample("b", "100", 0, 1), sample("c", "100.5", 0, 1)}, 50},
+		{"half aged sample", "EUR-USD", []sourceSample{sample("a", "100", 0, 1), sample("b", "100", 0, 1),
+			sample("c", "100", 30, 1)}, 83},
+		{"unreliable source", "EUR-USD", []sourceSample{sample("a", "100", 0, 1), sample("b", "100", 0, 1),
+			sample("unreliable", "100", 0, 1)}, 83},
+		{"crypto with half of the volume target", "NTN-USDC", fresh, 50},
+		{"stale samples take the lowest confidence", "EUR-USD", []sourceSample{sample("a", "100", 60, 1),
+			sample("b", "100", 90, 1), sample("c", "100", 120, 1)}, minConfidence},
+		{"broken source", "EUR-USD", []sourceSample{sample("broken", "100", 0, 1)}, minConfidence},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, ModelConfidence(tt.symbol, target, tt.samples, &model))
+		})
+	}
+}
+
+func TestComputeConfidenceStrategy(t *testing.T) {
+	srv := &OracleServer{conf: &config.Config{ConfidenceModel: config.DefaultConfidenceModelConfig}}
+	samples := []sourceSample{{Source: "a", Price: types.Price{Timestamp: 100, Price: decimal.NewFromInt(1)}}}
+
+	// crypto takes full confidence from a single source with the legacy strategies, but not with the model.
+	srv.conf.ConfidenceStrategy = config.ConfidenceStrategyLinear
+	require.Equal(t, uint8(MaxConfidence), srv.computeConfidence("NTN-USDC", 100, samples))
+	srv.conf.ConfidenceStrategy = config.ConfidenceStrategyModel
+	require.Equal(t, uint8(33), srv.computeConfidence("NTN-USDC", 100, samples))
+
+	require.Equal(t, uint8(40), bridgedConfidence(80, 50))
+	require.Equal(t, minConfidence, bridgedConfidence(1, 1))
+}
@@ -0,0 +1,163 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	o "os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+const (
+	configAuditLogFile = "config_audit.jsonl"
+	redactedValue      = "<redacted>"
+)
+
+const (
+	ReloadTriggerFSNotify = "fsnotify"
+	ReloadTriggerSIGHUP   = "SIGHUP"
+
+	ConfigChangeApplied         = "applied"
+	ConfigChangeRestartRequired = "restart-required"
+)
+
+// ConfigChange is the audit record of a server-level config field changed on a config reload.
+type ConfigChange struct {
+	At      string      `json:"at"`
+	Trigger string      `json:"trigger"`
+	Field   string      `json:"field"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+	Status  string      `json:"status"`
+}
+
+// ReloadConfig requests the oracle server to reload the server-level settings from the config file, it is called on
+// SIGHUP. The reload is applied by the main loop of the oracle server, thus it is not racing with the round voting.
+func (os *OracleServer) ReloadConfig() {
+	select {
+	case os.chReloadConfig <- struct{}{}:
+	default:
+		// a reload is already pending.
+	}
+}
+
+// handleConfigUpdate loads the config once for a config event, and applies it to the plugins, and to the server-level
+// settings if the config file is updated.
+func (os *OracleServer) handleConfigUpdate(trigger string, serverLevel bool) {
+	// the overrides of the CLI flags and the environment variables are re-applied on top of the config file.
+	newConf, err := config.LoadLayeredConfig(os.conf.ConfigFile, os.conf.Args)
+	if err != nil {
+		os.logger.Error("cannot reload config, keep running with the current one", "file", os.conf.ConfigFile, "error", err.Error())
+		return
+	}
+	if serverLevel {
+		os.reloadConfig(newConf, trigger)
+	}
+	os.managePlugins(newConf)
+}
+
+// reloadConfig re-applies the runtime safe fields of the config to the live server and its plugins' loggers, the
+// changes of those fields which require a restart are reported only. All the changes are appended to the audit log.
+func (os *OracleServer) reloadConfig(newConf *config.ServerConfig, trigger string) {
+	var changes []*ConfigChange
+	track := func(field string, oldValue, newValue interface{}, status string) bool {
+		if reflect.DeepEqual(oldValue, newValue) {
+			return false
+		}
+		changes = append(changes, &ConfigChange{Trigger: trigger, Field: field, Old: oldValue, New: newValue, Status: status})
+		return true
+	}
+
+	// runtime safe fields.
+	logLevelChanged := track("logLevel", int(os.conf.LoggingLevel), newConf.LoggingLevel, ConfigChangeApplied)
+	logLevelsChanged := track("logging.levels", os.conf.Logging.Levels, newConf.Logging.Levels, ConfigChangeApplied)
+	if logLevelChanged || logLevelsChanged {
+		os.conf.LoggingLevel = hclog.Level(newConf.LoggingLevel) //nolint
+		os.conf.Logging.Levels = newConf.Logging.Levels
+		logging.SetLevels(os.conf.LoggingLevel, os.conf.Logging.Levels)
+	}
+	if track("gasTipCap", os.conf.GasTipCap, newConf.GasTipCap, ConfigChangeApplied) {
+		os.conf.GasTipCap = newConf.GasTipCap
+	}
+	if track("voteBuffer", os.conf.VoteBuffer, newConf.VoteBuffer, ConfigChangeApplied) {
+		os.conf.VoteBuffer = newConf.VoteBuffer
+	}
+	if track("confidenceStrategy", os.conf.ConfidenceStrategy, newConf.ConfidenceStrategy, ConfigChangeApplied) {
+		os.conf.ConfidenceStrategy = newConf.ConfidenceStrategy
+	}
+	if track("confidenceModel", os.conf.ConfidenceModel, newConf.ConfidenceModel, ConfigChangeApplied) {
+		os.conf.ConfidenceModel = newConf.ConfidenceModel
+	}
+	if track("fallback", os.conf.Fallback, newConf.Fallback, ConfigChangeApplied) {
+		os.conf.Fallback = newConf.Fallback
+	}
+	if track("staleness", os.conf.Staleness, newConf.Staleness, ConfigChangeApplied) {
+		os.conf.Staleness = newConf.Staleness
+	}
+	if track("partialReveal", os.conf.PartialReveal, newConf.PartialReveal, ConfigChangeApplied) {
+		os.conf.PartialReveal = newConf.PartialReveal
+	}
+	if track("penalty", os.conf.Penalty, newConf.Penalty, ConfigChangeApplied) {
+		os.conf.Penalty = newConf.Penalty
+	}
+
+	// fields that require a restart, the key password is not kept in memory, thus only the key file is compared.
+	track("keyFile", os.conf.KeyFile, newConf.KeyFile, ConfigChangeRestartRequired)
+	track("autonityWSUrl", os.conf.AutonityWSUrl, newConf.AutonityWSUrl, ConfigChangeRestartRequired)
+	track("pluginDir", os.conf.PluginDIR, newConf.PluginDIR, ConfigChangeRestartRequired)
+	track("profileDir", os.conf.ProfileDir, newConf.ProfileDir, ConfigChangeRestartRequired)
+	track("logging.format", os.conf.Logging.Format, newConf.Logging.Format, ConfigChangeRestartRequired)
+	track("logging.file", os.conf.Logging.File, newConf.Logging.File, ConfigChangeRestartRequired)
+	track("logging.maxSizeMB", os.conf.Logging.MaxSizeMB, newConf.Logging.MaxSizeMB, ConfigChangeRestartRequired)
+	track("logging.rotationHours", os.conf.Logging.RotationHours, newConf.Logging.RotationHours, ConfigChangeRestartRequired)
+	track("logging.maxBackups", os.conf.Logging.MaxBackups, newConf.Logging.MaxBackups, ConfigChangeRestartRequired)
+	track("logging.maxAgeDays", os.conf.Logging.MaxAgeDays, newConf.Logging.MaxAgeDays, ConfigChangeRestartRequired)
+	track("journal.maxSizeMB", os.conf.Journal.MaxSizeMB, newConf.Journal.MaxSizeMB, ConfigChangeRestartRequired)
+	track("journal.retentionDays", os.conf.Journal.RetentionDays, newConf.Journal.RetentionDays, ConfigChangeRestartRequired)
+	// the metric configs carry the credentials of influxDB, thus their values are not logged.
+	if !reflect.DeepEqual(os.conf.MetricConfigs, newConf.MetricConfigs) {
+		changes = append(changes, &ConfigChange{Trigger: trigger, Field: "metricConfigs", Old: redactedValue,
+			New: redactedValue, Status: ConfigChangeRestartRequired})
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for _, c := range changes {
+		c.At = now
+		if c.Status == ConfigChangeApplied {
+			os.logger.Info("config change applied", "field", c.Field, "old", c.Old, "new", c.New, "trigger", trigger)
+		} else {
+			os.logger.Warn("config change requires a restart to take effect", "field", c.Field, "old", c.Old,
+				"new", c.New, "trigger", trigger)
+		}
+	}
+
+	if err := appendConfigAudit(os.conf.ProfileDir, changes); err != nil {
+		os.logger.Error("cannot write config audit log", "error", err.Error())
+	}
+}
+
+// appendConfigAudit appends the config changes into the audit log in the profile directory as JSON lines.
+func appendConfigAudit(profileDir string, changes []*ConfigChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fileName := filepath.Join(profileDir, configAuditLogFile)
+	file, err := o.OpenFile(fileName, o.O_CREATE|o.O_APPEND|o.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open config audit log: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, c := range changes {
+		if err = encoder.Encode(c); err != nil {
+			return fmt.Errorf("failed to encode config change: %v", err)
+		}
+	}
+	return nil
+}
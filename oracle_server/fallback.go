@@ -0,0 +1,98 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/logging"
+	"autonity-oracle/types"
+	"github.com/shopspring/decimal"
+	"math"
+	"math/big"
+)
+
+// stepTiers is the number of periods of the step curve after which the lowest confidence is taken.
+const stepTiers = 60
+
+// fallbackPrice resolves the price of a symbol without samples from the in-memory historic rounds, or optionally from
+// the on-chain latest round data, its confidence decays with its age by the decay curve of the symbol.
+func (os *OracleServer) fallbackPrice(symbol string, target int64) (*types.Price, error) {
+	price, err := os.queryHistoricRoundPrice(symbol)
+	if err != nil {
+		if !os.conf.Fallback.OnChain {
+			return nil, err
+		}
+		if price, err = os.queryOnChainPrice(symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	confidence := price.Confidence
+	decayed, err := decayedPrice(&price, target, os.conf.Fallback.SymbolDecay(symbol))
+	if err != nil {
+		return nil, err
+	}
+	if os.fallbacks != nil {
+		os.fallbacks[symbol] = confidence
+	}
+	return decayed, nil
+}
+
+// queryOnChainPrice queries the latest round price of a symbol from the oracle contract. The on-chain price carries no
+// confidence of ours, thus it takes the max confidence to be decayed by its age.
+func (os *OracleServer) queryOnChainPrice(symbol string) (types.Price, error) {
+	rd, err := os.oracleContract.LatestRoundData(nil, symbol)
+	if err != nil {
+		os.logger.Debug("cannot get latest round data for fallback", logging.KeySymbol, symbol, logging.KeyError, err)
+		return types.Price{}, err
+	}
+	if !rd.Success || rd.Price == nil || rd.Price.Cmp(invalidPrice) <= 0 {
+		return types.Price{}, types.ErrNoDataRound
+	}
+
+	return types.Price{
+		Timestamp:  rd.Timestamp.Int64(),
+		Symbol:     symbol,
+		Price:      decimal.NewFromBigInt(rd.Price, 0).Div(os.pricePrecision),
+		Volume:     new(big.Int).Set(types.DefaultVolume),
+		Confidence: MaxConfidence,
+	}, nil
+}
+
+// decayedPrice reduces the confidence of a historic price by its age to the target timestamp:
+//
+//	step:        full confidence under a period, the half under 60 periods, the lowest confidence 1 after that
+//	linear:      confidence * max(0, 1 - age / period)
+//	exponential: confidence * 0.5^(age / period)
+//
+// The price is not reported if it is older than the max age, or if its decayed confidence drops to 0.
+func decayedPrice(historicRoundPrice *types.Price, target int64, decay config.DecayConfig) (*types.Price, error) {
+	age := target - historicRoundPrice.Timestamp
+	if age < 0 {
+		age = 0
+	}
+	if decay.MaxAge > 0 && age > int64(decay.MaxAge) {
+		return nil, types.ErrNoAvailablePrice
+	}
+
+	period := float64(decay.Period)
+	confidence := float64(historicRoundPrice.Confidence)
+	switch decay.Curve {
+	case config.DecayCurveLinear:
+		confidence *= math.Max(0, 1-float64(age)/period)
+	case config.DecayCurveExponential:
+		confidence *= math.Pow(0.5, float64(age)/period)
+	default:
+		if age >= stepTiers*int64(decay.Period) {
+			confidence = float64(minConfidence)
+		} else if age >= int64(decay.Period) {
+			confidence = float64(historicRoundPrice.Confidence / 2)
+		}
+	}
+
+	reducedConfidence := uint8(math.Floor(confidence))
+	if reducedConfidence == 0 {
+		return nil, types.ErrNoAvailablePrice
+	}
+
+	historicRoundPrice.Confidence = reducedConfidence
+	return historicRoundPrice, nil
+}
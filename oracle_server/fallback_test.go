@@ -0,0 +1,136 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	contract "autonity-oracle/contract_binder/contract"
+	cMock "autonity-oracle/contract_binder/contract/mock"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestDecayedPrice(t *testing.T) {
+	target := int64(10000)
+	tests := []struct {
+		name     string
+		decay    config.DecayConfig
+		age      int64
+		expected uint8 // 0 for no price to be reported.
+	}{
+		{"step under a period", config.DefaultDecayConfig, 59, 80},
+		{"step under 60 periods", config.DefaultDecayConfig, 60, 40},
+		{"step after 60 periods", config.DefaultDecayConfig, 3600, 1},
+		{"linear", config.DecayConfig{Curve: config.DecayCurveLinear, Period: 100}, 25, 60},
+		{"linear to zero", config.DecayConfig{Curve: config.DecayCurveLinear, Period: 100}, 100, 0},
+		{"exponential half-life", config.DecayConfig{Curve: config.DecayCurveExponential, Period: 100}, 200, 20},
+		{"beyond max age", config.DecayConfig{Curve: config.DecayCurveStep, Period: 60, MaxAge: 300}, 301, 0},
+		{"future sample", config.DecayConfig{Curve: config.DecayCurveLinear, Period: 100}, -10, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := decayedPrice(&types.Price{Timestamp: target - tt.age, Confidence: 80}, target, tt.decay)
+			if tt.expected == 0 {
+				require.ErrorIs(t, err, types.ErrNoAvailablePrice)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, p.Confidence)
+		})
+	}
+}
+
+func TestFallbackPrice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	contractMock := cMock.NewMockContractAPI(ctrl)
+
+	srv := &OracleServer{
+		logger:         hclog.NewNullLogger(),
+		conf:           &config.Config{},
+		oracleContract: contractMock,
+		curRound:       10,
+		roundData:      make(map[uint64]*types.RoundData),
+		pricePrecision: decimal.NewFromBigInt(common.Big1, int32(OracleDecimals)),
+	}
+
+	// no local history and no on-chain fallback.
+	_, err := srv.fallbackPrice("EUR-USD", 1000)
+	require.ErrorIs(t, err, types.ErrNoDataRound)
+
+	// the on-chain latest round data is taken with the max confidence to be decayed.
+	srv.conf.Fallback.OnChain = true
+	srv.conf.Fallback.Symbols = map[string]config.DecayConfig{"EUR-USD": {Curve: config.DecayCurveLinear, Period: 100}}
+	contractMock.EXPECT().LatestRoundData(nil, "EUR-USD").Return(contract.IOracleRoundData{
+		Round:     big.NewInt(9),
+		Price:     decimal.RequireFromString("1.1").Mul(srv.pricePrecision).BigInt(),
+		Timestamp: big.NewInt(950),
+		Success:   true,
+	}, nil)
+	p, err := srv.fallbackPrice("EUR-USD", 1000)
+	require.NoError(t, err)
+	require.Equal(t, "1.1", p.Price.String())
+	require.Equal(t, uint8(50), p.Confidence)
+
+	contractMock.EXPECT().LatestRoundData(nil, "JPY-USD").Return(contract.IOracleRoundData{Success: false}, nil)
+	_, err = srv.fallbackPrice("JPY-USD", 1000)
+	require.ErrorIs(t, err, types.ErrNoDataRound)
+
+	// the local history takes precedence over the on-chain data.
+	srv.roundData[9] = &types.RoundData{Prices: types.PriceBySymbol{"JPY-USD": {Timestamp: 990, Symbol: "JPY-USD",
+		Price: decimal.RequireFromString("0.0067"), Confidence: 100}}}
+	p, err = srv.fallbackPrice("JPY-USD", 1000)
+	require.NoError(t, err)
+	require.Equal(t, uint8(100), p.Confidence)
+}
+
+func TestConsecutiveFallbackRounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		decay    config.DecayConfig
+		expected []uint8 // the confidences of the consecutive fallback rounds, 0 for no price to be reported.
+	}{
+		{"step", config.DecayConfig{Curve: config.DecayCurveStep, Period: 50}, []uint8{80, 40, 40, 40}},
+		{"linear", config.DecayConfig{Curve: config.DecayCurveLinear, Period: 100}, []uint8{60, 40, 20, 0}},
+		{"exponential", config.DecayConfig{Curve: config.DecayCurveExponential, Period: 25}, []uint8{40, 20, 10, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &OracleServer{
+				logger:    hclog.NewNullLogger(),
+				conf:      &config.Config{Fallback: config.FallbackConfig{Decay: tt.decay}},
+				curRound:  10,
+				roundData: make(map[uint64]*types.RoundData),
+			}
+			// the last live price of the symbol, the later rounds fall back to it every 25 seconds.
+			srv.roundData[9] = &types.RoundData{Prices: types.PriceBySymbol{"EUR-USD": {Timestamp: 1000,
+				Symbol: "EUR-USD", Price: decimal.RequireFromString("1.1"), Confidence: 80}}}
+
+			for i, expected := range tt.expected {
+				target := int64(1025 + 25*i)
+				srv.fallbacks = make(map[string]uint8)
+				p, err := srv.fallbackPrice("EUR-USD", target)
+				if expected == 0 {
+					require.ErrorIs(t, err, types.ErrNoAvailablePrice)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, expected, p.Confidence, "round %d", srv.curRound)
+				require.Equal(t, int64(1000), p.Timestamp)
+
+				// the fallback price is kept as the price of the round with its confidence before the decay, as the
+				// server does.
+				require.Equal(t, uint8(80), srv.fallbacks["EUR-USD"])
+				srv.roundData[srv.curRound] = &types.RoundData{Prices: types.PriceBySymbol{"EUR-USD": *p},
+					FallbackConfidence: srv.fallbacks}
+				srv.curRound++
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package oracleserver
+
+import (
+	"autonity-oracle/journal"
+	"autonity-oracle/logging"
+	common2 "autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"sort"
+)
+
+// historicSource is the source of a price which is taken from the historic rounds as there is no sample of it.
+const historicSource = "historic-round"
+
+func (os *OracleServer) appendJournal(r *journal.Record) {
+	if os.journal == nil {
+		return
+	}
+	if err := os.journal.Append(r); err != nil {
+		os.logger.Error("cannot append round journal", logging.KeyRound, r.Round, "kind", r.Kind, logging.KeyError, err)
+	}
+}
+
+// journalVote records the samples, the aggregated reports and the vote tx of a round, curRoundData is nil if there is
+// no commitment in the vote.
+func (os *OracleServer) journalVote(round uint64, curRoundData, lastRoundData *types.RoundData, tx *tp.Transaction, voteErr error) {
+	if os.journal == nil {
+		return
+	}
+
+	vote := &journal.Vote{
+		SampleTS:           os.curSampleTS,
+		SampleHeight:       os.curSampleHeight,
+		SampleEvents:       append([]int64(nil), os.sampleEvents...),
+		Plugins:            os.journalSamples(),
+		ConfidenceStrategy: os.conf.ConfidenceStrategy,
+		Staleness:          os.conf.Staleness,
+		Fallback:           os.conf.Fallback,
+		Penalty:            os.conf.Penalty,
+	}
+	for name, pConf := range os.conf.PluginConfigs {
+		if pConf.MaxStaleness > 0 {
+			if vote.PluginStaleness == nil {
+				vote.PluginStaleness = make(map[string]int)
+			}
+			vote.PluginStaleness[name] = pConf.MaxStaleness
+		}
+	}
+
+	if curRoundData != nil {
+		for i, s := range curRoundData.Symbols {
+			report := journal.SymbolReport{Symbol: s, Report: curRoundData.Reports[i].Price,
+				Confidence: curRoundData.Reports[i].Confidence}
+			if p, ok := curRoundData.Prices[s]; ok {
+				report.Price = p.Price
+				report.Sources = reportSources(s, curRoundData.Provenance)
+			}
+			report.Missing = report.Report.Cmp(invalidPrice) == 0
+			report.Suppression = os.suppressionState(s)
+			if report.Suppression == suppressionRecovering {
+				report.HealthyRounds = os.serverMemories.Suppressed[s].HealthyRounds
+			}
+			vote.Symbols = append(vote.Symbols, report)
+		}
+		vote.MissingData = curRoundData.MissingData
+		vote.CommitmentHash = curRoundData.CommitmentHash
+		vote.SaltHash = crypto.Keccak256Hash(common.LeftPadBytes(curRoundData.Salt.Bytes(), 32))
+	}
+
+	if lastRoundData != nil && tx != nil && lastRoundData.RevealTx == tx.Hash() {
+		vote.RevealRound = lastRoundData.RoundID
+	}
+	if tx != nil {
+		vote.TxHash = tx.Hash()
+		os.pendingVotes[tx.Hash()] = round
+	}
+	if voteErr != nil {
+		vote.Error = voteErr.Error()
+	}
+
+	os.appendJournal(&journal.Record{Kind: journal.KindVote, Round: round, Vote: vote})
+}
+
+// journalSamples collects the raw samples and the aggregated prices of the sampling symbols from all the plugins.
+func (os *OracleServer) journalSamples() []journal.PluginSamples {
+	names := make([]string, 0, len(os.runningPlugins))
+	for name := range os.runningPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var plugins []journal.PluginSamples
+	for _, name := range names {
+		plugin := os.runningPlugins[name]
+		ps := journal.PluginSamples{
+			Plugin:     name,
+			SourceType: int(plugin.Statement().DataSourceType),
+			Samples:    make(map[string][]journal.Sample),
+			Aggregated: make(map[string]journal.Sample),
+		}
+		for _, s := range os.samplingSymbols {
+			samples := plugin.Samples(s)
+			if len(samples) == 0 {
+				continue
+			}
+			for ts, p := range samples {
+				ps.Samples[s] = append(ps.Samples[s], journalSample(ts, &p))
+			}
+			sort.Slice(ps.Samples[s], func(i, j int) bool { return ps.Samples[s][i].TS < ps.Samples[s][j].TS })
+
+			if p, err := plugin.AggregatedPrice(s, os.curSampleTS); err == nil {
+				ps.Aggregated[s] = journalSample(p.Timestamp, &p)
+			}
+		}
+		plugins = append(plugins, ps)
+	}
+	return plugins
+}
+
+func journalSample(ts int64, p *types.Price) journal.Sample {
+	return journal.Sample{TS: ts, Price: p.Price, Volume: p.Volume, SourceTS: p.SourceTimestamp, Venue: p.Venue,
+		Bid: p.Bid, Ask: p.Ask}
+}
+
+// reportSources resolves the sources of an aggregated price from the provenance of the round: the plugins whose samples
+// of the symbol are aggregated, the symbol/plugin pairs of a derived price, or the historic rounds.
+func reportSources(s string, provenance map[string][]types.Provenance) []string {
+	switch s {
+	case ATNUSD:
+		return append(prefixSources(ATNUSDC, pluginSources(ATNUSDC, provenance)),
+			prefixSources(USDCUSD, pluginSources(USDCUSD, provenance))...)
+	case NTNUSD:
+		return append(prefixSources(NTNUSDC, pluginSources(NTNUSDC, provenance)),
+			prefixSources(USDCUSD, pluginSources(USDCUSD, provenance))...)
+	}
+
+	sources := pluginSources(s, provenance)
+	if len(sources) > 0 {
+		return sources
+	}
+	if s == common2.NTNATNSymbol {
+		return append(reportSources(NTNUSD, provenance), reportSources(ATNUSD, provenance)...)
+	}
+	return []string{historicSource}
+}
+
+// pluginSources returns the plugins whose samples of the symbol are aggregated, the stale samples and the outliers are
+// not recorded in the provenance.
+func pluginSources(s string, provenance map[string][]types.Provenance) []string {
+	var sources []string
+	for _, p := range provenance[s] {
+		sources = append(sources, p.Plugin)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func prefixSources(symbol string, sources []string) []string {
+	prefixed := make([]string, len(sources))
+	for i, s := range sources {
+		prefixed[i] = symbol + "/" + s
+	}
+	return prefixed
+}
+
+// journalReceipts records the receipts of the mined vote txs, the txs which are not mined for MaxBufferedRounds
+// rounds are dropped from the tracking. The reports of the last round are taken as revealed once the vote tx which
+// reveals them is mined successfully, a partial reveal also requires the Voted event of ours.
+func (os *OracleServer) journalReceipts() {
+	for hash, round := range os.pendingVotes {
+		receipt, err := os.client.TransactionReceipt(context.Background(), hash)
+		if err != nil {
+			if !errors.Is(err, ethereum.NotFound) {
+				os.logger.Debug("cannot get vote receipt", logging.KeyTxHash, hash, logging.KeyError, err)
+				continue
+			}
+			if os.curRound > round+MaxBufferedRounds {
+				os.logger.Warn("vote tx is not mined", logging.KeyRound, round, logging.KeyTxHash, hash)
+				delete(os.pendingVotes, hash)
+			}
+			continue
+		}
+
+		os.appendJournal(&journal.Record{Kind: journal.KindReceipt, Round: round, Receipt: &journal.Receipt{
+			TxHash:      hash,
+			BlockNumber: receipt.BlockNumber.Uint64(),
+			GasUsed:     receipt.GasUsed,
+			Status:      receipt.Status,
+		}})
+		if lastRoundData, ok := os.roundData[round-1]; ok && lastRoundData.RevealTx == hash {
+			if isPartialReveal(lastRoundData) {
+				lastRoundData.Revealed = os.partialRevealCounted(lastRoundData, receipt)
+			} else {
+				lastRoundData.Revealed = receipt.Status == tp.ReceiptStatusSuccessful
+			}
+		}
+		delete(os.pendingVotes, hash)
+	}
+}
+
+// journalOutcome records the finalized on-chain round data of the round, it aggregates the reports which were
+// committed at the previous round, thus the outcome is compared with our reports if we committed at that round.
+func (os *OracleServer) journalOutcome(round uint64) {
+	if os.journal == nil || round == 0 {
+		return
+	}
+	committed, ok := os.roundData[round-1]
+	if !ok {
+		return
+	}
+
+	outcome := &journal.Outcome{CommitRound: round - 1}
+	// the receipt of a pending reveal tx is not known yet, thus the reveal is not taken as rejected.
+	if _, pending := os.pendingVotes[committed.RevealTx]; isPartialReveal(committed) && !committed.Revealed && !pending {
+		outcome.PartialRevealRejected = true
+		os.logger.Warn("partial reveal is not counted in the round outcome", logging.KeyRound, round,
+			"commit round", committed.RoundID, logging.KeyTxHash, committed.RevealTx)
+	}
+	for i, s := range committed.Symbols {
+		o := journal.SymbolOutcome{Symbol: s}
+		if committed.Revealed {
+			o.Reported = committed.Reports[i].Price
+		}
+		// the outcome of the other symbols is still recorded, thus a failed read does not lose the evidence of the round.
+		rd, err := os.oracleContract.GetRoundData(nil, new(big.Int).SetUint64(round), s)
+		if err != nil {
+			os.logger.Error("cannot get round data for the journal", logging.KeyRound, round, logging.KeySymbol, s, logging.KeyError, err)
+			o.Error = err.Error()
+		} else {
+			o.Price, o.Success = rd.Price, rd.Success
+		}
+		outcome.Symbols = append(outcome.Symbols, o)
+	}
+	os.appendJournal(&journal.Record{Kind: journal.KindOutcome, Round: round, Outcome: outcome})
+}
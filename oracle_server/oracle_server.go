@@ -4,6 +4,8 @@ import (
 	"autonity-oracle/config"
 	contract "autonity-oracle/contract_binder/contract"
 	"autonity-oracle/helpers"
+	"autonity-oracle/journal"
+	"autonity-oracle/logging"
 	pWrapper "autonity-oracle/plugin_wrapper"
 	common2 "autonity-oracle/plugins/common"
 	"autonity-oracle/types"
@@ -68,13 +70,17 @@ const (
 	MaxBufferedRounds   = 10
 	SourceScalingFactor = uint64(10)
 	serverStateDumpFile = "server_state_dump.json"
+	pluginStateDir      = "plugin_state"
 )
 
 // ServerMemories is the state that to be flushed into the profiling report directory.
-// For the time being, it just contains the last outlier record of the server. It is loaded on start up.
+// It contains the last outlier record, the penalty history and the suppressed symbols of the server. It is loaded on
+// start up.
 type ServerMemories struct {
 	OutlierRecord
-	LoggedAt string `json:"logged_at"`
+	LoggedAt   string                  `json:"logged_at"`
+	History    []PenaltyRecord         `json:"history"`
+	Suppressed map[string]*Suppression `json:"suppressed_symbols"`
 }
 
 type OutlierRecord struct {
@@ -138,6 +144,7 @@ type OracleServer struct {
 
 	runningPlugins  map[string]*pWrapper.PluginWrapper // the plugin clients that connect with different adapters.
 	samplingSymbols []string                           // the symbols for data fetching in oracle service, can be different from the required protocol symbols.
+	aggSource       aggregationSource                  // the inputs of the aggregation, nil takes the running plugins and the server memories.
 
 	keyRequiredPlugins map[string]struct{} // saving those plugins which require a key granted by data provider
 
@@ -171,8 +178,15 @@ type OracleServer struct {
 
 	serverMemories *ServerMemories // server memories to be flushed.
 
-	fsWatcher *fsnotify.Watcher // FS watcher watches the changes of plugins and the plugins' configs.
-	chainID   int64             // ChainID saves the L1 chain ID, it is used for plugin compatibility check.
+	journal      *journal.Writer               // the append-only journal of rounds in the profile directory.
+	sampleEvents []int64                       // the TS of the sample events sent for the current round.
+	provenance   map[string][]types.Provenance // the origins of the samples aggregated for the current round.
+	fallbacks    map[string]uint8              // the confidence before the decay of the fallback prices of the current round.
+	pendingVotes map[common.Hash]uint64        // the vote txs waiting for their receipts, by the rounds of the votes.
+
+	fsWatcher      *fsnotify.Watcher // FS watcher watches the changes of plugins and the plugins' configs.
+	chReloadConfig chan struct{}     // the config reload requests, e.g. on SIGHUP.
+	chainID        int64             // ChainID saves the L1 chain ID, it is used for plugin compatibility check.
 }
 
 func NewOracleServer(conf *config.Config, dialer types.Dialer, client types.Blockchain,
@@ -185,17 +199,15 @@ func NewOracleServer(conf *config.Config, dialer types.Dialer, client types.Bloc
 		roundData:          make(map[uint64]*types.RoundData),
 		runningPlugins:     make(map[string]*pWrapper.PluginWrapper),
 		keyRequiredPlugins: make(map[string]struct{}),
+		pendingVotes:       make(map[common.Hash]uint64),
 		doneCh:             make(chan struct{}),
+		chReloadConfig:     make(chan struct{}, 1),
 		regularTicker:      time.NewTicker(tenSecsInterval),
 		psTicker:           time.NewTicker(oneSecsInterval),
 		pricePrecision:     decimal.NewFromBigInt(common.Big1, int32(OracleDecimals)),
 	}
 
-	os.logger = hclog.New(&hclog.LoggerOptions{
-		Name:   reflect2.TypeOfPtr(os).String() + conf.Key.Address.String(),
-		Output: o.Stdout,
-		Level:  conf.LoggingLevel,
-	})
+	os.logger = logging.Server(reflect2.TypeOfPtr(os).String() + conf.Key.Address.String())
 
 	chainID, err := client.ChainID(context.Background())
 	if err != nil {
@@ -207,7 +219,7 @@ func NewOracleServer(conf *config.Config, dialer types.Dialer, client types.Bloc
 
 	commitmentHashComputer, err := NewCommitmentHashComputer()
 	if err != nil {
-		os.logger.Error("cannot create commitment hash computer", "err", err)
+		os.logger.Error("cannot create commitment hash computer", "error", err)
 		o.Exit(1)
 	}
 	os.commitmentHashComputer = commitmentHashComputer
@@ -216,10 +228,18 @@ func NewOracleServer(conf *config.Config, dialer types.Dialer, client types.Bloc
 	state := &ServerMemories{}
 	err = state.loadState(os.conf.ProfileDir)
 	if err == nil {
+		state.seedHistory()
 		os.logger.Info("run oracle server with historical flushed state", "state", state)
 		os.serverMemories = state
 	}
 
+	journalWriter, err := journal.NewWriter(os.conf.ProfileDir, os.conf.Journal.MaxSizeMB, os.conf.Journal.RetentionDays)
+	if err != nil {
+		os.logger.Error("cannot open round journal", "error", err)
+		o.Exit(1)
+	}
+	os.journal = journalWriter
+
 	// discover plugins from plugin dir at startup.
 	binaries, err := helpers.ListPlugins(conf.PluginDIR)
 	if len(binaries) == 0 || err != nil {
@@ -281,9 +301,9 @@ func (os *OracleServer) syncStates() error {
 		return err
 	}
 
-	os.logger.Info("syncStates", "CurrentRound", os.curRound, "Num of AvailableSymbols", len(os.protocolSymbols), "CurrentSymbols", os.protocolSymbols)
+	os.logger.Info("syncStates", logging.KeyRound, os.curRound, "Num of AvailableSymbols", len(os.protocolSymbols), "CurrentSymbols", os.protocolSymbols)
 	os.AddNewSymbols(os.protocolSymbols)
-	os.logger.Info("syncStates", "CurrentRound", os.curRound, "Num of bridgerSymbols", len(bridgerSymbols), "bridgerSymbols", bridgerSymbols)
+	os.logger.Info("syncStates", logging.KeyRound, os.curRound, "Num of bridgerSymbols", len(bridgerSymbols), "bridgerSymbols", bridgerSymbols)
 	os.AddNewSymbols(bridgerSymbols)
 
 	// subscribe on-chain round rotation event
@@ -384,7 +404,7 @@ func (os *OracleServer) checkHealth() {
 		return
 	}
 
-	os.logger.Debug("checking heart beat", "current round", os.curRound)
+	os.logger.Debug("checking heart beat", logging.KeyRound, os.curRound)
 }
 
 func (os *OracleServer) isVoter() (bool, error) {
@@ -410,7 +430,7 @@ func (os *OracleServer) printLatestRoundData(newRound uint64) {
 			return
 		}
 
-		os.logger.Debug("get round price", "round", newRound-1, "symbol", s, "Price",
+		os.logger.Debug("get round price", logging.KeyRound, newRound-1, logging.KeySymbol, s, "Price",
 			rd.Price.String(), "success", rd.Success)
 	}
 
@@ -426,7 +446,7 @@ func (os *OracleServer) printLatestRoundData(newRound uint64) {
 			continue
 		}
 
-		os.logger.Debug("latest round price", "round", rd.Round.Uint64(), "symbol", s, "price",
+		os.logger.Debug("latest round price", logging.KeyRound, rd.Round.Uint64(), logging.KeySymbol, s, "price",
 			price.Div(os.pricePrecision).String(), "success", rd.Success)
 	}
 }
@@ -500,8 +520,9 @@ func (os *OracleServer) handleRoundVote() error {
 		return nil
 	}
 
-	// check with the vote buffer from the last penalty event.
-	if os.serverMemories != nil && os.curSampleHeight-os.serverMemories.LastPenalizedAtBlock <= os.conf.VoteBuffer {
+	// check with the vote buffer from the last penalty event, the symbol penalty policy suppresses the penalized
+	// symbols only.
+	if os.conf.Penalty.Policy != config.PenaltyPolicySymbol && os.serverMemories != nil && os.curSampleHeight-os.serverMemories.LastPenalizedAtBlock <= os.conf.VoteBuffer {
 		left := os.conf.VoteBuffer - (os.curSampleHeight - os.serverMemories.LastPenalizedAtBlock)
 		os.logger.Warn("due to the outlier penalty, we postpone your next vote from slashing", "next vote block", left)
 		os.logger.Warn("your last outlier report was", "report", os.serverMemories.OutlierRecord)
@@ -525,6 +546,7 @@ func (os *OracleServer) reportWithCommitment(newRound uint64, lastRoundData *typ
 	curRoundData, err := os.buildRoundData(newRound)
 	if err != nil {
 		os.logger.Error("build round data", "error", err)
+		os.journalVote(newRound, nil, lastRoundData, nil, err)
 		return err
 	}
 
@@ -533,12 +555,13 @@ func (os *OracleServer) reportWithCommitment(newRound uint64, lastRoundData *typ
 
 	// prepare the transaction which carry current round's commitment, and last round's data.
 	curRoundData.Tx, err = os.doReport(curRoundData.CommitmentHash, lastRoundData)
+	os.journalVote(newRound, curRoundData, lastRoundData, curRoundData.Tx, err)
 	if err != nil {
 		os.logger.Error("do report", "error", err.Error())
 		return err
 	}
 
-	os.logger.Info("reported last round data and with current round commitment", "TX hash", curRoundData.Tx.Hash(), "Nonce", curRoundData.Tx.Nonce(), "Cost", curRoundData.Tx.Cost())
+	os.logger.Info("reported last round data and with current round commitment", logging.KeyRound, newRound, logging.KeyTxHash, curRoundData.Tx.Hash(), "Nonce", curRoundData.Tx.Nonce(), "Cost", curRoundData.Tx.Cost())
 
 	// alert in case of balance reach the warning value.
 	balance, err := os.client.BalanceAt(context.Background(), os.conf.Key.Address, nil)
@@ -564,11 +587,12 @@ func (os *OracleServer) reportWithoutCommitment(lastRoundData *types.RoundData)
 
 	// report with no commitment of current round as voter is leaving from the committee.
 	tx, err := os.doReport(common.Hash{}, lastRoundData)
+	os.journalVote(os.curRound, nil, lastRoundData, tx, err)
 	if err != nil {
 		os.logger.Error("do report", "error", err.Error())
 		return err
 	}
-	os.logger.Info("reported last round data and without current round commitment", "TX hash", tx.Hash(), "Nonce", tx.Nonce())
+	os.logger.Info("reported last round data and without current round commitment", logging.KeyTxHash, tx.Hash(), "Nonce", tx.Nonce())
 	return nil
 }
 
@@ -605,14 +629,20 @@ func (os *OracleServer) doReport(curRoundCommitmentHash common.Hash, lastRoundDa
 
 	// if there is no last round data or there were missing datapoint in last round data, then we just submit the
 	// commitment hash of current round as data might be available at current round. This vote will be reimbursed by the
-	// protocol, however it won't be abused as it is limited by the 1 vote per round rule.
-	if lastRoundData == nil || lastRoundData.MissingData {
+	// protocol, however it won't be abused as it is limited by the 1 vote per round rule. With the partial reveal, the
+	// valid reports of the last round are still revealed.
+	if lastRoundData == nil || (lastRoundData.MissingData && !os.partialReveal(lastRoundData)) {
 		var reports []contract.IOracleReport
 		return os.oracleContract.Vote(auth, new(big.Int).SetBytes(curRoundCommitmentHash.Bytes()), reports, invalidSalt, config.Version)
 	}
 
 	// there is last round data, report with current round commitment, and the last round reports and salt to be revealed.
-	return os.oracleContract.Vote(auth, new(big.Int).SetBytes(curRoundCommitmentHash.Bytes()), lastRoundData.Reports, lastRoundData.Salt, config.Version)
+	// The reports are taken as revealed once the receipt of the vote tx is successful.
+	tx, err := os.oracleContract.Vote(auth, new(big.Int).SetBytes(curRoundCommitmentHash.Bytes()), lastRoundData.Reports, lastRoundData.Salt, config.Version)
+	if err == nil {
+		lastRoundData.RevealTx = tx.Hash()
+	}
+	return tx, err
 }
 
 func (os *OracleServer) buildRoundData(round uint64) (*types.RoundData, error) {
@@ -620,10 +650,13 @@ func (os *OracleServer) buildRoundData(round uint64) (*types.RoundData, error) {
 		return nil, types.ErrNoSymbolsObserved
 	}
 
+	os.provenance = make(map[string][]types.Provenance)
+	os.fallbacks = make(map[string]uint8)
 	prices, err := os.aggregateProtocolSymbolPrices()
 	if err != nil {
 		return nil, err
 	}
+	os.updateSuppressions(prices)
 
 	// assemble round data with reports, salt and commitment hash.
 	roundData, err := os.assembleReportData(round, os.protocolSymbols, prices)
@@ -631,7 +664,9 @@ func (os *OracleServer) buildRoundData(round uint64) (*types.RoundData, error) {
 		os.logger.Error("failed to assemble round report data", "error", err.Error())
 		return nil, err
 	}
-	os.logger.Info("assembled round report data", "current round", round, "prices", roundData)
+	roundData.Provenance = os.provenance
+	roundData.FallbackConfidence = os.fallbacks
+	os.logger.Info("assembled round report data", logging.KeyRound, round, "prices", roundData)
 	return roundData, nil
 }
 
@@ -651,7 +686,7 @@ func (os *OracleServer) aggregateProtocolSymbolPrices() (types.PriceBySymbol, er
 
 			p, e := os.aggregateBridgedPrice(s, os.curSampleTS, usdcPrice)
 			if e != nil {
-				os.logger.Error("aggregate bridged price", "error", e.Error(), "symbol", s)
+				os.logger.Error("aggregate bridged price", "error", e.Error(), logging.KeySymbol, s)
 				continue
 			}
 			prices[s] = *p
@@ -661,7 +696,7 @@ func (os *OracleServer) aggregateProtocolSymbolPrices() (types.PriceBySymbol, er
 		// aggregate none bridged symbols
 		p, e := os.aggregatePrice(s, os.curSampleTS)
 		if e != nil {
-			os.logger.Debug("no data for aggregation", "reason", e.Error(), "symbol", s)
+			os.logger.Debug("no data for aggregation", "reason", e.Error(), logging.KeySymbol, s)
 			continue
 		}
 		prices[s] = *p
@@ -703,47 +738,63 @@ func (os *OracleServer) assembleReportData(round uint64, symbols []string, price
 		Prices:  prices,
 	}
 
+	reports, missingData := os.buildReports(symbols, prices)
+
+	salt, err := rand.Int(rand.Reader, saltRange)
+	if err != nil {
+		os.logger.Error("generate rand salt", "error", err.Error())
+		return nil, err
+	}
+
+	commitmentHash, err := os.commitmentHashComputer.CommitmentHash(reports, salt, os.conf.Key.Address)
+	if err != nil {
+		os.logger.Error("failed to compute commitment hash", "error", err.Error())
+		return nil, err
+	}
+
+	roundData.MissingData = missingData
+	roundData.Reports = reports
+	roundData.Salt = salt
+	roundData.CommitmentHash = commitmentHash
+	return roundData, nil
+}
+
+// buildReports converts the prices of the symbols into the on-chain reports, it reports missing data if any of the
+// symbols has no price. The suppressed symbols take the invalid price without missing data, thus the other symbols are
+// still revealed.
+func (os *OracleServer) buildReports(symbols []string, prices types.PriceBySymbol) ([]contract.IOracleReport, bool) {
 	var missingData bool
 	var reports []contract.IOracleReport
 	for _, s := range symbols {
+		if os.reportsInvalid(s) {
+			os.logger.Info("round report suppresses penalized symbol", logging.KeySymbol, s)
+			reports = append(reports, contract.IOracleReport{
+				Price: invalidPrice,
+			})
+			continue
+		}
+
 		if pr, ok := prices[s]; ok {
 			// This is an edge case, which means there is no liquidity in the market for this symbol.
 			price := pr.Price.Mul(os.pricePrecision).BigInt()
 			if price.Cmp(invalidPrice) == 0 {
-				os.logger.Info("zero price measured from market", "symbol", s)
+				os.logger.Info("zero price measured from market", logging.KeySymbol, s)
 				missingData = true
 			}
-			reports = append(reports, contract.IOracleReport{
+			reports = append(reports, os.suppressReport(s, contract.IOracleReport{
 				Price:      price,
 				Confidence: pr.Confidence,
-			})
+			}))
 		} else {
 			// logging the missing of data points for all symbols
 			missingData = true
-			os.logger.Info("round report miss data point for symbol", "symbol", s)
+			os.logger.Info("round report miss data point for symbol", logging.KeySymbol, s)
 			reports = append(reports, contract.IOracleReport{
 				Price: invalidPrice,
 			})
 		}
 	}
-
-	salt, err := rand.Int(rand.Reader, saltRange)
-	if err != nil {
-		os.logger.Error("generate rand salt", "error", err.Error())
-		return nil, err
-	}
-
-	commitmentHash, err := os.commitmentHashComputer.CommitmentHash(reports, salt, os.conf.Key.Address)
-	if err != nil {
-		os.logger.Error("failed to compute commitment hash", "error", err.Error())
-		return nil, err
-	}
-
-	roundData.MissingData = missingData
-	roundData.Reports = reports
-	roundData.Salt = salt
-	roundData.CommitmentHash = commitmentHash
-	return roundData, nil
+	return reports, missingData
 }
 
 func (os *OracleServer) handleNewSymbolsEvent(symbols []string) {
@@ -765,43 +816,47 @@ func (os *OracleServer) aggregateBridgedPrice(srcSymbol string, target int64, us
 
 	p, err := os.aggregatePrice(bridgedSymbol, target)
 	if err != nil {
-		os.logger.Error("aggregate bridged price", "error", err.Error(), "symbol", bridgedSymbol)
+		os.logger.Error("aggregate bridged price", "error", err.Error(), logging.KeySymbol, bridgedSymbol)
 		return nil, err
 	}
 
 	// reset the symbol with source symbol,
 	// and update price with: ATN-USD=ATN-USDC*USDC-USD / NTN-USD=NTN-USDC*USDC-USD
-	// the confidence of ATN-USD and NTN-USD are inherit from ATN-USDC and NTN-USDC.
+	// the confidence of ATN-USD and NTN-USD are inherit from ATN-USDC and NTN-USDC, the confidence model scales it
+	// further with the confidence of USDC-USD.
 	p.Symbol = srcSymbol
 	p.Price = p.Price.Mul(usdcPrice.Price)
+	if os.conf.ConfidenceStrategy == config.ConfidenceStrategyModel {
+		p.Confidence = bridgedConfidence(p.Confidence, usdcPrice.Confidence)
+	}
 	return p, nil
 }
 
 // aggregatePrice takes the symbol's aggregated data points from all the supported plugins, if there are multiple
 // markets' datapoint, it will do a final VWAP aggregation to form the final reporting value.
 func (os *OracleServer) aggregatePrice(s string, target int64) (*types.Price, error) {
-	var prices []decimal.Decimal
-	var volumes []*big.Int
-	for _, plugin := range os.runningPlugins {
-		p, err := plugin.AggregatedPrice(s, target)
+	var samples []sourceSample
+	for _, src := range os.aggregationSource().priceSources() {
+		p, err := src.AggregatedPrice(s, target)
 		if err != nil {
 			continue
 		}
-		prices = append(prices, p.Price)
-		volumes = append(volumes, p.Volume)
-	}
-
-	if len(prices) == 0 {
-		historicRoundPrice, err := os.queryHistoricRoundPrice(s)
-		if err != nil {
-			return nil, err
+		sample := sourceSample{Source: src.Name(), Price: p}
+		if os.isStale(s, sample, target) {
+			continue
 		}
+		samples = append(samples, sample)
+	}
+	samples = os.aggregationSource().filterSamples(samples)
+	os.recordProvenance(s, samples)
 
-		return confidenceAdjustedPrice(&historicRoundPrice, target)
+	if len(samples) == 0 {
+		return os.fallbackPrice(s, target)
 	}
 
-	// compute confidence of the symbol from the num of plugins' samples of it.
-	confidence := ComputeConfidence(s, len(prices), os.conf.ConfidenceStrategy)
+	// compute confidence of the symbol from the plugins' samples of it.
+	confidence := os.computeConfidence(s, target, samples)
+	prices, volumes := samplePrices(samples), sampleVolumes(samples)
 	price := &types.Price{
 		Timestamp:  target,
 		Price:      prices[0],
@@ -836,6 +891,83 @@ func (os *OracleServer) aggregatePrice(s string, target int64) (*types.Price, er
 	return price, nil
 }
 
+// recordProvenance records the origins of the samples aggregated into the price of a symbol for the current round.
+func (os *OracleServer) recordProvenance(symbol string, samples []sourceSample) {
+	if os.provenance == nil || len(samples) == 0 {
+		return
+	}
+	provenance := make([]types.Provenance, 0, len(samples))
+	for _, s := range samples {
+		provenance = append(provenance, types.Provenance{Plugin: s.Source, Venue: s.Venue,
+			SourceTimestamp: s.SourceTime(), Bid: s.Bid, Ask: s.Ask})
+	}
+	os.provenance[symbol] = provenance
+}
+
+// aggregationSource provides the inputs of the aggregation which differ between the live server and the offline
+// replay: the price sources, the filter of their samples and the suppression states of the symbols.
+type aggregationSource interface {
+	priceSources() []types.PriceSource
+	filterSamples(samples []sourceSample) []sourceSample
+	suppressionState(symbol string) string
+}
+
+// liveSource is the aggregation source of the live server, it takes the running plugins and the server memories.
+type liveSource struct {
+	os *OracleServer
+}
+
+func (l liveSource) priceSources() []types.PriceSource {
+	sources := make([]types.PriceSource, 0, len(l.os.runningPlugins))
+	for _, plugin := range l.os.runningPlugins {
+		sources = append(sources, plugin)
+	}
+	return sources
+}
+
+func (l liveSource) filterSamples(samples []sourceSample) []sourceSample {
+	return samples
+}
+
+func (l liveSource) suppressionState(symbol string) string {
+	s, ok := l.os.serverMemories.Suppressed[symbol]
+	if !ok {
+		return ""
+	}
+	if l.os.inVoteBuffer(s.PenalizedAtBlock) {
+		return suppressionActive
+	}
+	return suppressionRecovering
+}
+
+func (os *OracleServer) aggregationSource() aggregationSource {
+	if os.aggSource == nil {
+		return liveSource{os: os}
+	}
+	return os.aggSource
+}
+
+func samplePrices(samples []sourceSample) []decimal.Decimal {
+	prices := make([]decimal.Decimal, len(samples))
+	for i, s := range samples {
+		prices[i] = s.Price.Price
+	}
+	return prices
+}
+
+func sampleVolumes(samples []sourceSample) []*big.Int {
+	volumes := make([]*big.Int, len(samples))
+	for i, s := range samples {
+		volumes[i] = s.Volume
+	}
+	return volumes
+}
+
+// deviationPercent returns the absolute deviation of the value from the reference in percent.
+func deviationPercent(value, reference decimal.Decimal) decimal.Decimal {
+	return value.Sub(reference).Abs().Div(reference.Abs()).Mul(decimal.NewFromInt(100))
+}
+
 // queryHistoricRoundPrice queries the last available price for a given symbol from the historic rounds.
 func (os *OracleServer) queryHistoricRoundPrice(symbol string) (types.Price, error) {
 
@@ -857,8 +989,12 @@ func (os *OracleServer) queryHistoricRoundPrice(symbol string) (types.Price, err
 			continue
 		}
 
-		// Check if the symbol exists in the Prices map
+		// Check if the symbol exists in the Prices map, a fallback price of the round takes its confidence before the
+		// decay, thus it is not decayed twice.
 		if price, found := roundData.Prices[symbol]; found {
+			if confidence, ok := roundData.FallbackConfidence[symbol]; ok {
+				price.Confidence = confidence
+			}
 			return price, nil
 		}
 	}
@@ -877,6 +1013,7 @@ func (os *OracleServer) samplePrice(symbols []string, ts int64) {
 		Symbols: cpSymbols,
 		TS:      ts,
 	}
+	os.sampleEvents = append(os.sampleEvents, ts)
 	nListener := os.sampleEventFeed.Send(e)
 	os.logger.Debug("sample event is sent to", "num of plugins", nListener)
 }
@@ -895,7 +1032,7 @@ func (os *OracleServer) Start() {
 				os.logger.Error("failed to watch filesystem")
 				return
 			}
-			os.logger.Error("fs-watcher errors", "err", err.Error())
+			os.logger.Error("fs-watcher errors", "error", err.Error())
 
 		case err := <-os.subSymbolsEvent.Err():
 			if err != nil {
@@ -923,42 +1060,23 @@ func (os *OracleServer) Start() {
 			}
 			os.lastSampledTS = preSampleTS
 		case penalizeEvent := <-os.chPenalizedEvent:
-
-			os.logger.Warn("Oracle client get penalized as an outlier", "node", penalizeEvent.Participant,
-				"currency symbol", penalizeEvent.Symbol, "median value", penalizeEvent.Median.String(),
-				"reported value", penalizeEvent.Reported.String(), "block", penalizeEvent.Raw.BlockNumber)
-			os.logger.Warn("your next vote will be postponed", "in blocks", os.conf.VoteBuffer)
-
-			if metrics.Enabled {
-				slashEventCounter.Inc(1)
-			}
-
-			newState := &ServerMemories{
-				OutlierRecord: OutlierRecord{
-					LastPenalizedAtBlock: penalizeEvent.Raw.BlockNumber,
-					Participant:          penalizeEvent.Participant,
-					Symbol:               penalizeEvent.Symbol,
-					Median:               penalizeEvent.Median.Uint64(),
-					Reported:             penalizeEvent.Reported.Uint64(),
-				},
-				LoggedAt: time.Now().Format(time.RFC3339),
-			}
-
-			os.serverMemories = newState
-			if err := os.serverMemories.flush(os.conf.ProfileDir); err != nil {
-				os.logger.Error("failed to flush oracle state", "error", err.Error())
-			}
+			os.handlePenalty(penalizeEvent)
 		case fsEvent, ok := <-os.fsWatcher.Events:
 			if !ok {
 				os.logger.Error("fs watcher has been closed")
 			}
 
 			os.logger.Info("watched new fs event", "file", fsEvent.Name, "event", fsEvent.Op.String())
-			// updates on the watched config and plugin directory will trigger plugin management.
-			os.PluginRuntimeManagement()
+			// updates on the config file re-apply the runtime safe server-level settings, and updates on the watched
+			// config and plugin directory will trigger plugin management.
+			os.handleConfigUpdate(ReloadTriggerFSNotify, filepath.Clean(fsEvent.Name) == filepath.Clean(os.conf.ConfigFile))
+
+		case <-os.chReloadConfig:
+			os.logger.Info("reloading config", "file", os.conf.ConfigFile, "trigger", ReloadTriggerSIGHUP)
+			os.handleConfigUpdate(ReloadTriggerSIGHUP, true)
 
 		case roundEvent := <-os.chRoundEvent:
-			os.logger.Info("handle new round", "round", roundEvent.Round.Uint64(), "required sampling TS",
+			os.logger.Info("handle new round", logging.KeyRound, roundEvent.Round.Uint64(), "required sampling TS",
 				roundEvent.Timestamp.Uint64(), "height", roundEvent.Height.Uint64(), "round period", roundEvent.VotePeriod.Uint64())
 
 			if metrics.Enabled {
@@ -975,6 +1093,10 @@ func (os *OracleServer) Start() {
 			if err != nil {
 				os.logger.Error("round voting failed", "error", err.Error())
 			}
+			// the last round is finalized on the round rotation, its outcome is journaled once the vote is sent, thus
+			// the queries of the round data do not delay the vote.
+			os.journalOutcome(os.curRound - 1)
+			os.sampleEvents = nil
 			// at the end of each round, gc expired samples of per plugin.
 			os.gcExpiredSamples()
 			// after vote finished, gc useless symbols by protocol required symbols.
@@ -987,6 +1109,7 @@ func (os *OracleServer) Start() {
 		case <-os.regularTicker.C:
 			os.checkHealth()
 			os.gcRoundData()
+			os.journalReceipts()
 		}
 	}
 }
@@ -999,6 +1122,9 @@ func (os *OracleServer) Stop() {
 	if os.fsWatcher != nil {
 		os.fsWatcher.Close() //nolint
 	}
+	if os.journal != nil {
+		os.journal.Close() //nolint
+	}
 
 	os.doneCh <- struct{}{}
 	for _, c := range os.runningPlugins {
@@ -1009,11 +1135,19 @@ func (os *OracleServer) Stop() {
 
 func (os *OracleServer) PluginRuntimeManagement() {
 	// load plugin configs before start them.
-	plugConfs, err := config.LoadPluginsConfig(os.conf.ConfigFile)
+	serverConf, err := config.LoadLayeredConfig(os.conf.ConfigFile, os.conf.Args)
 	if err != nil {
 		os.logger.Error("cannot load plugin configuration", "error", err.Error())
 		return
 	}
+	os.managePlugins(serverConf)
+}
+
+// managePlugins starts, stops and reconfigures the plugins by the plugin configs of the loaded config.
+func (os *OracleServer) managePlugins(serverConf *config.ServerConfig) {
+	plugConfs := serverConf.PluginConfigMap()
+	// keep the plugin configs up to date for the per plugin settings of the aggregation, i.e. the staleness limits.
+	os.conf.PluginConfigs = plugConfs
 
 	// load plugin binaries
 	binaries, err := helpers.ListPlugins(os.conf.PluginDIR)
@@ -1094,7 +1228,7 @@ func (os *OracleServer) setupNewPlugin(name string, conf *config.PluginConfig) (
 		return nil, err
 	}
 
-	pluginWrapper := pWrapper.NewPluginWrapper(os.conf.LoggingLevel, name, os.conf.PluginDIR, os, conf)
+	pluginWrapper := pWrapper.NewPluginWrapper(name, os.conf.PluginDIR, os, conf)
 	if err := pluginWrapper.Initialize(os.chainID); err != nil {
 		// if the plugin states that a service key is missing, then we mark it down, thus the runtime discovery can
 		// skip those plugins without a key configured.
@@ -1114,8 +1248,14 @@ func (os *OracleServer) WatchSampleEvent(sink chan<- *types.SampleEvent) event.S
 }
 
 func (os *OracleServer) ApplyPluginConf(name string, plugConf *config.PluginConfig) error {
+	// the plugins persist their state under the profile directory unless a state directory is configured.
+	pConf := *plugConf
+	if pConf.StateDir == "" {
+		pConf.StateDir = filepath.Join(os.conf.ProfileDir, pluginStateDir)
+	}
+
 	// set the plugin configuration via system env, thus the plugin can load it on startup.
-	conf, err := json.Marshal(plugConf)
+	conf, err := json.Marshal(pConf)
 	if err != nil {
 		os.logger.Error("cannot marshal plugin's configuration", "error", err.Error())
 		return err
@@ -1129,7 +1269,7 @@ func (os *OracleServer) ApplyPluginConf(name string, plugConf *config.PluginConf
 
 // ComputeConfidence calculates the confidence weight based on the number of data samples. Note! Cryptos take
 // fixed strategy as we have very limited number of data sources at the genesis phase. Thus, the confidence
-// computing is just for forex currencies for the time being.
+// computing is just for forex currencies for the time being, the ModelConfidence of strategy 2 covers all the symbols.
 func ComputeConfidence(symbol string, numOfSamples, strategy int) uint8 {
 
 	// Todo: once the community have more extensive AMM and DEX markets, we will remove this to enable linear
@@ -1152,26 +1292,3 @@ func ComputeConfidence(symbol string, numOfSamples, strategy int) uint8 {
 
 	return uint8(weight) //nolint
 }
-
-func confidenceAdjustedPrice(historicRoundPrice *types.Price, target int64) (*types.Price, error) {
-	// by according to the spreading of price timestamp from the target timestamp,
-	// we reduce the confidence of the price, set the lowest confidence as 1.
-	// Calculate the time difference between the target timestamp and the historic price timestamp
-	timeDifference := target - historicRoundPrice.Timestamp
-
-	var reducedConfidence uint8
-	if timeDifference < 60 { // Less than 1 minute
-		reducedConfidence = historicRoundPrice.Confidence // Keep original confidence
-	} else if timeDifference < 3600 { // Less than 1 hour
-		reducedConfidence = historicRoundPrice.Confidence / 2 // Reduce confidence by half
-	} else {
-		reducedConfidence = 1 // Set the lowest confidence to 1 if more than 1 hour old
-	}
-
-	if reducedConfidence == 0 {
-		return nil, types.ErrNoAvailablePrice
-	}
-
-	historicRoundPrice.Confidence = reducedConfidence
-	return historicRoundPrice, nil
-}
@@ -5,8 +5,12 @@ import (
 	contract "autonity-oracle/contract_binder/contract"
 	cMock "autonity-oracle/contract_binder/contract/mock"
 	"autonity-oracle/helpers"
+	"autonity-oracle/journal"
+	pWrapper "autonity-oracle/plugin_wrapper"
 	"autonity-oracle/types"
 	"autonity-oracle/types/mock"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	tp "github.com/ethereum/go-ethereum/core/types"
@@ -80,6 +84,96 @@ func TestServerState(t *testing.T) {
 	require.Equal(t, originalState, loadedState)
 }
 
+func TestReloadConfig(t *testing.T) {
+	profileDir := t.TempDir()
+	configFile := filepath.Join(profileDir, "oracle_config.yml")
+	writeConf := func(content string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+	}
+
+	srv := &OracleServer{
+		logger: hclog.NewNullLogger(),
+		conf: &config.Config{
+			ConfigFile:         configFile,
+			LoggingLevel:       hclog.Info,
+			GasTipCap:          1,
+			VoteBuffer:         100,
+			KeyFile:            "./key",
+			AutonityWSUrl:      "ws://127.0.0.1:8546",
+			PluginDIR:          "./plugins",
+			ProfileDir:         profileDir,
+			ConfidenceStrategy: config.ConfidenceStrategyLinear,
+			MetricConfigs:      config.DefaultMetricConfig,
+			Logging:            config.DefaultLoggingConfig,
+			Journal:            config.DefaultJournalConfig,
+			Penalty:            config.DefaultPenaltyConfig,
+			ConfidenceModel:    config.DefaultConfidenceModelConfig,
+			Fallback:           config.DefaultFallbackConfig,
+		},
+		runningPlugins: make(map[string]*pWrapper.PluginWrapper),
+		chReloadConfig: make(chan struct{}, 1),
+	}
+
+	t.Run("runtime safe fields are applied, others are reported", func(t *testing.T) {
+		writeConf(`logLevel: 2
+gasTipCap: 10
+voteBuffer: 200
+keyFile: "./key"
+autonityWSUrl: "ws://127.0.0.1:8547"
+pluginDir: "./plugins"
+profileDir: "` + profileDir + `"
+confidenceStrategy: 1
+metricConfigs:
+  influxDBPassword: "secret"
+pluginConfigs:
+  - name: forex_wise
+    refresh: 60
+`)
+		srv.handleConfigUpdate(ReloadTriggerFSNotify, true)
+		// the plugin configs are taken from the same load of the config.
+		require.Equal(t, 60, srv.conf.PluginConfigs["forex_wise"].DataUpdateInterval)
+		require.Equal(t, hclog.Debug, srv.conf.LoggingLevel)
+		require.Equal(t, uint64(10), srv.conf.GasTipCap)
+		require.Equal(t, uint64(200), srv.conf.VoteBuffer)
+		require.Equal(t, config.ConfidenceStrategyFixed, srv.conf.ConfidenceStrategy)
+		require.Equal(t, "ws://127.0.0.1:8546", srv.conf.AutonityWSUrl)
+
+		audit, err := os.ReadFile(filepath.Join(profileDir, configAuditLogFile))
+		require.NoError(t, err)
+		require.NotContains(t, string(audit), "secret")
+		lines := strings.Split(strings.TrimSpace(string(audit)), "\n")
+		require.Len(t, lines, 6)
+		statuses := make(map[string]string)
+		for _, l := range lines {
+			var c ConfigChange
+			require.NoError(t, json.Unmarshal([]byte(l), &c))
+			require.Equal(t, ReloadTriggerFSNotify, c.Trigger)
+			statuses[c.Field] = c.Status
+		}
+		require.Equal(t, map[string]string{
+			"logLevel":           ConfigChangeApplied,
+			"gasTipCap":          ConfigChangeApplied,
+			"voteBuffer":         ConfigChangeApplied,
+			"confidenceStrategy": ConfigChangeApplied,
+			"autonityWSUrl":      ConfigChangeRestartRequired,
+			"metricConfigs":      ConfigChangeRestartRequired,
+		}, statuses)
+	})
+
+	t.Run("invalid config is not applied", func(t *testing.T) {
+		writeConf("gasTipCap: 20\nconfidenceStrategy: 7\n")
+		srv.handleConfigUpdate(ReloadTriggerSIGHUP, true)
+		require.Equal(t, uint64(10), srv.conf.GasTipCap)
+		require.Equal(t, config.ConfidenceStrategyFixed, srv.conf.ConfidenceStrategy)
+	})
+
+	t.Run("reload requests are coalesced", func(t *testing.T) {
+		srv.ReloadConfig()
+		srv.ReloadConfig()
+		require.Len(t, srv.chReloadConfig, 1)
+	})
+}
+
 func TestOracleServer(t *testing.T) {
 	currentRound := new(big.Int).SetUint64(1)
 	precision := OracleDecimals
@@ -101,7 +195,7 @@ func TestOracleServer(t *testing.T) {
 		Key:                key,
 		AutonityWSUrl:      config.DefaultConfig.AutonityWSUrl,
 		PluginDIR:          "../plugins/template_plugin/bin",
-		ProfileDir:         ".",
+		ProfileDir:         t.TempDir(),
 		ConfidenceStrategy: 0,
 		PluginConfigs:      nil,
 		MetricConfigs:      config.MetricConfig{},
@@ -249,6 +343,53 @@ func TestOracleServer(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, hash, srv.roundData[srv.curRound].CommitmentHash)
 
+		// the vote is journaled with the samples, the reports and the vote tx.
+		records, err := journal.Read(conf.ProfileDir)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		vote := records[0].Vote
+		require.Equal(t, journal.KindVote, records[0].Kind)
+		require.Equal(t, srv.curRound, records[0].Round)
+		require.Equal(t, tx.Hash(), vote.TxHash)
+		require.Equal(t, srv.curRound-1, vote.RevealRound)
+		require.Equal(t, hash, vote.CommitmentHash)
+		require.NotEmpty(t, vote.SampleEvents)
+		require.Len(t, vote.Plugins, 1)
+		require.NotEmpty(t, vote.Plugins[0].Samples[NTNUSDC])
+		require.Len(t, vote.Symbols, len(helpers.DefaultSymbols))
+		for _, s := range vote.Symbols {
+			require.NotEmpty(t, s.Sources, s.Symbol)
+		}
+		require.Equal(t, []string{"NTN-USDC/template_plugin", "USDC-USD/template_plugin"}, reportSources(NTNUSD, srv.roundData[srv.curRound].Provenance))
+		require.Contains(t, srv.pendingVotes, tx.Hash())
+
+		// the receipt of the vote tx is journaled once it is mined.
+		l1Mock.EXPECT().TransactionReceipt(gomock.Any(), tx.Hash()).Return(&tp.Receipt{
+			Status: tp.ReceiptStatusSuccessful, GasUsed: 21000, BlockNumber: big.NewInt(61)}, nil)
+		srv.journalReceipts()
+		require.Empty(t, srv.pendingVotes)
+		require.True(t, srv.roundData[srv.curRound-1].Revealed)
+
+		// the outcome of the next round is compared with the committed reports.
+		// the round data of a symbol cannot be read, its error is recorded with the outcome of the other symbols.
+		contractMock.EXPECT().GetRoundData(nil, new(big.Int).SetUint64(srv.curRound+1), helpers.DefaultSymbols[0]).
+			Return(contract.IOracleRoundData{}, errors.New("connection reset"))
+		contractMock.EXPECT().GetRoundData(nil, new(big.Int).SetUint64(srv.curRound+1), gomock.Any()).
+			Times(len(helpers.DefaultSymbols)-1).Return(price, nil)
+		srv.journalOutcome(srv.curRound + 1)
+
+		records, err = journal.Read(conf.ProfileDir)
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		require.Equal(t, journal.KindReceipt, records[1].Kind)
+		require.Equal(t, uint64(21000), records[1].Receipt.GasUsed)
+		require.Equal(t, journal.KindOutcome, records[2].Kind)
+		require.Equal(t, srv.curRound, records[2].Outcome.CommitRound)
+		require.Len(t, records[2].Outcome.Symbols, len(helpers.DefaultSymbols))
+		require.Equal(t, "connection reset", records[2].Outcome.Symbols[0].Error)
+		require.Nil(t, records[2].Outcome.Symbols[0].Price)
+		require.Empty(t, records[2].Outcome.Symbols[1].Error)
+
 		srv.runningPlugins["template_plugin"].Close()
 	})
 
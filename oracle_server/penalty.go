@@ -0,0 +1,192 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	contract "autonity-oracle/contract_binder/contract"
+	"autonity-oracle/journal"
+	"autonity-oracle/logging"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"time"
+)
+
+// maxPenaltyHistory is the number of the latest penalty records kept in the server memories.
+const maxPenaltyHistory = 256
+
+// the suppression states of the symbols recorded in the round journal.
+const (
+	suppressionActive     = "suppressed" // the symbol is penalized within the vote buffer.
+	suppressionRecovering = "recovering" // the symbol is regaining its confidence after the vote buffer.
+)
+
+// minConfidence is the confidence of the reports of the suppressed symbols with the low confidence suppression.
+const minConfidence = uint8(1)
+
+// PenaltyRecord is an outlier penalty in the penalty history of the server.
+type PenaltyRecord struct {
+	OutlierRecord
+	Round    uint64 `json:"round"`
+	LoggedAt string `json:"logged_at"`
+}
+
+// Suppression tracks a symbol suppressed by the symbol penalty policy, it is dropped once the symbol has been healthy
+// for the recovery rounds after the vote buffer.
+type Suppression struct {
+	PenalizedAtBlock uint64 `json:"penalized_at_block"`
+	HealthyRounds    int    `json:"healthy_rounds"`
+}
+
+// addPenalty keeps the penalty as the last outlier record and appends it to the penalty history.
+func (s *ServerMemories) addPenalty(record OutlierRecord, round uint64, loggedAt string) {
+	s.OutlierRecord = record
+	s.LoggedAt = loggedAt
+	s.History = append(s.History, PenaltyRecord{OutlierRecord: record, Round: round, LoggedAt: loggedAt})
+	if len(s.History) > maxPenaltyHistory {
+		s.History = append([]PenaltyRecord(nil), s.History[len(s.History)-maxPenaltyHistory:]...)
+	}
+}
+
+// seedHistory seeds the penalty history with the last outlier record of the state flushed by the former versions.
+func (s *ServerMemories) seedHistory() {
+	if len(s.History) == 0 && s.LastPenalizedAtBlock != 0 {
+		s.History = []PenaltyRecord{{OutlierRecord: s.OutlierRecord, LoggedAt: s.LoggedAt}}
+	}
+}
+
+// SymbolPenalties returns the penalty history of a symbol, from the oldest to the latest.
+func (s *ServerMemories) SymbolPenalties(symbol string) []PenaltyRecord {
+	var records []PenaltyRecord
+	for _, r := range s.History {
+		if r.Symbol == symbol {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// handlePenalty records the outlier penalty into the server memories and the round journal, with the symbol penalty
+// policy the penalized symbol is suppressed rather than postponing the whole vote.
+func (os *OracleServer) handlePenalty(event *contract.OraclePenalized) {
+	os.logger.Warn("Oracle client get penalized as an outlier", "node", event.Participant,
+		logging.KeySymbol, event.Symbol, "median value", event.Median.String(),
+		"reported value", event.Reported.String(), "block", event.Raw.BlockNumber)
+
+	if metrics.Enabled {
+		slashEventCounter.Inc(1)
+	}
+
+	if os.serverMemories == nil {
+		os.serverMemories = &ServerMemories{}
+	}
+	os.serverMemories.addPenalty(OutlierRecord{
+		LastPenalizedAtBlock: event.Raw.BlockNumber,
+		Participant:          event.Participant,
+		Symbol:               event.Symbol,
+		Median:               event.Median.Uint64(),
+		Reported:             event.Reported.Uint64(),
+	}, os.curRound, time.Now().Format(time.RFC3339))
+
+	if os.conf.Penalty.Policy == config.PenaltyPolicySymbol {
+		os.logger.Warn("the penalized symbol will be suppressed", logging.KeySymbol, event.Symbol,
+			"in blocks", os.conf.VoteBuffer, "suppression", os.conf.Penalty.Suppression)
+		if os.serverMemories.Suppressed == nil {
+			os.serverMemories.Suppressed = make(map[string]*Suppression)
+		}
+		os.serverMemories.Suppressed[event.Symbol] = &Suppression{PenalizedAtBlock: event.Raw.BlockNumber}
+	} else {
+		os.logger.Warn("your next vote will be postponed", "in blocks", os.conf.VoteBuffer)
+	}
+
+	if err := os.serverMemories.flush(os.conf.ProfileDir); err != nil {
+		os.logger.Error("failed to flush oracle state", "error", err.Error())
+	}
+	os.appendJournal(&journal.Record{Kind: journal.KindPenalty, Round: os.curRound, Penalty: &journal.Penalty{
+		Symbol:      event.Symbol,
+		Median:      event.Median,
+		Reported:    event.Reported,
+		BlockNumber: event.Raw.BlockNumber,
+	}})
+}
+
+// inVoteBuffer checks if the current sample height is still within the vote buffer of a penalty.
+func (os *OracleServer) inVoteBuffer(penalizedAt uint64) bool {
+	return os.curSampleHeight-penalizedAt <= os.conf.VoteBuffer
+}
+
+// updateSuppressions counts the healthy rounds of the suppressed symbols after their vote buffers, a symbol is healthy
+// if its price is aggregated from the live samples rather than taken from the historic rounds. A symbol regains its
+// full confidence after the recovery rounds, an unhealthy round restarts the recovery.
+func (os *OracleServer) updateSuppressions(prices types.PriceBySymbol) {
+	if os.conf.Penalty.Policy != config.PenaltyPolicySymbol || os.serverMemories == nil ||
+		len(os.serverMemories.Suppressed) == 0 {
+		return
+	}
+
+	for symbol, s := range os.serverMemories.Suppressed {
+		if os.inVoteBuffer(s.PenalizedAtBlock) {
+			continue
+		}
+
+		if os.isHealthy(symbol, prices) {
+			s.HealthyRounds++
+		} else {
+			s.HealthyRounds = 0
+		}
+
+		if s.HealthyRounds >= os.conf.Penalty.RecoveryRounds {
+			os.logger.Info("symbol is recovered from the penalty suppression", logging.KeySymbol, symbol,
+				"healthy rounds", s.HealthyRounds)
+			delete(os.serverMemories.Suppressed, symbol)
+		}
+	}
+
+	if err := os.serverMemories.flush(os.conf.ProfileDir); err != nil {
+		os.logger.Error("failed to flush oracle state", "error", err.Error())
+	}
+}
+
+func (os *OracleServer) isHealthy(symbol string, prices types.PriceBySymbol) bool {
+	if _, ok := prices[symbol]; !ok {
+		return false
+	}
+	sources := reportSources(symbol, os.provenance)
+	for _, src := range sources {
+		if src == historicSource {
+			return false
+		}
+	}
+	return len(sources) > 0
+}
+
+// suppressionState returns the suppression state of a symbol, it is empty if the symbol is not suppressed.
+func (os *OracleServer) suppressionState(symbol string) string {
+	if os.conf.Penalty.Policy != config.PenaltyPolicySymbol || os.serverMemories == nil {
+		return ""
+	}
+	return os.aggregationSource().suppressionState(symbol)
+}
+
+// reportsInvalid checks if a symbol is reported with the invalid price by the invalid suppression.
+func (os *OracleServer) reportsInvalid(symbol string) bool {
+	return os.conf.Penalty.Suppression == config.SuppressionInvalid && os.suppressionState(symbol) == suppressionActive
+}
+
+// suppressReport applies the suppression of a symbol on its report. Within the vote buffer the report takes the lowest
+// confidence, while recovering the confidence grows with the healthy rounds until the symbol is restored.
+func (os *OracleServer) suppressReport(symbol string, report contract.IOracleReport) contract.IOracleReport {
+	switch os.suppressionState(symbol) {
+	case suppressionActive:
+		report.Confidence = minConfidence
+	case suppressionRecovering:
+		if os.conf.Penalty.RecoveryRounds <= 0 {
+			return report
+		}
+		s := os.serverMemories.Suppressed[symbol]
+		confidence := uint8(uint64(report.Confidence) * uint64(s.HealthyRounds) / uint64(os.conf.Penalty.RecoveryRounds))
+		if confidence < minConfidence {
+			confidence = minConfidence
+		}
+		report.Confidence = confidence
+	}
+	return report
+}
@@ -0,0 +1,118 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	contract "autonity-oracle/contract_binder/contract"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestPenaltyHistory(t *testing.T) {
+	state := &ServerMemories{OutlierRecord: OutlierRecord{LastPenalizedAtBlock: 10, Symbol: "EUR-USD"}, LoggedAt: "t0"}
+	state.seedHistory()
+	require.Equal(t, []PenaltyRecord{{OutlierRecord: state.OutlierRecord, LoggedAt: "t0"}}, state.History)
+
+	for i := 0; i < maxPenaltyHistory; i++ {
+		state.addPenalty(OutlierRecord{LastPenalizedAtBlock: uint64(100 + i), Symbol: "JPY-USD"}, uint64(i), "t1")
+	}
+	state.addPenalty(OutlierRecord{LastPenalizedAtBlock: 1000, Symbol: "EUR-USD"}, 300, "t2")
+	require.Len(t, state.History, maxPenaltyHistory)
+	require.Equal(t, uint64(1000), state.LastPenalizedAtBlock)
+	require.Equal(t, []PenaltyRecord{{OutlierRecord: state.OutlierRecord, Round: 300, LoggedAt: "t2"}},
+		state.SymbolPenalties("EUR-USD"))
+	require.Len(t, state.SymbolPenalties("JPY-USD"), maxPenaltyHistory-1)
+
+	dir := t.TempDir()
+	require.NoError(t, state.flush(dir))
+	loaded := &ServerMemories{}
+	require.NoError(t, loaded.loadState(dir))
+	require.Equal(t, state, loaded)
+}
+
+func TestSymbolSuppression(t *testing.T) {
+	newServer := func(suppression string) *OracleServer {
+		return &OracleServer{
+			logger: hclog.NewNullLogger(),
+			conf: &config.Config{
+				ProfileDir: t.TempDir(),
+				VoteBuffer: 10,
+				Penalty: config.PenaltyConfig{Policy: config.PenaltyPolicySymbol, Suppression: suppression,
+					RecoveryRounds: 4},
+			},
+			curSampleTS:     100,
+			curSampleHeight: 105,
+			pricePrecision:  decimal.NewFromBigInt(common.Big1, int32(OracleDecimals)),
+			// the prices of the symbols are aggregated from the samples of the forex plugin.
+			provenance: map[string][]types.Provenance{"EUR-USD": {{Plugin: "forex"}}, "JPY-USD": {{Plugin: "forex"}}},
+		}
+	}
+	penalize := func(srv *OracleServer, symbol string, block uint64) {
+		srv.handlePenalty(&contract.OraclePenalized{Symbol: symbol, Median: big.NewInt(1), Reported: big.NewInt(2),
+			Raw: tp.Log{BlockNumber: block}})
+	}
+	symbols := []string{"EUR-USD", "JPY-USD"}
+	prices := types.PriceBySymbol{
+		"EUR-USD": {Symbol: "EUR-USD", Price: decimal.RequireFromString("1.1"), Confidence: 80},
+		"JPY-USD": {Symbol: "JPY-USD", Price: decimal.RequireFromString("0.01"), Confidence: 80},
+	}
+
+	t.Run("the penalized symbol is reported with the lowest confidence and recovers gradually", func(t *testing.T) {
+		srv := newServer(config.SuppressionLowConfidence)
+		penalize(srv, "EUR-USD", 100)
+		require.Len(t, srv.serverMemories.SymbolPenalties("EUR-USD"), 1)
+
+		srv.updateSuppressions(prices)
+		reports, missingData := srv.buildReports(symbols, prices)
+		require.False(t, missingData)
+		require.Equal(t, minConfidence, reports[0].Confidence)
+		require.Equal(t, uint8(80), reports[1].Confidence)
+		require.Equal(t, suppressionActive, srv.suppressionState("EUR-USD"))
+
+		// out of the vote buffer, the confidence grows with the healthy rounds.
+		srv.curSampleHeight = 111
+		srv.updateSuppressions(prices)
+		reports, _ = srv.buildReports(symbols, prices)
+		require.Equal(t, uint8(20), reports[0].Confidence)
+		srv.updateSuppressions(prices)
+		reports, _ = srv.buildReports(symbols, prices)
+		require.Equal(t, uint8(40), reports[0].Confidence)
+
+		// an unhealthy round restarts the recovery.
+		unhealthy := types.PriceBySymbol{"JPY-USD": prices["JPY-USD"]}
+		srv.updateSuppressions(unhealthy)
+		require.Equal(t, 0, srv.serverMemories.Suppressed["EUR-USD"].HealthyRounds)
+
+		for i := 0; i < 4; i++ {
+			srv.updateSuppressions(prices)
+		}
+		require.Empty(t, srv.serverMemories.Suppressed)
+		reports, _ = srv.buildReports(symbols, prices)
+		require.Equal(t, uint8(80), reports[0].Confidence)
+	})
+
+	t.Run("the penalized symbol is reported invalid without missing data", func(t *testing.T) {
+		srv := newServer(config.SuppressionInvalid)
+		penalize(srv, "EUR-USD", 100)
+
+		reports, missingData := srv.buildReports(symbols, types.PriceBySymbol{"JPY-USD": prices["JPY-USD"]})
+		require.False(t, missingData)
+		require.Equal(t, invalidPrice, reports[0].Price)
+		require.Equal(t, uint8(80), reports[1].Confidence)
+	})
+
+	t.Run("the vote policy does not suppress symbols", func(t *testing.T) {
+		srv := newServer(config.SuppressionInvalid)
+		srv.conf.Penalty.Policy = config.PenaltyPolicyVote
+		penalize(srv, "EUR-USD", 100)
+		require.Empty(t, srv.serverMemories.Suppressed)
+
+		reports, _ := srv.buildReports(symbols, prices)
+		require.Equal(t, uint8(80), reports[0].Confidence)
+	})
+}
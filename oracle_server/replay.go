@@ -0,0 +1,242 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/helpers"
+	"autonity-oracle/journal"
+	pWrapper "autonity-oracle/plugin_wrapper"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+// ReplayConfig is the aggregation settings to replay the recorded rounds with, the nil settings take the recorded
+// values of each round. The staleness, the fallback and the suppression of the symbols are always taken as recorded.
+type ReplayConfig struct {
+	ConfidenceStrategy  *int
+	ConfidenceModel     *config.ConfidenceModelConfig // the confidence model of strategy 2, nil takes the default model.
+	SourceOutlierFilter float64                       // the replay only source outlier filter in percent, 0 disables it.
+	OutlierThreshold    float64                       // the outlier detection threshold of the protocol in percent.
+}
+
+// recordedRound is the aggregation source of a replayed round: the recorded price sources which replace the plugins,
+// the source outlier filter of the replay in percent, and the recorded suppression states by symbol.
+type recordedRound struct {
+	sources       []types.PriceSource
+	outlierFilter float64
+	suppressions  map[string]string
+}
+
+func (r *recordedRound) priceSources() []types.PriceSource {
+	return r.sources
+}
+
+func (r *recordedRound) filterSamples(samples []sourceSample) []sourceSample {
+	return filterSourceOutliers(samples, r.outlierFilter)
+}
+
+func (r *recordedRound) suppressionState(symbol string) string {
+	return r.suppressions[symbol]
+}
+
+// ReplayedRound compares the recorded reports of a round with the replayed ones.
+type ReplayedRound struct {
+	Round   uint64           `json:"round"`
+	Changed bool             `json:"changed"`
+	Symbols []ReplayedSymbol `json:"symbols"`
+}
+
+// ReplayedSymbol compares the recorded report and the replayed report of a symbol against the on-chain median, the
+// median is missing if there is no finalized outcome of the round in the journal.
+type ReplayedSymbol struct {
+	Symbol             string           `json:"symbol"`
+	Median             *big.Int         `json:"median,omitempty"`
+	Recorded           *big.Int         `json:"recorded"`
+	RecordedConfidence uint8            `json:"recordedConfidence"`
+	RecordedDeviation  *decimal.Decimal `json:"recordedDeviation,omitempty"` // in percent.
+	RecordedOutlier    bool             `json:"recordedOutlier"`
+	Replayed           *big.Int         `json:"replayed"`
+	ReplayedConfidence uint8            `json:"replayedConfidence"`
+	ReplayedDeviation  *decimal.Decimal `json:"replayedDeviation,omitempty"` // in percent.
+	ReplayedOutlier    bool             `json:"replayedOutlier"`
+}
+
+// recordedSource replays the recorded samples of a plugin with the same aggregation of the plugin wrapper.
+type recordedSource struct {
+	name    string
+	srcType types.DataSourceType
+	samples map[string]map[int64]types.Price
+	logger  hclog.Logger
+}
+
+func (r *recordedSource) Name() string {
+	return r.name
+}
+
+func (r *recordedSource) AggregatedPrice(symbol string, target int64) (types.Price, error) {
+	return pWrapper.AggregateSamples(r.samples[symbol], r.srcType, symbol, target, r.logger)
+}
+
+func newRecordedSources(plugins []journal.PluginSamples, logger hclog.Logger) []types.PriceSource {
+	sources := make([]types.PriceSource, 0, len(plugins))
+	for _, p := range plugins {
+		src := &recordedSource{
+			name:    p.Plugin,
+			srcType: types.DataSourceType(p.SourceType),
+			samples: make(map[string]map[int64]types.Price),
+			logger:  logger,
+		}
+		for symbol, samples := range p.Samples {
+			tsMap := make(map[int64]types.Price, len(samples))
+			for _, s := range samples {
+				tsMap[s.TS] = types.Price{Timestamp: s.TS, Symbol: symbol, Price: s.Price, Volume: s.Volume,
+					SourceTimestamp: s.SourceTS, Venue: s.Venue, Bid: s.Bid, Ask: s.Ask}
+			}
+			src.samples[symbol] = tsMap
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// Replay re-runs the price aggregation, the confidence computing and the bridging of the recorded rounds offline with
+// the replay settings, the recorded and the replayed reports are compared with the on-chain medians of the rounds.
+func Replay(records []*journal.Record, conf *ReplayConfig, logger hclog.Logger) []ReplayedRound {
+	srv := &OracleServer{
+		logger:         logger,
+		conf:           &config.Config{ConfidenceModel: config.DefaultConfidenceModelConfig},
+		roundData:      make(map[uint64]*types.RoundData),
+		pricePrecision: decimal.NewFromBigInt(common.Big1, int32(OracleDecimals)),
+	}
+
+	if conf.ConfidenceModel != nil {
+		srv.conf.ConfidenceModel = *conf.ConfidenceModel
+	}
+
+	// the on-chain medians by the rounds of the commitments.
+	medians := make(map[uint64]map[string]*big.Int)
+	for _, r := range records {
+		if r.Kind != journal.KindOutcome || r.Outcome == nil {
+			continue
+		}
+		medians[r.Outcome.CommitRound] = make(map[string]*big.Int)
+		for _, s := range r.Outcome.Symbols {
+			if s.Success && s.Price != nil {
+				medians[r.Outcome.CommitRound][s.Symbol] = s.Price
+			}
+		}
+	}
+
+	var rounds []ReplayedRound
+	for _, r := range records {
+		// only the votes with commitments carry the reports to be replayed.
+		if r.Kind != journal.KindVote || r.Vote == nil || len(r.Vote.Symbols) == 0 {
+			continue
+		}
+		vote := r.Vote
+
+		srv.conf.ConfidenceStrategy = vote.ConfidenceStrategy
+		if conf.ConfidenceStrategy != nil {
+			srv.conf.ConfidenceStrategy = *conf.ConfidenceStrategy
+		}
+		round := srv.applyRecordedConfig(vote)
+		round.sources = newRecordedSources(vote.Plugins, logger)
+		round.outlierFilter = conf.SourceOutlierFilter
+		srv.aggSource = round
+
+		symbols := make([]string, len(vote.Symbols))
+		for i, s := range vote.Symbols {
+			symbols[i] = s.Symbol
+		}
+		srv.curRound = r.Round
+		srv.curSampleTS = vote.SampleTS
+		srv.protocolSymbols = symbols
+
+		// the replayed prices are kept as the historic rounds of the later rounds, as the server does.
+		srv.fallbacks = make(map[string]uint8)
+		prices, _ := srv.aggregateProtocolSymbolPrices() //nolint
+		reports, _ := srv.buildReports(symbols, prices)
+		srv.roundData[r.Round] = &types.RoundData{RoundID: r.Round, Symbols: symbols, Prices: prices, Reports: reports,
+			FallbackConfidence: srv.fallbacks}
+		srv.gcRoundData()
+
+		replayed := ReplayedRound{Round: r.Round}
+		for i, recorded := range vote.Symbols {
+			s := ReplayedSymbol{
+				Symbol:             recorded.Symbol,
+				Median:             medians[r.Round][recorded.Symbol],
+				Recorded:           recorded.Report,
+				RecordedConfidence: recorded.Confidence,
+				Replayed:           reports[i].Price,
+				ReplayedConfidence: reports[i].Confidence,
+			}
+			s.RecordedDeviation, s.RecordedOutlier = outlierCheck(s.Recorded, s.Median, conf.OutlierThreshold)
+			s.ReplayedDeviation, s.ReplayedOutlier = outlierCheck(s.Replayed, s.Median, conf.OutlierThreshold)
+			if s.Recorded == nil || s.Recorded.Cmp(s.Replayed) != 0 || s.RecordedConfidence != s.ReplayedConfidence {
+				replayed.Changed = true
+			}
+			replayed.Symbols = append(replayed.Symbols, s)
+		}
+		rounds = append(rounds, replayed)
+	}
+	return rounds
+}
+
+// applyRecordedConfig takes the recorded staleness, fallback and suppression settings of a round, and returns the
+// recorded suppression states of its symbols. The fallback to the on-chain round data is not replayed, as there is no L1.
+func (os *OracleServer) applyRecordedConfig(vote *journal.Vote) *recordedRound {
+	os.conf.Staleness = vote.Staleness
+	os.conf.Fallback = vote.Fallback
+	os.conf.Fallback.OnChain = false
+	os.conf.Penalty = vote.Penalty
+	os.conf.PluginConfigs = make(map[string]config.PluginConfig, len(vote.PluginStaleness))
+	for name, maxStaleness := range vote.PluginStaleness {
+		os.conf.PluginConfigs[name] = config.PluginConfig{Name: name, MaxStaleness: maxStaleness}
+	}
+
+	os.serverMemories = &ServerMemories{Suppressed: make(map[string]*Suppression)}
+	round := &recordedRound{suppressions: make(map[string]string)}
+	for _, s := range vote.Symbols {
+		if s.Suppression == "" {
+			continue
+		}
+		round.suppressions[s.Symbol] = s.Suppression
+		os.serverMemories.Suppressed[s.Symbol] = &Suppression{HealthyRounds: s.HealthyRounds}
+	}
+	return round
+}
+
+// outlierCheck computes the deviation of the report from the median in percent, and if it crosses the threshold. The
+// missing reports and the missing medians are not checked.
+func outlierCheck(report, median *big.Int, threshold float64) (*decimal.Decimal, bool) {
+	if report == nil || median == nil || report.Cmp(invalidPrice) == 0 || median.Sign() == 0 {
+		return nil, false
+	}
+	deviation := deviationPercent(decimal.NewFromBigInt(report, 0), decimal.NewFromBigInt(median, 0))
+	return &deviation, deviation.GreaterThan(decimal.NewFromFloat(threshold))
+}
+
+// filterSourceOutliers drops the samples which deviate from the median of the prices by more than the filter in
+// percent, it requires at least 3 samples to tell the outliers, and a 0 filter disables it.
+func filterSourceOutliers(samples []sourceSample, filter float64) []sourceSample {
+	if filter <= 0 || len(samples) < 3 {
+		return samples
+	}
+
+	median, err := helpers.Median(samplePrices(samples))
+	if err != nil || median.IsZero() {
+		return samples
+	}
+
+	maxDeviation := decimal.NewFromFloat(filter)
+	var kept []sourceSample
+	for _, s := range samples {
+		if deviationPercent(s.Price.Price, median).GreaterThan(maxDeviation) {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept
+}
@@ -0,0 +1,109 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/journal"
+	"autonity-oracle/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestFilterSourceOutliers(t *testing.T) {
+	// the samples are not in the order of the prices, thus the volumes have to follow their prices.
+	samples := []sourceSample{
+		{Source: "c", Price: types.Price{Price: decimal.RequireFromString("1.20"), Volume: big.NewInt(3)}},
+		{Source: "a", Price: types.Price{Price: decimal.RequireFromString("1.00"), Volume: big.NewInt(1)}},
+		{Source: "b", Price: types.Price{Price: decimal.RequireFromString("1.01"), Volume: big.NewInt(2)}},
+	}
+
+	kept := filterSourceOutliers(samples, 5)
+	require.Equal(t, samples[1:], kept)
+	require.Equal(t, []*big.Int{big.NewInt(1), big.NewInt(2)}, sampleVolumes(kept))
+
+	// the filter is disabled, or there are too few sources to tell the outliers.
+	require.Len(t, filterSourceOutliers(samples, 0), 3)
+	require.Len(t, filterSourceOutliers(samples[1:], 5), 2)
+}
+
+func TestReplay(t *testing.T) {
+	precision := decimal.NewFromBigInt(big.NewInt(1), int32(OracleDecimals))
+	report := func(price string) *big.Int {
+		return decimal.RequireFromString(price).Mul(precision).BigInt()
+	}
+	sample := func(price string) map[string][]journal.Sample {
+		return map[string][]journal.Sample{"EUR-USD": {{TS: 100, Price: decimal.RequireFromString(price), Volume: types.DefaultVolume}}}
+	}
+
+	// the recorded round took the median of 3 forex sources, one of them deviated.
+	records := []*journal.Record{
+		{Kind: journal.KindVote, Round: 10, Vote: &journal.Vote{
+			SampleTS: 100,
+			Plugins: []journal.PluginSamples{
+				{Plugin: "forex_a", SourceType: int(types.SrcCEX), Samples: sample("1.00")},
+				{Plugin: "forex_b", SourceType: int(types.SrcCEX), Samples: sample("1.02")},
+				{Plugin: "forex_c", SourceType: int(types.SrcCEX), Samples: sample("1.30")},
+			},
+			Symbols:            []journal.SymbolReport{{Symbol: "EUR-USD", Report: report("1.02"), Confidence: 90}},
+			ConfidenceStrategy: config.ConfidenceStrategyLinear,
+		}},
+		// a vote without commitment is not replayed.
+		{Kind: journal.KindVote, Round: 11, Vote: &journal.Vote{SampleTS: 130}},
+		{Kind: journal.KindOutcome, Round: 11, Outcome: &journal.Outcome{CommitRound: 10,
+			Symbols: []journal.SymbolOutcome{{Symbol: "EUR-USD", Price: report("1.10"), Success: true}}}},
+	}
+
+	t.Run("replay with the recorded settings", func(t *testing.T) {
+		rounds := Replay(records, &ReplayConfig{OutlierThreshold: 5}, hclog.NewNullLogger())
+		require.Len(t, rounds, 1)
+		require.False(t, rounds[0].Changed)
+		s := rounds[0].Symbols[0]
+		require.Equal(t, report("1.02"), s.Replayed)
+		require.Equal(t, report("1.10"), s.Median)
+		require.True(t, s.RecordedOutlier)
+		require.True(t, s.ReplayedOutlier)
+		require.Equal(t, "7.27", s.ReplayedDeviation.StringFixed(2))
+	})
+
+	t.Run("replay with the alternative settings", func(t *testing.T) {
+		strategy := config.ConfidenceStrategyFixed
+		rounds := Replay(records, &ReplayConfig{ConfidenceStrategy: &strategy, SourceOutlierFilter: 10,
+			OutlierThreshold: 10}, hclog.NewNullLogger())
+		require.Len(t, rounds, 1)
+		require.True(t, rounds[0].Changed)
+		s := rounds[0].Symbols[0]
+		require.Equal(t, report("1.01"), s.Replayed)
+		require.Equal(t, uint8(MaxConfidence), s.ReplayedConfidence)
+		require.False(t, s.ReplayedOutlier)
+	})
+
+	t.Run("replay with the recorded staleness and suppression", func(t *testing.T) {
+		samples := func(price string, sourceTS int64) map[string][]journal.Sample {
+			s := []journal.Sample{{TS: 200, Price: decimal.RequireFromString(price), Volume: types.DefaultVolume, SourceTS: sourceTS}}
+			return map[string][]journal.Sample{"EUR-USD": s, "JPY-USD": s}
+		}
+		// the sample of forex_b was stale, and JPY-USD was suppressed with the invalid price.
+		recorded := []*journal.Record{{Kind: journal.KindVote, Round: 20, Vote: &journal.Vote{
+			SampleTS: 200,
+			Plugins: []journal.PluginSamples{
+				{Plugin: "forex_a", SourceType: int(types.SrcCEX), Samples: samples("1.00", 195)},
+				{Plugin: "forex_b", SourceType: int(types.SrcCEX), Samples: samples("1.50", 100)},
+			},
+			Symbols: []journal.SymbolReport{
+				{Symbol: "EUR-USD", Report: report("1.00"), Confidence: MaxConfidence},
+				{Symbol: "JPY-USD", Report: invalidPrice, Suppression: suppressionActive},
+			},
+			ConfidenceStrategy: config.ConfidenceStrategyFixed,
+			Staleness:          config.StalenessConfig{MaxAge: 30},
+			Penalty:            config.PenaltyConfig{Policy: config.PenaltyPolicySymbol, Suppression: config.SuppressionInvalid},
+		}}}
+
+		rounds := Replay(recorded, &ReplayConfig{OutlierThreshold: 10}, hclog.NewNullLogger())
+		require.Len(t, rounds, 1)
+		require.False(t, rounds[0].Changed)
+		require.Equal(t, report("1.00"), rounds[0].Symbols[0].Replayed)
+		require.Equal(t, invalidPrice, rounds[0].Symbols[1].Replayed)
+	})
+}
@@ -0,0 +1,80 @@
+package oracleserver
+
+import (
+	"autonity-oracle/logging"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	// votedEventID is the topic of the Voted event, the oracle contract emits it once it counts the reveal of a voter.
+	votedEventID = crypto.Keccak256Hash([]byte("Voted(address,int256[])"))
+
+	rejectedPartialReveals = metrics.GetOrRegisterCounter("oracle/reveal/partial/rejected", nil)
+)
+
+// partialReveal checks if the reports of a round with missing symbols are revealed. The missing symbols were
+// committed with the invalid price, thus the reveal matches the commitment, the invalid prices are the missing symbols
+// of the reveal. Whether the oracle contract counts the partial reveal is checked by the receipt of the vote tx.
+func (os *OracleServer) partialReveal(lastRoundData *types.RoundData) bool {
+	missing := missingSymbols(lastRoundData)
+	if !os.conf.PartialReveal {
+		os.logger.Info("discard the reports of last round with missing symbols", logging.KeyRound,
+			lastRoundData.RoundID, "missing", missing)
+		return false
+	}
+
+	if len(missing) == len(lastRoundData.Symbols) {
+		os.logger.Info("no valid report of last round to be revealed", logging.KeyRound, lastRoundData.RoundID)
+		return false
+	}
+
+	os.logger.Info("partially reveal the reports of last round", logging.KeyRound, lastRoundData.RoundID,
+		"missing", missing)
+	return true
+}
+
+// isPartialReveal tells if the reports of a round are revealed partially, i.e. with the missing symbols.
+func isPartialReveal(roundData *types.RoundData) bool {
+	return roundData.MissingData && roundData.RevealTx != (common.Hash{})
+}
+
+// partialRevealCounted checks the receipt of a partial reveal tx, the reveal is counted by the oracle contract if the
+// tx succeeds with the Voted event of ours. A rejected partial reveal is reported explicitly, as the reports of the
+// round are lost.
+func (os *OracleServer) partialRevealCounted(roundData *types.RoundData, receipt *tp.Receipt) bool {
+	if receipt.Status == tp.ReceiptStatusSuccessful && hasVotedEvent(receipt, os.conf.Key.Address) {
+		return true
+	}
+
+	os.logger.Warn("partial reveal of last round is rejected", logging.KeyRound, roundData.RoundID,
+		logging.KeyTxHash, roundData.RevealTx, "status", receipt.Status, "missing", missingSymbols(roundData))
+	if metrics.Enabled {
+		rejectedPartialReveals.Inc(1)
+	}
+	return false
+}
+
+// hasVotedEvent tells if the receipt has the Voted event of the voter.
+func hasVotedEvent(receipt *tp.Receipt, voter common.Address) bool {
+	for _, l := range receipt.Logs {
+		if len(l.Topics) > 1 && l.Topics[0] == votedEventID && common.BytesToAddress(l.Topics[1].Bytes()) == voter {
+			return true
+		}
+	}
+	return false
+}
+
+// missingSymbols returns the symbols committed with the invalid price.
+func missingSymbols(roundData *types.RoundData) []string {
+	var missing []string
+	for i, s := range roundData.Symbols {
+		if roundData.Reports[i].Price.Cmp(invalidPrice) == 0 {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,146 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	contract "autonity-oracle/contract_binder/contract"
+	cMock "autonity-oracle/contract_binder/contract/mock"
+	"autonity-oracle/journal"
+	"autonity-oracle/types"
+	"autonity-oracle/types/mock"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestPartialReveal(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	key := &keystore.Key{PrivateKey: privateKey, Address: crypto.PubkeyToAddress(privateKey.PublicKey)}
+
+	lastRoundData := func() *types.RoundData {
+		return &types.RoundData{
+			RoundID: 5,
+			Symbols: []string{"EUR-USD", "JPY-USD"},
+			Reports: []contract.IOracleReport{{Price: big.NewInt(110), Confidence: 100}, {Price: invalidPrice}},
+			Salt:    big.NewInt(1234),
+			// JPY-USD is missing.
+			MissingData: true,
+		}
+	}
+	commitment := common.HexToHash("0x01")
+	tx := tp.NewTx(&tp.DynamicFeeTx{Nonce: 1})
+
+	newServer := func(ctrl *gomock.Controller, partialReveal bool) (*OracleServer, *mock.MockBlockchain, *cMock.MockContractAPI) {
+		l1Mock := mock.NewMockBlockchain(ctrl)
+		l1Mock.EXPECT().ChainID(gomock.Any()).Return(ChainIDPiccadilly, nil)
+		contractMock := cMock.NewMockContractAPI(ctrl)
+		return &OracleServer{
+			logger:         hclog.NewNullLogger(),
+			conf:           &config.Config{Key: key, PartialReveal: partialReveal},
+			client:         l1Mock,
+			oracleContract: contractMock,
+		}, l1Mock, contractMock
+	}
+
+	t.Run("valid reports are revealed with the missing symbols as invalid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		srv, _, contractMock := newServer(ctrl, true)
+
+		data := lastRoundData()
+		contractMock.EXPECT().Vote(gomock.Any(), new(big.Int).SetBytes(commitment.Bytes()), data.Reports, data.Salt,
+			config.Version).Return(tx, nil)
+		_, err := srv.doReport(commitment, data)
+		require.NoError(t, err)
+		require.Equal(t, tx.Hash(), data.RevealTx)
+		// the reports are not taken as revealed until the receipt of the vote tx.
+		require.False(t, data.Revealed)
+		require.Equal(t, []string{"JPY-USD"}, missingSymbols(data))
+	})
+
+	t.Run("failed vote does not reveal the reports", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		srv, _, contractMock := newServer(ctrl, true)
+
+		data := lastRoundData()
+		contractMock.EXPECT().Vote(gomock.Any(), gomock.Any(), data.Reports, data.Salt, config.Version).
+			Return(nil, errors.New("nonce too low"))
+		_, err := srv.doReport(commitment, data)
+		require.Error(t, err)
+		require.Equal(t, common.Hash{}, data.RevealTx)
+	})
+
+	t.Run("reports are discarded without partial reveal", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		srv, _, contractMock := newServer(ctrl, false)
+
+		data := lastRoundData()
+		contractMock.EXPECT().Vote(gomock.Any(), gomock.Any(), gomock.Len(0), invalidSalt, config.Version).Return(tx, nil)
+		_, err := srv.doReport(commitment, data)
+		require.NoError(t, err)
+		require.Equal(t, common.Hash{}, data.RevealTx)
+	})
+
+	t.Run("rejected partial reveal is reported", func(t *testing.T) {
+		voted := &tp.Log{Topics: []common.Hash{votedEventID, common.BytesToHash(key.Address.Bytes())}}
+		otherVoted := &tp.Log{Topics: []common.Hash{votedEventID, common.HexToHash("0x02")}}
+		tests := []struct {
+			name    string
+			receipt *tp.Receipt
+			counted bool
+		}{
+			{"counted", &tp.Receipt{Status: tp.ReceiptStatusSuccessful, Logs: []*tp.Log{voted}}, true},
+			{"failed tx", &tp.Receipt{Status: tp.ReceiptStatusFailed}, false},
+			{"no voted event of ours", &tp.Receipt{Status: tp.ReceiptStatusSuccessful, Logs: []*tp.Log{otherVoted}}, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				l1Mock := mock.NewMockBlockchain(ctrl)
+				contractMock := cMock.NewMockContractAPI(ctrl)
+				dir := t.TempDir()
+				w, err := journal.NewWriter(dir, 0, 0)
+				require.NoError(t, err)
+
+				data := lastRoundData()
+				data.RevealTx = tx.Hash()
+				srv := &OracleServer{
+					logger:         hclog.NewNullLogger(),
+					conf:           &config.Config{Key: key, PartialReveal: true},
+					client:         l1Mock,
+					oracleContract: contractMock,
+					journal:        w,
+					curRound:       6,
+					roundData:      map[uint64]*types.RoundData{5: data},
+					pendingVotes:   map[common.Hash]uint64{tx.Hash(): 6},
+				}
+
+				tt.receipt.BlockNumber = big.NewInt(61)
+				l1Mock.EXPECT().TransactionReceipt(gomock.Any(), tx.Hash()).Return(tt.receipt, nil)
+				srv.journalReceipts()
+				require.Equal(t, tt.counted, data.Revealed)
+
+				// the outcome of the round flags the partial reveal which is not counted.
+				contractMock.EXPECT().GetRoundData(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(contract.IOracleRoundData{Price: big.NewInt(110), Success: true}, nil).Times(2)
+				srv.journalOutcome(6)
+				require.NoError(t, w.Close())
+				records, err := journal.Read(dir)
+				require.NoError(t, err)
+				require.Len(t, records, 2)
+				require.Equal(t, journal.KindOutcome, records[1].Kind)
+				require.Equal(t, !tt.counted, records[1].Outcome.PartialRevealRejected)
+			})
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package oracleserver
+
+import (
+	"autonity-oracle/logging"
+	"fmt"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// stalenessLimit resolves the max age in seconds of a sample of a symbol from a source, it is the strictest of the
+// global, the symbol and the plugin limits, 0 for no limit.
+func (os *OracleServer) stalenessLimit(source, symbol string) int64 {
+	limits := []int{os.conf.Staleness.MaxAge, os.conf.Staleness.Symbols[symbol]}
+	if pConf, ok := os.conf.PluginConfigs[source]; ok {
+		limits = append(limits, pConf.MaxStaleness)
+	}
+
+	var limit int64
+	for _, l := range limits {
+		if l > 0 && (limit == 0 || int64(l) < limit) {
+			limit = int64(l)
+		}
+	}
+	return limit
+}
+
+// isStale checks the age of the source side quote of a sample to the round timestamp against its staleness limit, the stale sample is excluded
+// from the aggregation and it is counted as missing of the source.
+func (os *OracleServer) isStale(symbol string, sample sourceSample, target int64) bool {
+	age := target - sample.SourceTime()
+	if age < 0 {
+		age = 0
+	}
+	limit := os.stalenessLimit(sample.Source, symbol)
+	stale := limit > 0 && age > limit
+
+	if metrics.Enabled {
+		metrics.GetOrRegisterGauge(fmt.Sprintf("oracle/%s/%s/staleness", sample.Source, symbol), nil).Update(age)
+		if stale {
+			metrics.GetOrRegisterCounter(fmt.Sprintf("oracle/%s/%s/stale", sample.Source, symbol), nil).Inc(1)
+		}
+	}
+
+	if stale {
+		os.logger.Warn("excluding stale sample", logging.KeyPlugin, sample.Source, logging.KeySymbol, symbol,
+			"staleness", age, "limit", limit)
+		return true
+	}
+	os.logger.Debug("sample staleness", logging.KeyPlugin, sample.Source, logging.KeySymbol, symbol, "staleness", age)
+	return false
+}
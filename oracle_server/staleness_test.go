@@ -0,0 +1,96 @@
+package oracleserver
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestStalenessLimit(t *testing.T) {
+	srv := &OracleServer{conf: &config.Config{
+		Staleness:     config.StalenessConfig{MaxAge: 300, Symbols: map[string]int{"EUR-USD": 120}},
+		PluginConfigs: map[string]config.PluginConfig{"forex": {MaxStaleness: 60}, "binance": {}},
+	}}
+
+	require.Equal(t, int64(300), srv.stalenessLimit("binance", "NTN-USDC"))
+	require.Equal(t, int64(120), srv.stalenessLimit("binance", "EUR-USD"))
+	require.Equal(t, int64(60), srv.stalenessLimit("forex", "EUR-USD"))
+
+	srv.conf.Staleness = config.StalenessConfig{}
+	require.Equal(t, int64(0), srv.stalenessLimit("binance", "EUR-USD"))
+	require.Equal(t, int64(60), srv.stalenessLimit("forex", "EUR-USD"))
+}
+
+func TestStaleSamplesExcluded(t *testing.T) {
+	target := int64(1000)
+	source := func(name string, ts int64, price string) *recordedSource {
+		return &recordedSource{name: name, srcType: types.SrcCEX, logger: hclog.NewNullLogger(),
+			samples: map[string]map[int64]types.Price{"EUR-USD": {ts: {Timestamp: ts, Symbol: "EUR-USD",
+				Price: decimal.RequireFromString(price), Volume: big.NewInt(1)}}}}
+	}
+
+	srv := &OracleServer{
+		logger:    hclog.NewNullLogger(),
+		conf:      &config.Config{ConfidenceStrategy: config.ConfidenceStrategyLinear},
+		roundData: make(map[uint64]*types.RoundData),
+		aggSource: &recordedRound{sources: []types.PriceSource{source("fresh", 990, "1.1"), source("stale", 700, "1.3")}},
+	}
+
+	// without the staleness limit both samples are aggregated.
+	p, err := srv.aggregatePrice("EUR-USD", target)
+	require.NoError(t, err)
+	require.Equal(t, "1.2", p.Price.String())
+
+	// the stale sample is excluded, thus it takes the fresh sample only.
+	srv.conf.Staleness.MaxAge = 60
+	p, err = srv.aggregatePrice("EUR-USD", target)
+	require.NoError(t, err)
+	require.Equal(t, "1.1", p.Price.String())
+
+	// all samples are stale, the symbol has no data.
+	srv.conf.Staleness.Symbols = map[string]int{"EUR-USD": 5}
+	_, err = srv.aggregatePrice("EUR-USD", target)
+	require.Error(t, err)
+}
+
+func TestSourceTimestampStaleness(t *testing.T) {
+	target := int64(1000)
+	bid, ask := decimal.RequireFromString("1.09"), decimal.RequireFromString("1.11")
+	// both samples are fetched recently, while the quote of the cached one is old on the data source side.
+	srv := &OracleServer{
+		logger:     hclog.NewNullLogger(),
+		conf:       &config.Config{ConfidenceStrategy: config.ConfidenceStrategyLinear},
+		roundData:  make(map[uint64]*types.RoundData),
+		provenance: make(map[string][]types.Provenance),
+		aggSource: &recordedRound{sources: []types.PriceSource{
+			&recordedSource{name: "live", srcType: types.SrcCEX, logger: hclog.NewNullLogger(),
+				samples: map[string]map[int64]types.Price{"EUR-USD": {995: {Timestamp: 995, Symbol: "EUR-USD",
+					Price: decimal.RequireFromString("1.1"), Volume: big.NewInt(1), SourceTimestamp: 994,
+					Venue: "EURUSD", Bid: &bid, Ask: &ask}}}},
+			&recordedSource{name: "cached", srcType: types.SrcCEX, logger: hclog.NewNullLogger(),
+				samples: map[string]map[int64]types.Price{"EUR-USD": {995: {Timestamp: 995, Symbol: "EUR-USD",
+					Price: decimal.RequireFromString("1.3"), Volume: big.NewInt(1), SourceTimestamp: 400}}}},
+		}},
+	}
+	srv.conf.Staleness.MaxAge = 60
+
+	p, err := srv.aggregatePrice("EUR-USD", target)
+	require.NoError(t, err)
+	require.Equal(t, "1.1", p.Price.String())
+	require.Equal(t, []types.Provenance{{Plugin: "live", Venue: "EURUSD", SourceTimestamp: 994, Bid: &bid, Ask: &ask}},
+		srv.provenance["EUR-USD"])
+	// the cached plugin of the stale sample is not a source of the price.
+	require.Equal(t, []string{"live"}, reportSources("EUR-USD", srv.provenance))
+
+	// all samples are stale, the price is not healthy as it can only be taken from the historic rounds.
+	srv.conf.Staleness.MaxAge = 1
+	srv.provenance = make(map[string][]types.Provenance)
+	_, err = srv.aggregatePrice("EUR-USD", target)
+	require.Error(t, err)
+	require.Equal(t, []string{historicSource}, reportSources("EUR-USD", srv.provenance))
+	require.False(t, srv.isHealthy("EUR-USD", types.PriceBySymbol{"EUR-USD": *p}))
+}
@@ -0,0 +1,283 @@
+package pluginprobe
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/logging"
+	pWrapper "autonity-oracle/plugin_wrapper"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/hashicorp/go-hclog"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	defaultInterval = 10 * time.Second
+	defaultRounds   = 1
+
+	ErrMissingBinary = errors.New("the plugin binary to be probed is missing")
+)
+
+// Config is the resolved configuration of a plugin probe.
+type Config struct {
+	PluginDIR    string
+	Name         string
+	Symbols      []string // symbols to be fetched, the plugin's available symbols are taken if it is empty.
+	ChainID      int64
+	Interval     time.Duration
+	Rounds       int // the number of FetchPrices calls, 0 means probing until the process is interrupted.
+	JSON         bool
+	LoggingLevel hclog.Level
+	PluginConfig config.PluginConfig
+}
+
+// Result is the outcome of a single FetchPrices call of the probed plugin.
+type Result struct {
+	Round                 int           `json:"round"`
+	At                    time.Time     `json:"at"`
+	Latency               time.Duration `json:"latency"`
+	Prices                []ProbedPrice `json:"prices"`
+	UnRecognizableSymbols []string      `json:"unRecognizableSymbols,omitempty"`
+	Error                 string        `json:"error,omitempty"`
+}
+
+// ProbedPrice is the printable form of a price sampled by the probed plugin.
+type ProbedPrice struct {
+	Symbol    string `json:"symbol"`
+	Price     string `json:"price"`
+	Volume    string `json:"volume"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MakeConfig resolves the probe configuration from the arguments of the `plugin probe` sub command, the last argument
+// is the path of the plugin binary.
+func MakeConfig(args []string) (*Config, error) {
+	var configFile, symbols string
+	var flagConf config.PluginConfig
+	conf := &Config{}
+	logLevel := int(hclog.Info)
+
+	fs := flag.NewFlagSet("plugin probe", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s plugin probe [flags] <plugin binary>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&configFile, "config", "", "The oracle server config file to load the plugin's config from, optional.")
+	fs.StringVar(&symbols, "symbols", "", "Comma separated symbols to be fetched, the plugin's available symbols are used if it is empty.")
+	fs.Int64Var(&conf.ChainID, "chainID", common.ChainIDPiccadilly.Int64(), "The L1 chain ID passed to the plugin's State() call.")
+	fs.DurationVar(&conf.Interval, "interval", defaultInterval, "The interval between two FetchPrices calls.")
+	fs.IntVar(&conf.Rounds, "rounds", defaultRounds, "The number of FetchPrices calls, 0 to probe until interrupted.")
+	fs.BoolVar(&conf.JSON, "json", false, "Print the probe results in JSON lines instead of a table.")
+	fs.IntVar(&logLevel, "logLevel", logLevel, "Logging verbosity: 0: NoLevel, 1: Trace, 2: Debug, 3: Info, 4: Warn, 5: Error")
+	fs.StringVar(&flagConf.Key, "key", "", "The API key of the data provider, it overrides the one from the config file.")
+	fs.StringVar(&flagConf.Scheme, "scheme", "", "The data service scheme, it overrides the one from the config file.")
+	fs.StringVar(&flagConf.Endpoint, "endpoint", "", "The data service endpoint, it overrides the one from the config file.")
+	fs.IntVar(&flagConf.Timeout, "timeout", 0, "The request timeout in seconds, it overrides the one from the config file.")
+	fs.IntVar(&flagConf.DataUpdateInterval, "refresh", 0, "The data refresh interval in seconds, it overrides the one from the config file.")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, ErrMissingBinary
+	}
+
+	binary := fs.Arg(0)
+	conf.PluginDIR = filepath.Dir(binary)
+	conf.Name = filepath.Base(binary)
+	conf.LoggingLevel = hclog.Level(logLevel) //nolint
+
+	if configFile != "" {
+		pluginConfigs, err := config.LoadPluginsConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.PluginConfig = pluginConfigs[conf.Name]
+	}
+	conf.PluginConfig.Name = conf.Name
+	overridePluginConf(&conf.PluginConfig, &flagConf)
+
+	for _, s := range strings.Split(symbols, ",") {
+		if sym := strings.TrimSpace(s); sym != "" {
+			conf.Symbols = append(conf.Symbols, sym)
+		}
+	}
+	return conf, nil
+}
+
+func overridePluginConf(conf *config.PluginConfig, flagConf *config.PluginConfig) {
+	if flagConf.Key != "" {
+		conf.Key = flagConf.Key
+	}
+	if flagConf.Scheme != "" {
+		conf.Scheme = flagConf.Scheme
+	}
+	if flagConf.Endpoint != "" {
+		conf.Endpoint = flagConf.Endpoint
+	}
+	if flagConf.Timeout != 0 {
+		conf.Timeout = flagConf.Timeout
+	}
+	if flagConf.DataUpdateInterval != 0 {
+		conf.DataUpdateInterval = flagConf.DataUpdateInterval
+	}
+}
+
+// Prober launches a single plugin through the same plugin wrapper used by the oracle server, and it fetches prices
+// from it on a schedule without any L1 connectivity.
+type Prober struct {
+	conf    *Config
+	out     io.Writer
+	wrapper *pWrapper.PluginWrapper
+
+	// the probe never emits sample events, the plugin wrapper is driven by the prober directly.
+	sampleEventFeed event.Feed
+}
+
+func New(conf *Config, out io.Writer) *Prober {
+	return &Prober{conf: conf, out: out}
+}
+
+func (p *Prober) WatchSampleEvent(sink chan<- *types.SampleEvent) event.Subscription {
+	return p.sampleEventFeed.Subscribe(sink)
+}
+
+// Run starts the plugin, prints its statement and probes it with the configured rounds. The plugin is stopped once the
+// probing is done, or once the done channel is closed.
+func (p *Prober) Run(done <-chan struct{}) error {
+	// set the plugin configuration via system env, thus the plugin can load it on startup.
+	conf, err := json.Marshal(&p.conf.PluginConfig)
+	if err != nil {
+		return err
+	}
+	if err = os.Setenv(p.conf.Name, string(conf)); err != nil {
+		return err
+	}
+
+	logging.SetLevels(p.conf.LoggingLevel, config.LogLevels{})
+	p.wrapper = pWrapper.NewPluginWrapper(p.conf.Name, p.conf.PluginDIR, p, &p.conf.PluginConfig)
+	if err = p.wrapper.Initialize(p.conf.ChainID); err != nil {
+		p.wrapper.CleanPluginProcess()
+		return fmt.Errorf("cannot initialize plugin %s: %w", p.conf.Name, err)
+	}
+	defer p.wrapper.Close()
+
+	statement := p.wrapper.Statement()
+	p.printStatement(&statement)
+
+	symbols := p.conf.Symbols
+	if len(symbols) == 0 {
+		symbols = statement.AvailableSymbols
+	}
+
+	ticker := time.NewTicker(p.conf.Interval)
+	defer ticker.Stop()
+	for round := 1; ; round++ {
+		result := p.probe(round, symbols)
+		p.printResult(&result)
+
+		if p.conf.Rounds > 0 && round >= p.conf.Rounds {
+			return nil
+		}
+
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Prober) probe(round int, symbols []string) Result {
+	result := Result{Round: round, At: time.Now()}
+	report, err := p.wrapper.FetchPrices(symbols)
+	result.Latency = time.Since(result.At)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	result.UnRecognizableSymbols = report.UnRecognizableSymbols
+	for _, price := range report.Prices {
+		pp := ProbedPrice{
+			Symbol:    price.Symbol,
+			Price:     price.Price.String(),
+			Timestamp: price.Timestamp,
+		}
+		if price.Volume != nil {
+			pp.Volume = price.Volume.String()
+		}
+		result.Prices = append(result.Prices, pp)
+	}
+	return result
+}
+
+func (p *Prober) printStatement(statement *types.PluginStatement) {
+	if p.conf.JSON {
+		p.printJSON(statement)
+		return
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PLUGIN\t%s\n", p.conf.Name)
+	fmt.Fprintf(w, "VERSION\t%s\n", statement.Version)
+	fmt.Fprintf(w, "DATA SOURCE\t%s\n", statement.DataSource)
+	fmt.Fprintf(w, "SOURCE TYPE\t%s\n", dataSourceTypeName(statement.DataSourceType))
+	fmt.Fprintf(w, "KEY REQUIRED\t%t\n", statement.KeyRequired)
+	fmt.Fprintf(w, "SYMBOLS\t%s\n\n", strings.Join(statement.AvailableSymbols, ","))
+	w.Flush() //nolint
+}
+
+func (p *Prober) printResult(result *Result) {
+	if p.conf.JSON {
+		p.printJSON(result)
+		return
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ROUND\tAT\tLATENCY\tSYMBOL\tPRICE\tVOLUME\tTIMESTAMP\n")
+	for _, price := range result.Prices {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\n", result.Round, result.At.Format(time.RFC3339),
+			result.Latency.Round(time.Millisecond), price.Symbol, price.Price, price.Volume, price.Timestamp)
+	}
+	if len(result.Prices) == 0 {
+		fmt.Fprintf(w, "%d\t%s\t%s\t-\t-\t-\t-\n", result.Round, result.At.Format(time.RFC3339),
+			result.Latency.Round(time.Millisecond))
+	}
+	if len(result.UnRecognizableSymbols) > 0 {
+		fmt.Fprintf(w, "UNRECOGNIZABLE\t%s\n", strings.Join(result.UnRecognizableSymbols, ","))
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "ERROR\t%s\n", result.Error)
+	}
+	fmt.Fprintln(w)
+	w.Flush() //nolint
+}
+
+func (p *Prober) printJSON(v interface{}) {
+	if err := json.NewEncoder(p.out).Encode(v); err != nil {
+		fmt.Fprintf(p.out, "cannot encode probe output: %s\n", err.Error())
+	}
+}
+
+func dataSourceTypeName(t types.DataSourceType) string {
+	switch t {
+	case types.SrcAMM:
+		return "AMM"
+	case types.SrcCEX:
+		return "CEX"
+	case types.SrcAFQ:
+		return "AFQ"
+	default:
+		return fmt.Sprintf("unknown(%d)", t)
+	}
+}
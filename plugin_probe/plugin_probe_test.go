@@ -0,0 +1,67 @@
+package pluginprobe
+
+import (
+	"autonity-oracle/helpers"
+	"autonity-oracle/types"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const templatePlugin = "../plugins/template_plugin/bin/template_plugin"
+
+func TestMakeConfig(t *testing.T) {
+	t.Run("missing plugin binary", func(t *testing.T) {
+		_, err := MakeConfig([]string{"-rounds", "2"})
+		require.ErrorIs(t, err, ErrMissingBinary)
+	})
+
+	t.Run("flags override the plugin config", func(t *testing.T) {
+		conf, err := MakeConfig([]string{"-symbols", "EUR-USD, JPY-USD,", "-rounds", "0", "-json", "-logLevel", "2",
+			"-endpoint", "localhost:8080", "-timeout", "5", templatePlugin})
+		require.NoError(t, err)
+		require.Equal(t, "../plugins/template_plugin/bin", conf.PluginDIR)
+		require.Equal(t, "template_plugin", conf.Name)
+		require.Equal(t, []string{"EUR-USD", "JPY-USD"}, conf.Symbols)
+		require.Equal(t, 0, conf.Rounds)
+		require.Equal(t, true, conf.JSON)
+		require.Equal(t, hclog.Debug, conf.LoggingLevel)
+		require.Equal(t, "template_plugin", conf.PluginConfig.Name)
+		require.Equal(t, "localhost:8080", conf.PluginConfig.Endpoint)
+		require.Equal(t, 5, conf.PluginConfig.Timeout)
+	})
+}
+
+func TestProber(t *testing.T) {
+	conf, err := MakeConfig([]string{"-symbols", "EUR-USD,NTN-USDC,FOO-BAR", "-rounds", "2", "-interval", "1s",
+		"-json", templatePlugin})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	prober := New(conf, &out)
+	require.NoError(t, prober.Run(make(chan struct{})))
+
+	scanner := bufio.NewScanner(&out)
+	require.True(t, scanner.Scan())
+	var statement types.PluginStatement
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &statement))
+	require.Equal(t, types.SrcCEX, statement.DataSourceType)
+	require.Contains(t, statement.AvailableSymbols, "EUR-USD")
+
+	for round := 1; round <= conf.Rounds; round++ {
+		require.True(t, scanner.Scan())
+		var result Result
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		require.Equal(t, round, result.Round)
+		require.Empty(t, result.Error)
+		require.Equal(t, []string{"FOO-BAR"}, result.UnRecognizableSymbols)
+		require.Len(t, result.Prices, 2)
+		for _, p := range result.Prices {
+			require.Equal(t, helpers.ResolveSimulatedPrice(p.Symbol).String(), p.Price)
+		}
+	}
+	require.False(t, scanner.Scan())
+}
@@ -3,6 +3,7 @@ package pluginwrapper
 import (
 	"autonity-oracle/config"
 	"autonity-oracle/helpers"
+	"autonity-oracle/logging"
 	"autonity-oracle/types"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -12,7 +13,6 @@ import (
 	"github.com/hashicorp/go-plugin"
 	"github.com/shopspring/decimal"
 	"math/big"
-	"os"
 	"os/exec"
 	"strings"
 	"sync"
@@ -27,6 +27,7 @@ var (
 // plugin, buffers recent data samples measured from the corresponding plugin.
 type PluginWrapper struct {
 	version          string
+	statement        types.PluginStatement
 	conf             *config.PluginConfig
 	dataSrcType      types.DataSourceType
 	lockService      sync.RWMutex
@@ -49,13 +50,9 @@ type PluginWrapper struct {
 	priceMetrics map[string]metrics.GaugeFloat64
 }
 
-func NewPluginWrapper(logLevel hclog.Level, name string, pluginDir string, sub types.SampleEventSubscriber, conf *config.PluginConfig) *PluginWrapper {
-	// Create a hclog.Logger
-	logger := hclog.New(&hclog.LoggerOptions{
-		Name:   name,
-		Output: os.Stdout,
-		Level:  logLevel,
-	})
+func NewPluginWrapper(name string, pluginDir string, sub types.SampleEventSubscriber, conf *config.PluginConfig) *PluginWrapper {
+	// the logs of the plugin process are forwarded by the plugin's logger.
+	logger := logging.Plugin(name)
 
 	// pluginMap is the map of plugins we can dispense.
 	var pluginMap = map[string]plugin.Plugin{
@@ -116,25 +113,44 @@ func (pw *PluginWrapper) AggregatedPrice(symbol string, target int64) (types.Pri
 	if !ok {
 		return types.Price{}, types.ErrNoAvailablePrice
 	}
+	return AggregateSamples(tsMap, pw.dataSrcType, symbol, target, pw.logger)
+}
+
+// AggregateSamples aggregates the samples of a symbol keyed by the TS of the sample events by the data source type,
+// it is shared by the plugin wrappers and by the offline replay of the recorded samples.
+func AggregateSamples(tsMap map[int64]types.Price, srcType types.DataSourceType, symbol string, target int64,
+	logger hclog.Logger) (types.Price, error) {
+	if len(tsMap) == 0 {
+		return types.Price{}, types.ErrNoAvailablePrice
+	}
 
 	// for AMMs or AFQs, as the data points may move quickly, thus we get the VWAP of
 	// the collected samples of the recent pre-sampling period.
-	if pw.dataSrcType == types.SrcAMM || pw.dataSrcType == types.SrcAFQ {
+	if srcType == types.SrcAMM || srcType == types.SrcAFQ {
 		var prices []decimal.Decimal
 		var volumes []*big.Int
-		for _, sample := range tsMap {
+		var latest int64
+		var latestSample types.Price
+		for ts, sample := range tsMap {
 			prices = append(prices, sample.Price)
 			volumes = append(volumes, sample.Volume)
+			if ts > latest {
+				latest = ts
+				latestSample = sample
+			}
 		}
 
 		vwap, highestVol, err := helpers.VWAP(prices, volumes)
 		if err != nil {
-			pw.logger.Error("failed to calculate vwap", "symbol", symbol, "err", err)
+			logger.Error("failed to calculate vwap", logging.KeySymbol, symbol, "error", err)
 			return types.Price{}, err
 		}
 
-		pw.logger.Debug("VWAP aggregation", "symbol", symbol, "samples", len(tsMap), "vwap", vwap.String())
-		return types.Price{Symbol: symbol, Price: vwap, Timestamp: target, Volume: highestVol}, nil
+		logger.Debug("VWAP aggregation", logging.KeySymbol, symbol, "samples", len(tsMap), "vwap", vwap.String())
+		// the VWAP is as fresh as the latest sample of it, and it takes the provenance of the latest sample.
+		return types.Price{Symbol: symbol, Price: vwap, Timestamp: latest, Volume: highestVol,
+			SourceTimestamp: latestSample.SourceTimestamp, Venue: latestSample.Venue, Bid: latestSample.Bid,
+			Ask: latestSample.Ask}, nil
 	}
 
 	// for CEX, we just need to take the last sample as data points from CEX were already aggregated.
@@ -165,10 +181,21 @@ func (pw *PluginWrapper) AggregatedPrice(symbol string, target int64) (types.Pri
 	}
 
 	price := tsMap[nearestKey]
-	pw.logger.Debug("nearest sample", "symbol", symbol, "samples", len(tsMap), "targetTS", target, "nearestTS", nearestKey, "price", price)
+	logger.Debug("nearest sample", logging.KeySymbol, symbol, "samples", len(tsMap), "targetTS", target, "nearestTS", nearestKey, "price", price)
 	return price, nil
 }
 
+// Samples returns a copy of the buffered samples of a symbol, they are keyed by the TS of the sample events.
+func (pw *PluginWrapper) Samples(symbol string) map[int64]types.Price {
+	pw.lockSamples.RLock()
+	defer pw.lockSamples.RUnlock()
+	samples := make(map[int64]types.Price, len(pw.samples[symbol]))
+	for ts, p := range pw.samples[symbol] {
+		samples[ts] = p
+	}
+	return samples
+}
+
 // GCExpiredSamples removes data points that are older than the TTL seconds of per plugin, it leaves recent samples
 // together with next round's pre-samples as the input for the price aggregation for AMM, AFQ plugins. While, for CEX
 // plugins, only the latest sample are kept without GC.
@@ -221,6 +248,19 @@ func (pw *PluginWrapper) StartTime() time.Time {
 	return pw.startAt
 }
 
+// Statement returns the plugin's statement which was resolved on the initialization of the plugin.
+func (pw *PluginWrapper) Statement() types.PluginStatement {
+	return pw.statement
+}
+
+// FetchPrices fetches the prices of symbols from the plugin directly, the samples are not buffered into the plugin
+// wrapper, thus it won't affect the price aggregation. It is used to test a plugin in isolation.
+func (pw *PluginWrapper) FetchPrices(symbols []string) (types.PluginPriceReport, error) {
+	pw.lockService.Lock()
+	defer pw.lockService.Unlock()
+	return pw.adapter.FetchPrices(symbols)
+}
+
 // Initialize start the plugin, connect to it and do a handshake via state() interface.
 func (pw *PluginWrapper) Initialize(chainID int64) error {
 	// start the plugin process and connect to it
@@ -247,6 +287,7 @@ func (pw *PluginWrapper) Initialize(chainID int64) error {
 	}
 	pw.dataSrcType = state.DataSourceType
 	pw.version = state.Version
+	pw.statement = state
 	if state.KeyRequired && pw.conf.Key == "" {
 		return types.ErrMissingServiceKey
 	}
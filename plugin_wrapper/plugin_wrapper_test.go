@@ -5,6 +5,7 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
+	"math/big"
 	"testing"
 	"time"
 )
@@ -78,4 +79,26 @@ func TestPluginWrapper(t *testing.T) {
 		p.GCExpiredSamples()
 		require.Equal(t, 1, len(p.samples))
 	})
+	t.Run("VWAP takes the provenance of the latest sample", func(t *testing.T) {
+		p := PluginWrapper{
+			logger:           hclog.NewNullLogger(),
+			samples:          make(map[string]map[int64]types.Price),
+			latestTimestamps: make(map[string]int64),
+			dataSrcType:      types.SrcAMM,
+		}
+
+		bid := decimal.RequireFromString("0.99")
+		p.AddSample([]types.Price{{Timestamp: 100, Symbol: "NTN-USDC", Price: decimal.RequireFromString("1"),
+			Volume: big.NewInt(1), SourceTimestamp: 98, Venue: "old-pool"}}, 100)
+		p.AddSample([]types.Price{{Timestamp: 110, Symbol: "NTN-USDC", Price: decimal.RequireFromString("2"),
+			Volume: big.NewInt(1), SourceTimestamp: 109, Venue: "pool", Bid: &bid}}, 110)
+
+		price, err := p.AggregatedPrice("NTN-USDC", 120)
+		require.NoError(t, err)
+		require.Equal(t, "1.5", price.Price.String())
+		require.Equal(t, int64(110), price.Timestamp)
+		require.Equal(t, int64(109), price.SourceTimestamp)
+		require.Equal(t, "pool", price.Venue)
+		require.Equal(t, &bid, price.Bid)
+	})
 }
@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/url"
 	"os"
-	"time"
 )
 
 const (
@@ -33,7 +32,7 @@ type BIClient struct {
 }
 
 func NewBIClient(conf *config.PluginConfig) *BIClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   conf.Name,
 		Level:  hclog.Info,
@@ -1,11 +1,30 @@
 package common
 
 import (
+	"autonity-oracle/config"
+	"bytes"
+	"context"
+	"github.com/hashicorp/go-hclog"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	DefaultRetries   = 2 // the retries of a failed idempotent request if they are not configured.
+	DefaultRateBurst = 1 // the burst of the rate limiter of a host if it is not configured.
+
+	minRetryDelay = 250 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+	statsInterval = 5 * time.Minute // the interval of the request metrics reported by the log.
+)
+
 type Connection interface {
 	Request(scheme string, endpoint *url.URL) (*http.Response, error)
 	Do(req *http.Request) (*http.Response, error)
@@ -19,21 +38,98 @@ type DataSourceClient interface {
 	Close()
 }
 
+// ConnectionOptions configures the resilience of a connection: the retries of the idempotent requests, the rate limit
+// of the host, and the max wait of a request for the rate limit or for the Retry-After of the host.
+type ConnectionOptions struct {
+	Retries   int           // the retries of a failed idempotent request, 0 disables them.
+	RateLimit float64       // the max requests per second to the host, 0 disables it.
+	RateBurst int           // the burst of the requests to the host.
+	MaxWait   time.Duration // the max wait of a request, it is the timeout of the connection if it is 0.
+	Logger    hclog.Logger  // the logger of the request metrics, they are reported at info level periodically.
+}
+
+// RequestStats is the per connection metrics of the requests.
+type RequestStats struct {
+	Requests    uint64        // the requests sent to the host, including the retries.
+	Retries     uint64        // the retries of the failed requests.
+	Failures    uint64        // the requests failed with an error or with a retryable status.
+	RateLimited uint64        // the requests rejected by the rate limit or by the Retry-After of the host.
+	NotModified uint64        // the requests served from the ETag cache.
+	Latency     time.Duration // the total latency of the requests sent to the host.
+}
+
+// cachedResponse is the last response of an URL with an ETag, it serves the request once the data is not modified.
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
 type connection struct {
-	client *http.Client
-	host   string
+	client  *http.Client
+	timeout time.Duration // the timeout of a request including its retries.
+	host    string
+	opts    ConnectionOptions
+	limiter *hostLimiter
+	logger  hclog.Logger
+
+	cacheMu sync.Mutex
+	cache   map[string]*cachedResponse
+
+	requests, retries, failures, rateLimited, notModified, latency uint64
+	reportedAt                                                     int64 // the unix nano time of the last metrics report.
 }
 
 func NewConnection(duration time.Duration, host string) Connection {
+	return NewConnectionWithOptions(duration, host, ConnectionOptions{Retries: DefaultRetries})
+}
+
+// NewConnectionWithOptions returns a connection to the host, the requests of all the connections to the host in the
+// process share the rate limit and the Retry-After of the host.
+func NewConnectionWithOptions(duration time.Duration, host string, opts ConnectionOptions) Connection {
 	client := &http.Client{
 		Timeout: duration,
 	}
+	if opts.MaxWait == 0 {
+		opts.MaxWait = duration
+	}
+	if opts.RateBurst <= 0 {
+		opts.RateBurst = DefaultRateBurst
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
 
 	return &connection{
-		client: client,
-		host:   host,
+		client:     client,
+		timeout:    duration,
+		host:       host,
+		opts:       opts,
+		limiter:    limiterOf(host, opts.RateLimit, opts.RateBurst),
+		logger:     logger,
+		cache:      make(map[string]*cachedResponse),
+		reportedAt: time.Now().UnixNano(),
 	}
 }
+
+// ConnectionOptionsOf resolves the connection options from the plugin config.
+func ConnectionOptionsOf(conf *config.PluginConfig, logger hclog.Logger) ConnectionOptions {
+	retries := conf.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return ConnectionOptions{
+		Retries:   retries,
+		RateLimit: conf.RateLimit,
+		RateBurst: conf.RateBurst,
+		Logger:    logger,
+	}
+}
+
 func (conn *connection) Close() {
 	if conn.client != nil {
 		conn.client.CloseIdleConnections()
@@ -43,11 +139,324 @@ func (conn *connection) Request(scheme string, endpoint *url.URL) (*http.Respons
 	endpoint.Scheme = scheme
 	endpoint.Host = conn.host
 	targetUrl := endpoint.String()
-	return conn.client.Get(targetUrl)
+	req, err := http.NewRequest(http.MethodGet, targetUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Do(req)
 }
 
+// Do sends a request under the rate limit of the host. An idempotent request is retried with a jittered backoff, or
+// after the Retry-After of the host, once it fails with an error or with a retryable status, and a GET request is
+// conditional with the ETag of the last response of its URL. All the attempts of a request share the timeout of the
+// connection, thus the retries do not extend the time of a request.
 func (conn *connection) Do(req *http.Request) (*http.Response, error) {
-	return conn.client.Do(req)
+	defer conn.reportStats()
+	if conn.timeout <= 0 {
+		return conn.do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), conn.timeout)
+	res, err := conn.do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the deadline is kept until the body is read.
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (conn *connection) do(req *http.Request) (*http.Response, error) {
+	idempotent := req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead
+	attempts := 1
+	if idempotent {
+		attempts += conn.opts.Retries
+	}
+
+	key := req.URL.String()
+	var cached *cachedResponse
+	if req.Method == "" || req.Method == http.MethodGet {
+		conn.cacheMu.Lock()
+		cached = conn.cache[key]
+		conn.cacheMu.Unlock()
+		if cached != nil && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		} else {
+			cached = nil
+		}
+	}
+
+	var res *http.Response
+	var err error
+	var delay time.Duration
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if err = sleep(req, delay); err != nil {
+				return nil, err
+			}
+			atomic.AddUint64(&conn.retries, 1)
+		}
+
+		if err = conn.limiter.wait(req, conn.opts.MaxWait); err != nil {
+			atomic.AddUint64(&conn.rateLimited, 1)
+			conn.logger.Debug("http request is rate limited", "host", req.URL.Host, "path", req.URL.Path, "error", err)
+			return nil, err
+		}
+
+		start := time.Now()
+		res, err = conn.client.Do(req)
+		elapsed := time.Since(start)
+		atomic.AddUint64(&conn.requests, 1)
+		atomic.AddUint64(&conn.latency, uint64(elapsed))
+		if err != nil {
+			atomic.AddUint64(&conn.failures, 1)
+			conn.logger.Debug("http request", "host", req.URL.Host, "path", req.URL.Path, "attempt", i+1,
+				"latency", elapsed, "error", err)
+			if delay = retryDelay(i + 1); !idempotent || i == attempts-1 || !conn.retriable(req, delay) {
+				return nil, err
+			}
+			continue
+		}
+		conn.logger.Debug("http request", "host", req.URL.Host, "path", req.URL.Path, "attempt", i+1,
+			"latency", elapsed, "status", res.StatusCode)
+
+		if !retryableStatus(res.StatusCode) {
+			break
+		}
+		atomic.AddUint64(&conn.failures, 1)
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+		if retryAfter > 0 {
+			conn.limiter.block(retryAfter)
+		}
+		// the response is returned once it cannot be retried within the max wait and the timeout.
+		if delay = retryDelay(i + 1); retryAfter > delay {
+			delay = retryAfter
+		}
+		if !idempotent || i == attempts-1 || !conn.retriable(req, delay) {
+			break
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	return conn.conditional(req, key, cached, res)
+}
+
+// conditional serves a not modified response from the ETag cache, and it caches an OK response with an ETag.
+func (conn *connection) conditional(req *http.Request, key string, cached *cachedResponse, res *http.Response) (*http.Response, error) {
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		res.Body.Close()
+		atomic.AddUint64(&conn.notModified, 1)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         res.Proto,
+			ProtoMajor:    res.ProtoMajor,
+			ProtoMinor:    res.ProtoMinor,
+			Header:        cached.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       req,
+		}, nil
+	}
+
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" || (req.Method != "" && req.Method != http.MethodGet) {
+		return res, nil
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	conn.cacheMu.Lock()
+	conn.cache[key] = &cachedResponse{etag: etag, header: res.Header.Clone(), body: body}
+	conn.cacheMu.Unlock()
+	return res, nil
+}
+
+// retriable tells if a request can be retried after the delay, within the max wait and before its deadline.
+func (conn *connection) retriable(req *http.Request, delay time.Duration) bool {
+	if delay > conn.opts.MaxWait {
+		return false
+	}
+	deadline, ok := req.Context().Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// stats returns the metrics of the requests of the connection.
+func (conn *connection) stats() RequestStats {
+	return RequestStats{
+		Requests:    atomic.LoadUint64(&conn.requests),
+		Retries:     atomic.LoadUint64(&conn.retries),
+		Failures:    atomic.LoadUint64(&conn.failures),
+		RateLimited: atomic.LoadUint64(&conn.rateLimited),
+		NotModified: atomic.LoadUint64(&conn.notModified),
+		Latency:     time.Duration(atomic.LoadUint64(&conn.latency)),
+	}
+}
+
+// reportStats logs the metrics of the requests once per stats interval.
+func (conn *connection) reportStats() {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&conn.reportedAt)
+	if time.Duration(now-last) < statsInterval || !atomic.CompareAndSwapInt64(&conn.reportedAt, last, now) {
+		return
+	}
+
+	s := conn.stats()
+	var latency time.Duration
+	if s.Requests > 0 {
+		latency = s.Latency / time.Duration(s.Requests)
+	}
+	conn.logger.Info("http request stats", "host", conn.host, "requests", s.Requests, "retries", s.Retries,
+		"failures", s.Failures, "rate limited", s.RateLimited, "not modified", s.NotModified, "avg latency", latency)
+}
+
+// cancelBody is the body of a response, it cancels the context of the request once it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryableStatus tells if a status is transient: the rate limit, or the unavailability of the server.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the jittered exponential backoff of a retry, it is a random delay in the upper half of the backoff.
+func retryDelay(retry int) time.Duration {
+	backoff := minRetryDelay << (retry - 1)
+	if backoff > maxRetryDelay || backoff <= 0 {
+		backoff = maxRetryDelay
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// parseRetryAfter parses the Retry-After header in seconds or in a HTTP date, it is 0 if there is no such header.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleep waits for the delay, it returns the error of the request context if it is done earlier.
+func sleep(req *http.Request, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// hostLimiter is the token bucket of a host, and it holds the requests to the host until its Retry-After.
+type hostLimiter struct {
+	mu           sync.Mutex
+	rate         float64 // the tokens per second, 0 disables the bucket.
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+var hostLimiters = struct {
+	sync.Mutex
+	limiters map[string]*hostLimiter
+}{limiters: make(map[string]*hostLimiter)}
+
+// limiterOf returns the limiter of a host, the rate limit of the host is updated by the latest configured one.
+func limiterOf(host string, rate float64, burst int) *hostLimiter {
+	hostLimiters.Lock()
+	defer hostLimiters.Unlock()
+	l, ok := hostLimiters.limiters[host]
+	if !ok {
+		l = &hostLimiter{tokens: float64(burst), last: time.Now()}
+		hostLimiters.limiters[host] = l
+	}
+	if rate > 0 || !ok {
+		l.mu.Lock()
+		l.rate, l.burst = rate, float64(burst)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.mu.Unlock()
+	}
+	return l
+}
+
+// wait takes a token of the host, it waits for the token and for the Retry-After of the host, the request is rejected
+// if the wait is longer than the max wait.
+func (l *hostLimiter) wait(req *http.Request, maxWait time.Duration) error {
+	l.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if l.blockedUntil.After(now) {
+		delay = l.blockedUntil.Sub(now)
+	}
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens < 1 {
+			if d := time.Duration((1 - l.tokens) / l.rate * float64(time.Second)); d > delay {
+				delay = d
+			}
+		}
+	}
+	if delay > maxWait {
+		l.mu.Unlock()
+		return ErrAccessLimited
+	}
+	// the token is taken in advance, thus the waiting requests are queued.
+	if l.rate > 0 {
+		l.tokens--
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		return sleep(req, delay)
+	}
+	return nil
+}
+
+// block holds the requests to the host for the Retry-After of the host.
+func (l *hostLimiter) block(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(retryAfter); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
 }
 
 type Client struct {
@@ -59,6 +468,18 @@ func NewClient(apiKey string, timeOut time.Duration, host string) *Client {
 	return NewClientConnection(apiKey, NewConnection(timeOut, host))
 }
 
+// NewPluginClient returns the client of the endpoint of a plugin, its retries and its rate limit are resolved from
+// the plugin config.
+func NewPluginClient(conf *config.PluginConfig) *Client {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+	conn := NewConnectionWithOptions(time.Second*time.Duration(conf.Timeout), conf.Endpoint, ConnectionOptionsOf(conf, logger))
+	return NewClientConnection(conf.Key, conn)
+}
+
 func NewClientConnection(apiKey string, connection Connection) *Client {
 	return &Client{
 		Conn:   connection,
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"bytes"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConnection returns a connection to a test server of the handler, each test server is a new host thus it has its
+// own rate limiter.
+func testConnection(t *testing.T, handler http.HandlerFunc, opts ConnectionOptions) (Connection, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+	conn := NewConnectionWithOptions(5*time.Second, host, opts)
+	t.Cleanup(conn.Close)
+	return conn, host
+}
+
+func get(conn Connection) (*http.Response, error) {
+	return conn.Request("http", &url.URL{Path: "/prices", RawQuery: "symbol=USDC-USD"})
+}
+
+func readBody(t *testing.T, res *http.Response) string {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestConnection(t *testing.T) {
+	t.Run("transient failures of a GET request are retried", func(t *testing.T) {
+		var hits int32
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&hits, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("1.0"))
+		}, ConnectionOptions{Retries: 2})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "1.0", readBody(t, res))
+
+		stats := conn.(*connection).stats()
+		require.Equal(t, uint64(3), stats.Requests)
+		require.Equal(t, uint64(2), stats.Retries)
+		require.Equal(t, uint64(2), stats.Failures)
+		require.Positive(t, stats.Latency)
+	})
+
+	t.Run("the last failure is returned once the retries are exhausted", func(t *testing.T) {
+		var hits int32
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}, ConnectionOptions{Retries: 1})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		res.Body.Close()
+		require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("the retries share the timeout of the connection", func(t *testing.T) {
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			time.Sleep(400 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+		conn := NewConnectionWithOptions(time.Second, strings.TrimPrefix(server.URL, "http://"), ConnectionOptions{Retries: 5})
+		t.Cleanup(conn.Close)
+
+		start := time.Now()
+		res, err := get(conn)
+		if err == nil {
+			res.Body.Close()
+		}
+		require.Less(t, time.Since(start), 1500*time.Millisecond)
+		require.LessOrEqual(t, atomic.LoadInt32(&hits), int32(3))
+	})
+
+	t.Run("the request metrics are reported periodically", func(t *testing.T) {
+		var out bytes.Buffer
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("1.0"))
+		}, ConnectionOptions{Logger: hclog.New(&hclog.LoggerOptions{Output: &out, Level: hclog.Info})})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Empty(t, out.String())
+
+		atomic.StoreInt64(&conn.(*connection).reportedAt, time.Now().Add(-statsInterval).UnixNano())
+		res, err = get(conn)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Contains(t, out.String(), "http request stats")
+		require.Contains(t, out.String(), "requests=2")
+	})
+
+	t.Run("a POST request is not retried", func(t *testing.T) {
+		var hits int32
+		conn, host := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, ConnectionOptions{Retries: 2})
+
+		req, err := http.NewRequest(http.MethodPost, "http://"+host+"/orders", strings.NewReader("{}"))
+		require.NoError(t, err)
+		res, err := conn.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		res.Body.Close()
+		require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("the Retry-After of the host is respected", func(t *testing.T) {
+		var hits int32
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&hits, 1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte("1.0"))
+		}, ConnectionOptions{Retries: 2})
+
+		start := time.Now()
+		res, err := get(conn)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+		require.GreaterOrEqual(t, time.Since(start), time.Second)
+		require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("the host is not requested until the Retry-After beyond the max wait", func(t *testing.T) {
+		var hits int32
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}, ConnectionOptions{Retries: 2, MaxWait: time.Second})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		res.Body.Close()
+
+		_, err = get(conn)
+		require.ErrorIs(t, err, ErrAccessLimited)
+		require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+		require.Equal(t, uint64(1), conn.(*connection).stats().RateLimited)
+	})
+
+	t.Run("a not modified response is served from the ETag cache", func(t *testing.T) {
+		var hits int32
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(`{"price":"1.0"}`))
+		}, ConnectionOptions{})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		require.Equal(t, `{"price":"1.0"}`, readBody(t, res))
+
+		res, err = get(conn)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, `"v1"`, res.Header.Get("ETag"))
+		require.Equal(t, `{"price":"1.0"}`, readBody(t, res))
+		require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		require.Equal(t, uint64(1), conn.(*connection).stats().NotModified)
+	})
+
+	t.Run("the requests to the host are rate limited", func(t *testing.T) {
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("1.0"))
+		}, ConnectionOptions{RateLimit: 10, RateBurst: 1})
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			res, err := get(conn)
+			require.NoError(t, err)
+			res.Body.Close()
+		}
+		require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	})
+
+	t.Run("a request is rejected if the rate limit wait is beyond the max wait", func(t *testing.T) {
+		conn, _ := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("1.0"))
+		}, ConnectionOptions{RateLimit: 0.1, MaxWait: 100 * time.Millisecond})
+
+		res, err := get(conn)
+		require.NoError(t, err)
+		res.Body.Close()
+		_, err = get(conn)
+		require.ErrorIs(t, err, ErrAccessLimited)
+	})
+}
+
+func TestConnectionOptionsOf(t *testing.T) {
+	opts := ConnectionOptionsOf(&config.PluginConfig{RateLimit: 5, RateBurst: 2}, nil)
+	require.Equal(t, ConnectionOptions{Retries: DefaultRetries, RateLimit: 5, RateBurst: 2}, opts)
+
+	opts = ConnectionOptionsOf(&config.PluginConfig{Retries: -1}, nil)
+	require.Equal(t, 0, opts.Retries)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, time.Duration(0), parseRetryAfter(""))
+	require.Equal(t, 2*time.Second, parseRetryAfter("2"))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	at := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	require.True(t, at > 50*time.Second && at <= time.Minute)
+}
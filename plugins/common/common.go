@@ -35,12 +35,19 @@ const (
 	AutonityCryptoDecimals       = 18 // both NTN and the Wrapped ATN take 18 as the decimal.
 	USDCDecimals                 = 6  // the decimal of USDC coin in autonity L1 network.
 	CryptoToUsdcDecimals         = 18 // the data precision in oracle contract.
+
+	DefaultBackfillBlocks = 1800 // the swap history of 30 minutes in 1s blocks is backfilled by the AMM plugins.
 )
 
 type Price struct {
 	Symbol string `json:"symbol,omitempty"`
 	Price  string `json:"price,omitempty"`
 	Volume string `json:"volume,omitempty"` // recent accumulating trade volume in USDCx.
+
+	Timestamp int64  `json:"timestamp,omitempty"` // the quote TS of the data source in Unix time, the fetch TS is taken if it is 0.
+	Venue     string `json:"venue,omitempty"`     // the venue or the market identifier of the quote.
+	Bid       string `json:"bid,omitempty"`       // the optional best bid of the quote.
+	Ask       string `json:"ask,omitempty"`       // the optional best ask of the quote.
 }
 
 type Prices []Price
@@ -116,10 +123,17 @@ func (p *Plugin) FetchPrices(symbols []string) (types.PluginPriceReport, error)
 		}
 
 		pr := types.Price{
-			Timestamp: now,
-			Symbol:    availableSymMap[v.Symbol], // set the symbol with the symbol style used in oracle server side.
-			Price:     decPrice,
-			Volume:    decVol,
+			Timestamp:       now,
+			Symbol:          availableSymMap[v.Symbol], // set the symbol with the symbol style used in oracle server side.
+			Price:           decPrice,
+			Volume:          decVol,
+			SourceTimestamp: v.Timestamp,
+			Venue:           v.Venue,
+			Bid:             p.parseQuote(v.Bid),
+			Ask:             p.parseQuote(v.Ask),
+		}
+		if pr.SourceTimestamp == 0 {
+			pr.SourceTimestamp = now
 		}
 		p.cachePrices[v.Symbol] = pr
 		report.Prices = append(report.Prices, pr)
@@ -128,6 +142,19 @@ func (p *Plugin) FetchPrices(symbols []string) (types.PluginPriceReport, error)
 	return report, nil
 }
 
+// parseQuote parses the optional bid or ask of a quote, it returns nil if the source does not provide it.
+func (p *Plugin) parseQuote(quote string) *decimal.Decimal {
+	if quote == "" {
+		return nil
+	}
+	d, err := decimal.NewFromString(quote)
+	if err != nil {
+		p.logger.Warn("cannot convert quote string to decimal: ", "quote", quote, "error", err.Error())
+		return nil
+	}
+	return &d
+}
+
 func (p *Plugin) State(chainID int64) (types.PluginStatement, error) {
 	var state types.PluginStatement
 
@@ -242,6 +269,24 @@ func ConvertSymbol(src string, toSep string) string {
 	return strings.Join(subs, toSep)
 }
 
+// BackfillStart returns the first block of the swap history which is backfilled by the AMM plugins up to the head
+// block, it resumes from the block after the synced one, while it goes back no more than the backfill blocks. There is
+// nothing to backfill if it is after the head block.
+func BackfillStart(head, synced uint64, backfillBlocks int) uint64 {
+	if backfillBlocks <= 0 {
+		backfillBlocks = DefaultBackfillBlocks
+	}
+
+	var start uint64
+	if head >= uint64(backfillBlocks) {
+		start = head - uint64(backfillBlocks) + 1
+	}
+	if synced > 0 && synced+1 > start {
+		start = synced + 1
+	}
+	return start
+}
+
 func ResolveConf(cmd string, defConf *config.PluginConfig) *config.PluginConfig {
 
 	conf, err := LoadPluginConf(cmd)
@@ -290,6 +335,10 @@ func ResolveConf(cmd string, defConf *config.PluginConfig) *config.PluginConfig
 		conf.SwapAddress = defConf.SwapAddress
 	}
 
+	if len(conf.Pairs) == 0 {
+		conf.Pairs = defConf.Pairs
+	}
+
 	return conf
 }
 
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"autonity-oracle/config"
+	"autonity-oracle/types"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -32,3 +34,53 @@ func TestResolveSeparator(t *testing.T) {
 	symbol = "BTCUSD"
 	require.Equal(t, "", ResolveSeparator(symbol))
 }
+
+func TestBackfillStart(t *testing.T) {
+	// the backfill blocks up to the head, or from the genesis of a short chain.
+	require.Equal(t, uint64(8201), BackfillStart(10000, 0, 1800))
+	require.Equal(t, uint64(0), BackfillStart(100, 0, 1800))
+	require.Equal(t, uint64(8201), BackfillStart(10000, 0, 0))
+	// it resumes from the synced block, but it goes back no more than the backfill blocks.
+	require.Equal(t, uint64(9001), BackfillStart(10000, 9000, 1800))
+	require.Equal(t, uint64(8201), BackfillStart(10000, 100, 1800))
+	// nothing to backfill once it is synced to the head.
+	require.Greater(t, BackfillStart(10000, 10000, 1800), uint64(10000))
+}
+
+type fakeClient struct {
+	prices Prices
+}
+
+func (f *fakeClient) AvailableSymbols() ([]string, error) {
+	return []string{"USDC-USD", "EUR-USD"}, nil
+}
+func (f *fakeClient) FetchPrice([]string) (Prices, error) { return f.prices, nil }
+func (f *fakeClient) KeyRequired() bool                   { return false }
+func (f *fakeClient) Close()                              {}
+
+func TestFetchPricesProvenance(t *testing.T) {
+	client := &fakeClient{prices: Prices{
+		{Symbol: "USDC-USD", Price: "1.0001", Volume: "1", Timestamp: 1700000000, Venue: "USDCUSD", Bid: "1.0000",
+			Ask: "1.0002"},
+		{Symbol: "EUR-USD", Price: "1.08", Volume: "1", Bid: "invalid"},
+	}}
+	p := NewPlugin(&config.PluginConfig{Name: "test"}, client, "v0.0.1", types.SrcCEX, nil)
+	_, err := p.State(0)
+	require.NoError(t, err)
+
+	report, err := p.FetchPrices([]string{"USDC-USD", "EUR-USD"})
+	require.NoError(t, err)
+	require.Len(t, report.Prices, 2)
+
+	usdc := report.Prices[0]
+	require.Equal(t, int64(1700000000), usdc.SourceTimestamp)
+	require.Equal(t, "USDCUSD", usdc.Venue)
+	require.Equal(t, "1", usdc.Bid.String())
+	require.Equal(t, "1.0002", usdc.Ask.String())
+
+	// the fetch TS is taken without the quote TS of the data source, and the invalid quote is dropped.
+	eur := report.Prices[1]
+	require.Equal(t, eur.Timestamp, eur.SourceTimestamp)
+	require.Nil(t, eur.Bid)
+	require.Nil(t, eur.Ask)
+}
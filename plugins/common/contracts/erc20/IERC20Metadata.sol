@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: MIT
+pragma solidity >=0.5.0;
+
+/// @dev Interface for the optional metadata functions and the balance of the ERC20 standard.
+interface IERC20Metadata {
+    /// @dev Returns the amount of tokens owned by `account`.
+    function balanceOf(address account) external view returns (uint256);
+
+    /// @dev Returns the name of the token.
+    function name() external view returns (string memory);
+
+    /// @dev Returns the symbol of the token.
+    function symbol() external view returns (string memory);
+
+    /// @dev Returns the decimals places of the token.
+    function decimals() external view returns (uint8);
+}
@@ -0,0 +1,374 @@
+// Package simchain provides a simulated EVM chain for the tests of the on-chain plugins. The contracts of a test are
+// deployed at genesis with the mock contract below, its views return the outputs stored for their call data, and its
+// events are emitted by the transactions of the test, thus the plugins are tested with their contract bindings against
+// the go-ethereum simulated backend.
+package simchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/asm"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	gasLimit   = 30_000_000 // the gas limit of the blocks.
+	txGasLimit = 1_000_000  // the gas limit of the emitting transactions.
+
+	emitSelector  = 0xffffff01
+	routeSelector = 0xffffff02
+)
+
+// mockSource is the assembly of the mock contract, it is compiled by the go-ethereum assembler. The selector 0xffffff01
+// emits an event of the call data: the count of the topics in a word, the topics and the event data. The selector
+// 0xffffff02 routes the calls of the call data in order: the contract address in a word, the payload length in a word
+// and the payload, thus a transaction emits the events of many contracts. Any other call is a view, it returns the
+// words stored at the keccak256 of its call data plus 1 to n, where n is stored at the keccak256 itself.
+const mockSource = `
+	PUSH 0
+	CALLDATALOAD
+	PUSH 224
+	SHR
+	DUP1
+	PUSH 0xffffff01
+	EQ
+	JUMPI @emit
+	PUSH 0xffffff02
+	EQ
+	JUMPI @route
+
+	;; view: [h, n, i]
+	CALLDATASIZE
+	PUSH 0
+	PUSH 0
+	CALLDATACOPY
+	CALLDATASIZE
+	PUSH 0
+	KECCAK256
+	DUP1
+	SLOAD
+	PUSH 0
+view_loop:
+	DUP2
+	DUP2
+	LT
+	ISZERO
+	JUMPI @view_return
+	DUP1
+	DUP4
+	ADD
+	PUSH 1
+	ADD
+	SLOAD
+	DUP2
+	PUSH 32
+	MUL
+	MSTORE
+	PUSH 1
+	ADD
+	JUMP @view_loop
+view_return:
+	POP
+	PUSH 32
+	MUL
+	PUSH 0
+	RETURN
+
+	;; emit: [n, data offset, data length]
+emit:
+	POP
+	PUSH 4
+	CALLDATALOAD
+	DUP1
+	PUSH 32
+	MUL
+	PUSH 36
+	ADD
+	DUP1
+	CALLDATASIZE
+	SUB
+	DUP1
+	DUP3
+	PUSH 0
+	CALLDATACOPY
+	DUP3
+	PUSH 0
+	EQ
+	JUMPI @log0
+	DUP3
+	PUSH 1
+	EQ
+	JUMPI @log1
+	DUP3
+	PUSH 2
+	EQ
+	JUMPI @log2
+	DUP3
+	PUSH 3
+	EQ
+	JUMPI @log3
+	DUP3
+	PUSH 4
+	EQ
+	JUMPI @log4
+	JUMP @fail
+log0:
+	DUP1
+	PUSH 0
+	LOG0
+	STOP
+log1:
+	PUSH 36
+	CALLDATALOAD
+	DUP2
+	PUSH 0
+	LOG1
+	STOP
+log2:
+	PUSH 68
+	CALLDATALOAD
+	PUSH 36
+	CALLDATALOAD
+	DUP3
+	PUSH 0
+	LOG2
+	STOP
+log3:
+	PUSH 100
+	CALLDATALOAD
+	PUSH 68
+	CALLDATALOAD
+	PUSH 36
+	CALLDATALOAD
+	DUP4
+	PUSH 0
+	LOG3
+	STOP
+log4:
+	PUSH 132
+	CALLDATALOAD
+	PUSH 100
+	CALLDATALOAD
+	PUSH 68
+	CALLDATALOAD
+	PUSH 36
+	CALLDATALOAD
+	DUP5
+	PUSH 0
+	LOG4
+	STOP
+
+	;; route: [offset, length]
+route:
+	PUSH 4
+route_loop:
+	DUP1
+	CALLDATASIZE
+	GT
+	ISZERO
+	JUMPI @route_done
+	DUP1
+	PUSH 32
+	ADD
+	CALLDATALOAD
+	DUP1
+	DUP3
+	PUSH 64
+	ADD
+	PUSH 0
+	CALLDATACOPY
+	PUSH 0
+	PUSH 0
+	DUP3
+	PUSH 0
+	PUSH 0
+	DUP7
+	CALLDATALOAD
+	GAS
+	CALL
+	ISZERO
+	JUMPI @fail
+	PUSH 64
+	ADD
+	ADD
+	JUMP @route_loop
+route_done:
+	STOP
+
+fail:
+	PUSH 0
+	PUSH 0
+	REVERT
+`
+
+var (
+	// routerAddress is the mock contract which routes the emitting calls of the transactions of the tests.
+	routerAddress = common.HexToAddress("0x000000000000000000000000000000000000ffff")
+	funds         = new(big.Int).Lsh(big.NewInt(1), 100)
+)
+
+// Alloc is the genesis allocation of the mock contracts of a test, and the outputs of their views.
+type Alloc struct {
+	accounts core.GenesisAlloc
+}
+
+func NewAlloc() *Alloc {
+	return &Alloc{accounts: make(core.GenesisAlloc)}
+}
+
+// SetView stubs the view of the method of a contract with the args to return the outputs.
+func (a *Alloc) SetView(contract common.Address, contractABI *abi.ABI, method string, args []interface{}, outputs ...interface{}) error {
+	m, ok := contractABI.Methods[method]
+	if !ok {
+		return fmt.Errorf("method %s is not found in the ABI", method)
+	}
+	input, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := m.Outputs.Pack(outputs...)
+	if err != nil {
+		return err
+	}
+
+	storage := a.deploy(contract)
+	key := crypto.Keccak256Hash(input).Big()
+	words := len(output) / common.HashLength
+	storage[common.BigToHash(key)] = common.BigToHash(big.NewInt(int64(words)))
+	for i := 0; i < words; i++ {
+		slot := new(big.Int).Add(key, big.NewInt(int64(i+1)))
+		storage[common.BigToHash(slot)] = common.BytesToHash(output[i*common.HashLength : (i+1)*common.HashLength])
+	}
+	return nil
+}
+
+// Deploy deploys a contract without views, i.e. a contract of which only the events are taken.
+func (a *Alloc) Deploy(contract common.Address) {
+	a.deploy(contract)
+}
+
+func (a *Alloc) deploy(contract common.Address) map[common.Hash]common.Hash {
+	account, ok := a.accounts[contract]
+	if !ok {
+		account = core.GenesisAccount{Balance: new(big.Int), Storage: make(map[common.Hash]common.Hash)}
+		a.accounts[contract] = account
+	}
+	return account.Storage
+}
+
+// Chain is the simulated chain of the mock contracts. Its genesis block is at time 0, and each committed block is 10
+// seconds after its parent unless the time is adjusted.
+type Chain struct {
+	*backends.SimulatedBackend
+	t       testing.TB
+	key     *ecdsa.PrivateKey
+	router  *bind.BoundContract
+	pending []common.Hash
+}
+
+// New creates the chain of the mock contracts of the allocation, the test fails if the mock contract cannot be compiled.
+func New(t testing.TB, alloc *Alloc) *Chain {
+	code, err := compile(mockSource)
+	if err != nil {
+		t.Fatalf("cannot compile mock contract: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+
+	genesis := core.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: funds},
+		routerAddress:                         {Code: code, Balance: new(big.Int)},
+	}
+	for address, account := range alloc.accounts {
+		account.Code = code
+		genesis[address] = account
+	}
+
+	backend := backends.NewSimulatedBackend(genesis, gasLimit)
+	return &Chain{
+		SimulatedBackend: backend,
+		t:                t,
+		key:              key,
+		router:           bind.NewBoundContract(routerAddress, abi.ABI{}, backend, backend, backend),
+	}
+}
+
+// Emit emits the logs by a transaction of the pending block, the block and the transaction fields of the logs are set
+// once the block is committed. The test fails if the contract of a log is not deployed.
+func (c *Chain) Emit(logs ...tp.Log) {
+	c.t.Helper()
+	var data []byte
+	data = binary.BigEndian.AppendUint32(data, routeSelector)
+	for _, l := range logs {
+		if code, err := c.CodeAt(context.Background(), l.Address, nil); err != nil || len(code) == 0 {
+			c.t.Fatalf("cannot emit event of %s, it is not deployed", l.Address)
+		}
+		payload := binary.BigEndian.AppendUint32(nil, emitSelector)
+		payload = append(payload, common.BigToHash(big.NewInt(int64(len(l.Topics)))).Bytes()...)
+		for _, topic := range l.Topics {
+			payload = append(payload, topic.Bytes()...)
+		}
+		payload = append(payload, l.Data...)
+
+		data = append(data, common.BytesToHash(l.Address.Bytes()).Bytes()...)
+		data = append(data, common.BigToHash(big.NewInt(int64(len(payload)))).Bytes()...)
+		data = append(data, payload...)
+	}
+
+	opts, err := bind.NewKeyedTransactorWithChainID(c.key, c.Blockchain().Config().ChainID)
+	if err != nil {
+		c.t.Fatalf("cannot create transactor: %v", err)
+	}
+	opts.GasLimit = txGasLimit
+	tx, err := c.router.RawTransact(opts, data)
+	if err != nil {
+		c.t.Fatalf("cannot send emitting transaction: %v", err)
+	}
+	c.pending = append(c.pending, tx.Hash())
+}
+
+// AdjustTime moves the time of the pending block forward, it takes an empty pending block only.
+func (c *Chain) AdjustTime(d time.Duration) {
+	c.t.Helper()
+	if err := c.SimulatedBackend.AdjustTime(d); err != nil {
+		c.t.Fatalf("cannot adjust time: %v", err)
+	}
+}
+
+// Commit seals the pending block, the test fails if any of its emitting transactions is reverted.
+func (c *Chain) Commit() {
+	c.t.Helper()
+	c.SimulatedBackend.Commit()
+	for _, hash := range c.pending {
+		receipt, err := c.TransactionReceipt(context.Background(), hash)
+		if err != nil || receipt.Status != tp.ReceiptStatusSuccessful {
+			c.t.Fatalf("emitting transaction %s failed", hash)
+		}
+	}
+	c.pending = nil
+}
+
+// compile compiles the assembly source into the bytecode.
+func compile(source string) ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(strings.TrimSpace(source)+"\n"), false))
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%v", errs)
+	}
+	return common.FromHex(bin), nil
+}
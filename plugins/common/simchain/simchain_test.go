@@ -0,0 +1,70 @@
+package simchain
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testABI = `[{"name":"balanceOf","type":"function","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],
+	"outputs":[{"name":"balance","type":"uint256"},{"name":"updatedAt","type":"uint256"}]}]`
+
+func TestChain(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testABI))
+	require.NoError(t, err)
+	token := common.HexToAddress("0x0a00000000000000000000000000000000000001")
+	pool := common.HexToAddress("0xa000000000000000000000000000000000000001")
+	owner := common.HexToAddress("0x5000000000000000000000000000000000000001")
+
+	alloc := NewAlloc()
+	require.NoError(t, alloc.SetView(token, &contractABI, "balanceOf", []interface{}{owner}, big.NewInt(42), big.NewInt(7)))
+	alloc.Deploy(pool)
+	sim := New(t, alloc)
+	defer sim.Close()
+
+	// the stored outputs are returned for the call data of the view, the other calls return no data.
+	input, err := contractABI.Pack("balanceOf", owner)
+	require.NoError(t, err)
+	output, err := sim.CallContract(context.Background(), ethereum.CallMsg{To: &token, Data: input}, nil)
+	require.NoError(t, err)
+	values, err := contractABI.Unpack("balanceOf", output)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{big.NewInt(42), big.NewInt(7)}, values)
+
+	input, err = contractABI.Pack("balanceOf", token)
+	require.NoError(t, err)
+	output, err = sim.CallContract(context.Background(), ethereum.CallMsg{To: &token, Data: input}, nil)
+	require.NoError(t, err)
+	require.Empty(t, output)
+
+	// the logs of the contracts are emitted in order by a transaction.
+	topic := common.HexToHash("0x01")
+	sim.Emit(tp.Log{Address: pool, Topics: []common.Hash{topic, common.HexToHash("0x02")}, Data: []byte{1, 2, 3}},
+		tp.Log{Address: token})
+	sim.Commit()
+	sim.AdjustTime(100 * time.Second)
+	sim.Commit()
+
+	logs, err := sim.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: common.Big0})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, pool, logs[0].Address)
+	require.Equal(t, []common.Hash{topic, common.HexToHash("0x02")}, logs[0].Topics)
+	require.Equal(t, []byte{1, 2, 3}, logs[0].Data)
+	require.Equal(t, token, logs[1].Address)
+	require.Empty(t, logs[1].Topics)
+	require.Equal(t, logs[0].TxHash, logs[1].TxHash)
+	require.Equal(t, uint64(1), logs[0].BlockNumber)
+
+	header, err := sim.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), header.Number.Uint64())
+	require.Equal(t, uint64(120), header.Time)
+}
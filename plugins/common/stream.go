@@ -0,0 +1,352 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/types"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultStreamPingInterval = 15 * time.Second // the interval of the heartbeats of a market data stream.
+	DefaultStreamVWAPWindow   = time.Minute      // the window of the trades of the VWAP of a market data stream.
+	minReconnectDelay         = time.Second
+	maxReconnectDelay         = 30 * time.Second
+)
+
+var errStreamClosed = errors.New("the stream is closed")
+
+// Tick is a ticker update or a trade of a market data stream, the price of a ticker is taken as it is, while the prices
+// of the trades are aggregated into the VWAP of the recent trades.
+type Tick struct {
+	Symbol    string          // the protocol symbol, i.e. USDC-USD.
+	Price     decimal.Decimal // the last price of a ticker, or the price of a trade.
+	Volume    decimal.Decimal // the quantity of a trade in the base asset, it is zero for a ticker.
+	Bid       string          // the optional best bid of a ticker.
+	Ask       string          // the optional best ask of a ticker.
+	Trade     bool            // the tick is a trade.
+	Timestamp time.Time       // the event time of the exchange.
+}
+
+// StreamAdapter adapts the WebSocket market data feed of an exchange to the stream client, it builds the subscriptions
+// of the symbols, and it parses the messages of the feed into ticks.
+type StreamAdapter interface {
+	// Subscriptions returns the messages to subscribe the symbols, they are sent on each (re)connection.
+	Subscriptions(symbols []string) ([]interface{}, error)
+	// ParseMessage parses a message of the feed, the acks and the heartbeats of the exchange are parsed into no tick.
+	ParseMessage(msg []byte) ([]Tick, error)
+	// PingMessage returns the application level heartbeat of the exchange, it is nil if the exchange takes the
+	// WebSocket pings.
+	PingMessage() interface{}
+}
+
+// streamEntry is the cached market data of a symbol.
+type streamEntry struct {
+	last   Tick
+	trades []Tick // the trades of the VWAP window in time order.
+}
+
+// StreamCache keeps the last price of the tickers, and the VWAP of the recent trades of the symbols of a stream. The
+// data older than the VWAP window is not served, thus a silent feed does not report a frozen price.
+type StreamCache struct {
+	mu      sync.RWMutex
+	window  time.Duration
+	entries map[string]*streamEntry
+	now     func() time.Time
+}
+
+func NewStreamCache(window time.Duration) *StreamCache {
+	return &StreamCache{window: window, entries: make(map[string]*streamEntry), now: time.Now}
+}
+
+// Update takes a tick, the trades out of the VWAP window of the latest one are dropped.
+func (c *StreamCache) Update(tick Tick) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[tick.Symbol]
+	if !ok {
+		entry = &streamEntry{}
+		c.entries[tick.Symbol] = entry
+	}
+	entry.last = tick
+	if !tick.Trade {
+		entry.trades = nil
+		return
+	}
+
+	entry.trades = append(entry.trades, tick)
+	start := tick.Timestamp.Add(-c.window)
+	i := 0
+	for i < len(entry.trades) && entry.trades[i].Timestamp.Before(start) {
+		i++
+	}
+	entry.trades = entry.trades[i:]
+}
+
+// Clear drops the cached data of all the symbols, the data of an ended session is not served.
+func (c *StreamCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*streamEntry)
+}
+
+// Price returns the cached price of a symbol, it is the VWAP of the recent trades with their quote volume, or the last
+// price of the ticker with the default volume. The trades out of the VWAP window of now are not counted, and there is
+// no price if the last tick is out of the window.
+func (c *StreamCache) Price(symbol string) (Price, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[symbol]
+	if !ok {
+		return Price{}, false
+	}
+
+	start := c.now().Add(-c.window)
+	if entry.last.Timestamp.Before(start) {
+		return Price{}, false
+	}
+
+	price := Price{
+		Symbol:    symbol,
+		Price:     entry.last.Price.String(),
+		Volume:    types.DefaultVolume.String(),
+		Timestamp: entry.last.Timestamp.Unix(),
+		Bid:       entry.last.Bid,
+		Ask:       entry.last.Ask,
+	}
+
+	notional, quantity := decimal.Zero, decimal.Zero
+	for _, trade := range entry.trades {
+		if trade.Timestamp.Before(start) {
+			continue
+		}
+		notional = notional.Add(trade.Price.Mul(trade.Volume))
+		quantity = quantity.Add(trade.Volume)
+	}
+	if quantity.IsPositive() {
+		price.Price = notional.DivRound(quantity, CryptoToUsdcDecimals).String()
+		if volume := notional.BigInt(); volume.Sign() > 0 {
+			price.Volume = volume.String()
+		}
+	}
+	return price, true
+}
+
+// StreamClient is the data source client of a WebSocket market data feed, it keeps the stream subscribed with the
+// heartbeats and with the reconnections, and it serves the prices from the local cache of the stream.
+type StreamClient struct {
+	conf    *config.PluginConfig
+	url     string
+	adapter StreamAdapter
+	symbols []string
+	logger  hclog.Logger
+	cache   *StreamCache
+
+	dialer       *websocket.Dialer
+	pingInterval time.Duration
+	readTimeout  time.Duration
+
+	mu      sync.Mutex // it guards the connection and the writes to it.
+	conn    *websocket.Conn
+	doneCh  chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func NewStreamClient(conf *config.PluginConfig, adapter StreamAdapter, symbols []string, logger hclog.Logger) *StreamClient {
+	timeout := time.Duration(conf.Timeout) * time.Second
+	return &StreamClient{
+		conf:         conf,
+		url:          conf.Scheme + "://" + conf.Endpoint,
+		adapter:      adapter,
+		symbols:      symbols,
+		logger:       logger,
+		cache:        NewStreamCache(DefaultStreamVWAPWindow),
+		dialer:       &websocket.Dialer{HandshakeTimeout: timeout},
+		pingInterval: DefaultStreamPingInterval,
+		readTimeout:  2*DefaultStreamPingInterval + timeout,
+		doneCh:       make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+// Start runs the stream until the client is closed, the stream is reconnected and resubscribed with an exponential
+// backoff once it is dropped or once its heartbeats are timed out.
+func (s *StreamClient) Start() {
+	go s.run()
+}
+
+func (s *StreamClient) run() {
+	defer close(s.stopped)
+	delay := minReconnectDelay
+	for {
+		received, err := s.session()
+		// the data of the ended session is dropped, it is not served until the stream is resubscribed.
+		s.cache.Clear()
+		select {
+		case <-s.doneCh:
+			return
+		default:
+		}
+
+		if received {
+			delay = minReconnectDelay
+		}
+		s.logger.Warn("market data stream is dropped, reconnecting", "url", s.url, "error", err, "delay", delay)
+		select {
+		case <-s.doneCh:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// session connects and subscribes the stream, and it takes the messages until the stream is dropped, it tells if any
+// message was received.
+func (s *StreamClient) session() (bool, error) {
+	conn, _, err := s.dialer.Dial(s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	select {
+	case <-s.doneCh:
+		s.mu.Unlock()
+		return false, errStreamClosed
+	default:
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	subscriptions, err := s.adapter.Subscriptions(s.symbols)
+	if err != nil {
+		return false, err
+	}
+	for _, subscription := range subscriptions {
+		if err = s.write(conn, subscription); err != nil {
+			return false, err
+		}
+	}
+	s.logger.Info("market data stream is subscribed", "url", s.url, "symbols", s.symbols)
+
+	// any message or pong of the exchange extends the read deadline, and the pings of the exchange are answered.
+	extend := func() error {
+		return conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	}
+	conn.SetPongHandler(func(string) error { return extend() })
+	conn.SetPingHandler(func(data string) error {
+		if err := extend(); err != nil {
+			return err
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(s.readTimeout))
+	})
+
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+	go s.heartbeat(conn, sessionDone)
+
+	received := false
+	for {
+		if err = extend(); err != nil {
+			return received, err
+		}
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		ticks, err := s.adapter.ParseMessage(msg)
+		if err != nil {
+			s.logger.Warn("cannot parse market data message", "error", err, "message", string(msg))
+			continue
+		}
+		for _, tick := range ticks {
+			s.cache.Update(tick)
+		}
+	}
+}
+
+// heartbeat pings the exchange in the interval until the session is done.
+func (s *StreamClient) heartbeat(conn *websocket.Conn, sessionDone chan struct{}) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sessionDone:
+			return
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.pingInterval))
+			if ping := s.adapter.PingMessage(); err == nil && ping != nil {
+				err = s.write(conn, ping)
+			}
+			if err != nil {
+				s.logger.Warn("cannot ping market data stream", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// write sends a JSON message, the writes of the subscriptions and of the heartbeats are serialized.
+func (s *StreamClient) write(conn *websocket.Conn, msg interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := conn.SetWriteDeadline(time.Now().Add(s.readTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(msg)
+}
+
+// FetchPrice reads the prices of the symbols from the local cache of the stream.
+func (s *StreamClient) FetchPrice(symbols []string) (Prices, error) {
+	var prices Prices
+	for _, symbol := range symbols {
+		if price, ok := s.cache.Price(symbol); ok {
+			prices = append(prices, price)
+		}
+	}
+	if len(prices) == 0 {
+		return nil, ErrDataNotAvailable
+	}
+	return prices, nil
+}
+
+func (s *StreamClient) AvailableSymbols() ([]string, error) {
+	return s.symbols, nil
+}
+
+func (s *StreamClient) KeyRequired() bool {
+	return false
+}
+
+// Close stops the stream, the stream of a started client is stopped once its Stopped channel is closed.
+func (s *StreamClient) Close() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		close(s.doneCh)
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+}
+
+// Stopped returns a channel which is closed once the stream is stopped after a close.
+func (s *StreamClient) Stopped() <-chan struct{} {
+	return s.stopped
+}
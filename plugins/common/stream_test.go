@@ -0,0 +1,192 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAdapter is the adapter of the test feed, its trades are {"s": symbol, "p": price, "q": quantity, "t": ms}.
+type testAdapter struct{}
+
+func (a *testAdapter) Subscriptions(symbols []string) ([]interface{}, error) {
+	return []interface{}{map[string]interface{}{"op": "subscribe", "symbols": symbols}}, nil
+}
+
+func (a *testAdapter) ParseMessage(msg []byte) ([]Tick, error) {
+	var trade struct {
+		S string          `json:"s"`
+		P decimal.Decimal `json:"p"`
+		Q decimal.Decimal `json:"q"`
+		T int64           `json:"t"`
+	}
+	if err := json.Unmarshal(msg, &trade); err != nil {
+		return nil, err
+	}
+	if trade.S == "" {
+		return nil, nil
+	}
+	return []Tick{{Symbol: trade.S, Price: trade.P, Volume: trade.Q, Trade: true, Timestamp: time.UnixMilli(trade.T)}}, nil
+}
+
+func (a *testAdapter) PingMessage() interface{} {
+	return map[string]string{"op": "ping"}
+}
+
+func TestStreamCache(t *testing.T) {
+	cache := NewStreamCache(time.Minute)
+	_, ok := cache.Price("USDC-USD")
+	require.False(t, ok)
+
+	start := time.Unix(1700000000, 0)
+	now := start.Add(30 * time.Second)
+	cache.now = func() time.Time { return now }
+	trade := func(price string, quantity int64, at time.Duration) Tick {
+		return Tick{Symbol: "USDC-USD", Price: decimal.RequireFromString(price), Volume: decimal.NewFromInt(quantity),
+			Trade: true, Timestamp: start.Add(at)}
+	}
+	cache.Update(trade("1.0", 100, 0))
+	cache.Update(trade("1.1", 300, 30*time.Second))
+	price, ok := cache.Price("USDC-USD")
+	require.True(t, ok)
+	require.Equal(t, "1.075", price.Price)
+	require.Equal(t, "430", price.Volume)
+	require.Equal(t, int64(1700000030), price.Timestamp)
+
+	// the first trade is out of the window of the third one.
+	now = start.Add(90 * time.Second)
+	cache.Update(trade("0.9", 100, 90*time.Second))
+	price, _ = cache.Price("USDC-USD")
+	require.Equal(t, "1.05", price.Price)
+	require.Equal(t, "420", price.Volume)
+
+	// without new trades, the trades out of the window of now are not counted.
+	now = start.Add(100 * time.Second)
+	price, _ = cache.Price("USDC-USD")
+	require.Equal(t, "0.9", price.Price)
+	require.Equal(t, "90", price.Volume)
+
+	// the last trade is out of the window, the symbol has no price.
+	now = start.Add(151 * time.Second)
+	_, ok = cache.Price("USDC-USD")
+	require.False(t, ok)
+
+	// a ticker is taken as it is.
+	now = start
+	cache.Update(Tick{Symbol: "EUR-USD", Price: decimal.RequireFromString("1.08"), Bid: "1.07", Ask: "1.09",
+		Timestamp: start})
+	price, _ = cache.Price("EUR-USD")
+	require.Equal(t, Price{Symbol: "EUR-USD", Price: "1.08", Volume: "1000000", Timestamp: 1700000000, Bid: "1.07",
+		Ask: "1.09"}, price)
+
+	// the cached data is dropped.
+	cache.Clear()
+	_, ok = cache.Price("EUR-USD")
+	require.False(t, ok)
+}
+
+func TestStreamClient(t *testing.T) {
+	var mu sync.Mutex
+	var subscriptions []string
+	pings := 0
+	connections := 0
+	release := make(chan struct{})
+	defer close(release)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		subscriptions = append(subscriptions, string(msg))
+		connections++
+		n := connections
+		mu.Unlock()
+
+		now := time.Now().UnixMilli()
+		switch n {
+		case 1:
+			// the first connection is dropped after a trade.
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"s":"USDC-USD","p":"1.0","q":"100","t":`+
+				decimal.NewFromInt(now).String()+`}`))
+		case 2:
+			// the second connection sends a trade and goes silent, neither the pings nor the pongs are answered.
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"s":"USDC-USD","p":"1.2","q":"100","t":`+
+				decimal.NewFromInt(now).String()+`}`))
+			<-release
+		default:
+			// the third connection sends a trade and takes the heartbeats.
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"s":"USDC-USD","p":"1.3","q":"100","t":`+
+				decimal.NewFromInt(now).String()+`}`))
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if strings.Contains(string(msg), "ping") {
+					mu.Lock()
+					pings++
+					mu.Unlock()
+					_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"pong"}`))
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	conf := &config.PluginConfig{Name: "test", Scheme: "ws", Endpoint: strings.TrimPrefix(server.URL, "http://"), Timeout: 1}
+	client := NewStreamClient(conf, &testAdapter{}, []string{"USDC-USD"}, hclog.NewNullLogger())
+	client.pingInterval = 100 * time.Millisecond
+	client.readTimeout = 500 * time.Millisecond
+
+	_, err := client.FetchPrice([]string{"USDC-USD"})
+	require.ErrorIs(t, err, ErrDataNotAvailable)
+
+	client.Start()
+	// the stream is resubscribed after the drop and after the heartbeat timeout, the trades of the dropped sessions are
+	// not served.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return connections >= 3 && pings >= 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	require.JSONEq(t, `{"op":"subscribe","symbols":["USDC-USD"]}`, subscriptions[0])
+	require.Equal(t, subscriptions[0], subscriptions[2])
+	mu.Unlock()
+
+	prices, err := client.FetchPrice([]string{"USDC-USD", "EUR-USD"})
+	require.NoError(t, err)
+	require.Len(t, prices, 1)
+	require.Equal(t, "1.3", prices[0].Price)
+	require.Equal(t, "130", prices[0].Volume)
+
+	symbols, err := client.AvailableSymbols()
+	require.NoError(t, err)
+	require.Equal(t, []string{"USDC-USD"}, symbols)
+
+	client.Close()
+	select {
+	case <-client.Stopped():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is not stopped")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"autonity-oracle/config"
 	"autonity-oracle/plugins/common"
+	tokenmeta "autonity-oracle/plugins/common/contracts/erc20"
 	"autonity-oracle/plugins/crypto_airswap/erc20"
 	swaperc20 "autonity-oracle/plugins/crypto_airswap/swap_erc20"
 	"autonity-oracle/types"
@@ -16,7 +17,6 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/hashicorp/go-hclog"
 	ring "github.com/zfjagann/golang-ring"
-	"math"
 	"math/big"
 	"os"
 	"sync"
@@ -25,14 +25,12 @@ import (
 
 var (
 	orderBookCapacity = 64
-	version           = "v0.2.0"
+	version           = "v0.3.0"
 	ATNUSDC           = "ATN-USDC"
 	NTNUSDC           = "NTN-USDC"
-	supportedSymbols  = common.DefaultCryptoSymbols
 	NTNTokenAddress   = types.AutonityContractAddress // Autonity contract is the protocol contract of NTN token
 )
 
-// todo: airswap DEX plugin is not going to be released for the coming release.
 var defaultConfig = config.PluginConfig{
 	Name:     "crypto_airswap",
 	Scheme:   "wss",
@@ -45,27 +43,45 @@ var defaultConfig = config.PluginConfig{
 	NTNTokenAddress:  NTNTokenAddress.Hex(),                        // Same as 0xBd770416a3345F91E4B34576cb804a576fa48EB1, Autonity contract address.
 	ATNTokenAddress:  "0xcE17e51cE4F0417A1aB31a3c5d6831ff3BbFa1d2", // Wrapped ATN ERC20 contract address on the target blockchain.
 	USDCTokenAddress: "0xB855D5e83363A4494e09f0Bb3152A70d3f161940", // USDCx ERC20 contract address on the target blockchain.
-	SwapAddress:      "0x28363983213F88C759b501E3a5888458178cD5E7", // todo: config this once AirSwap SwapERC20 contract created.
+	// There is no default SwapAddress, the AirSwap SwapERC20 contract address of the target blockchain is required.
 }
 
+// ChainBackend is the L1 node backend of the client, it is dialed from the plugin config, or simulated in tests.
+type ChainBackend interface {
+	bind.ContractBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types2.Header, error)
+	TransactionReceipt(ctx context.Context, txHash ecommon.Hash) (*types2.Receipt, error)
+}
+
+// Order is the exchange of a swap between the base token and the quote token of a pair in their raw amounts.
 type Order struct {
-	cryptoToken  ecommon.Address
-	cryptoAmount *big.Int
-	usdcAmount   *big.Int
+	baseAmount  *big.Int
+	quoteAmount *big.Int
+}
+
+// SwapPair is a configured token pair, the swaps between its tokens are aggregated into its own order book.
+type SwapPair struct {
+	symbol        string
+	baseToken     ecommon.Address
+	quoteToken    ecommon.Address
+	baseDecimals  uint8
+	quoteDecimals uint8
+	orderBook     ring.Ring
 }
 
 // aggregatePrice compute the VWAP of the input orders, and return the total accumulating volumes.
-func aggregatePrice(orderBook *ring.Ring, order Order) (*big.Rat, *big.Int, error) {
-	orderBook.Enqueue(order)
-	recentOrders := orderBook.Values()
-	return volumeWeightedPrice(recentOrders)
+func aggregatePrice(p *SwapPair, order Order) (*big.Rat, *big.Int, error) {
+	p.orderBook.Enqueue(order)
+	recentOrders := p.orderBook.Values()
+	return volumeWeightedPrice(recentOrders, p.baseDecimals, p.quoteDecimals)
 }
 
-// volumeWeightedPrice return the volume-weighted exchange ratio of ATN or NTN to USDC, and the total volumes.
-func volumeWeightedPrice(orders []interface{}) (*big.Rat, *big.Int, error) {
-	// Initialize total crypto and USDC amounts
-	totalCrypto := new(big.Int)
-	totalUSDC := new(big.Int)
+// volumeWeightedPrice return the volume-weighted exchange ratio of the base token to the quote token, and the total
+// volumes in the quote token.
+func volumeWeightedPrice(orders []interface{}, baseDecimals, quoteDecimals uint8) (*big.Rat, *big.Int, error) {
+	// Initialize total base and quote amounts
+	totalBase := new(big.Int)
+	totalQuote := new(big.Int)
 
 	// Iterate through the orders to sum up the amounts
 	for _, orderInterface := range orders {
@@ -75,39 +91,34 @@ func volumeWeightedPrice(orders []interface{}) (*big.Rat, *big.Int, error) {
 			return nil, nil, fmt.Errorf("invalid order type")
 		}
 
-		totalCrypto.Add(totalCrypto, order.cryptoAmount)
-		totalUSDC.Add(totalUSDC, order.usdcAmount)
+		totalBase.Add(totalBase, order.baseAmount)
+		totalQuote.Add(totalQuote, order.quoteAmount)
 	}
 
-	// Check if totalUSDC is zero to avoid division by zero
-	if totalUSDC.Cmp(common.Zero) == 0 {
-		return nil, nil, fmt.Errorf("total USDC amount is zero, cannot compute ratio")
+	// Check if totalQuote is zero to avoid division by zero
+	if totalQuote.Cmp(common.Zero) == 0 {
+		return nil, nil, fmt.Errorf("total quote amount is zero, cannot compute ratio")
 	}
 
 	// Scale the totals according to their decimals
-	scaledTotalCrypto := new(big.Int).Mul(totalCrypto, big.NewInt(int64(math.Pow(10, float64(common.USDCDecimals)))))
-	scaledTotalUSDC := new(big.Int).Mul(totalUSDC, big.NewInt(int64(math.Pow(10, float64(common.AutonityCryptoDecimals)))))
-
-	// Calculate the weighted ratio as a fraction
-	if scaledTotalUSDC.Cmp(common.Zero) == 0 {
-		return nil, nil, fmt.Errorf("scaled total USDC amount is zero, cannot compute ratio")
-	}
+	scaledTotalBase := new(big.Int).Mul(totalBase, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(quoteDecimals)), nil))
+	scaledTotalQuote := new(big.Int).Mul(totalQuote, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(baseDecimals)), nil))
 
-	weightedRatio := new(big.Rat).SetFrac(scaledTotalCrypto, scaledTotalUSDC)
-	return weightedRatio, totalUSDC, nil
+	weightedRatio := new(big.Rat).SetFrac(scaledTotalBase, scaledTotalQuote)
+	return weightedRatio, totalQuote, nil
 }
 
 type AirswapClient struct {
-	conf   *config.PluginConfig
-	client *ethclient.Client
-	logger hclog.Logger
+	conf    *config.PluginConfig
+	backend ChainBackend
+	closer  func()
+	logger  hclog.Logger
 
-	atnAddress  ecommon.Address
-	usdcAddress ecommon.Address
-	ntnAddress  ecommon.Address
+	pairs  []*SwapPair
+	tokens map[ecommon.Address]bool // the tokens of the pairs, the swaps of the other tokens are skipped.
 
 	// ERC20 Transfer event parser.
-	erc20LogParser *erc20.Erc20
+	transferParser *erc20.Erc20Filterer
 
 	// SwapERC20 event watcher and log parser.
 	swapContract *swaperc20.Swaperc20
@@ -119,13 +130,12 @@ type AirswapClient struct {
 	ticker   *time.Ticker // the clock interval to recover L1 connectivity.
 	lostSync bool
 
-	ntnOrderBooks ring.Ring
-
-	atnOrderBooks ring.Ring
+	syncedBlock  uint64 // the last block of which the swap events are taken.
+	backfilledTo uint64 // the head block of the last backfill, the swap events up to it are taken by the backfill.
 
 	priceMutex sync.RWMutex
 
-	lastAggregatedPrices map[ecommon.Address]common.Price
+	lastAggregatedPrices map[string]common.Price
 }
 
 func NewAirswapClient(conf *config.PluginConfig) (*AirswapClient, error) {
@@ -142,42 +152,119 @@ func NewAirswapClient(conf *config.PluginConfig) (*AirswapClient, error) {
 		return nil, err
 	}
 
-	swapContract, err := swaperc20.NewSwaperc20(ecommon.HexToAddress(conf.SwapAddress), client)
+	ac, err := newAirswapClient(conf, client, client.Close, logger)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return ac, nil
+}
+
+func newAirswapClient(conf *config.PluginConfig, backend ChainBackend, closer func(), logger hclog.Logger) (*AirswapClient, error) {
+	if conf.SwapAddress == "" {
+		logger.Error("airswap SwapERC20 contract address is not configured")
+		return nil, fmt.Errorf("airswap SwapERC20 contract address is not configured")
+	}
+
+	swapContract, err := swaperc20.NewSwaperc20(ecommon.HexToAddress(conf.SwapAddress), backend)
 	if err != nil {
 		logger.Error("cannot bind airswapERC20 contract", "error", err)
 		return nil, err
 	}
 
-	erc20LogParser, err := erc20.NewErc20(NTNTokenAddress, client)
+	// the transfers of any token are parsed by the parser.
+	transferParser, err := erc20.NewErc20Filterer(ecommon.Address{}, backend)
 	if err != nil {
-		logger.Error("cannot bind NTN ERC20 contract", "error", err)
+		logger.Error("cannot bind ERC20 transfer parser", "error", err)
 		return nil, err
 	}
 
 	ac := &AirswapClient{
 		conf:                 conf,
-		client:               client,
+		backend:              backend,
+		closer:               closer,
 		logger:               logger,
-		atnAddress:           ecommon.HexToAddress(conf.ATNTokenAddress),
-		ntnAddress:           NTNTokenAddress,
-		usdcAddress:          ecommon.HexToAddress(conf.USDCTokenAddress),
-		erc20LogParser:       erc20LogParser,
+		tokens:               make(map[ecommon.Address]bool),
+		transferParser:       transferParser,
 		swapContract:         swapContract,
 		doneCh:               make(chan struct{}),
-		ticker:               time.NewTicker(time.Minute),
-		lastAggregatedPrices: make(map[ecommon.Address]common.Price),
+		ticker:               time.NewTicker(time.Second * 30),
+		lastAggregatedPrices: make(map[string]common.Price),
 	}
 
-	ac.atnOrderBooks.SetCapacity(orderBookCapacity)
-	ac.ntnOrderBooks.SetCapacity(orderBookCapacity)
+	for _, pairConf := range defaultPairs(conf) {
+		p, err := newSwapPair(backend, pairConf)
+		if err != nil {
+			logger.Error("cannot resolve the tokens of pair", "symbol", pairConf.Symbol, "error", err)
+			return nil, err
+		}
+		ac.pairs = append(ac.pairs, p)
+		ac.tokens[p.baseToken] = true
+		ac.tokens[p.quoteToken] = true
+	}
 
 	if err = ac.EventSubscription(); err != nil {
 		return nil, err
 	}
 
+	// the swap contract is subscribed before the backfill, thus no swap is missed in between.
+	if err = ac.backfill(); err != nil {
+		logger.Warn("cannot backfill swap history", "error", err)
+	}
+
 	return ac, nil
 }
 
+// defaultPairs returns the configured pairs, they are the ATN-USDC and NTN-USDC pairs of the token addresses if there
+// is no pair configured.
+func defaultPairs(conf *config.PluginConfig) []config.PairConfig {
+	if len(conf.Pairs) > 0 {
+		return conf.Pairs
+	}
+
+	ntnTokenAddress := NTNTokenAddress.Hex()
+	if conf.NTNTokenAddress != "" {
+		ntnTokenAddress = conf.NTNTokenAddress
+	}
+	return []config.PairConfig{
+		{Symbol: ATNUSDC, Base: conf.ATNTokenAddress, Quote: conf.USDCTokenAddress},
+		{Symbol: NTNUSDC, Base: ntnTokenAddress, Quote: conf.USDCTokenAddress},
+	}
+}
+
+// newSwapPair resolves the tokens of a configured pair, the decimals of a token are read from the token contract if
+// they are not configured.
+func newSwapPair(backend ChainBackend, pairConf config.PairConfig) (*SwapPair, error) {
+	p := &SwapPair{
+		symbol:     pairConf.Symbol,
+		baseToken:  ecommon.HexToAddress(pairConf.Base),
+		quoteToken: ecommon.HexToAddress(pairConf.Quote),
+	}
+
+	var err error
+	if p.baseDecimals, err = tokenDecimals(backend, p.baseToken, pairConf.BaseDecimals); err != nil {
+		return nil, err
+	}
+	if p.quoteDecimals, err = tokenDecimals(backend, p.quoteToken, pairConf.QuoteDecimals); err != nil {
+		return nil, err
+	}
+	p.orderBook.SetCapacity(orderBookCapacity)
+	return p, nil
+}
+
+// tokenDecimals returns the configured decimals of a token, or reads them from the ERC20 token contract if it is 0.
+func tokenDecimals(backend ChainBackend, token ecommon.Address, configured uint8) (uint8, error) {
+	if configured != 0 {
+		return configured, nil
+	}
+
+	tokenContract, err := tokenmeta.NewERC20Caller(token, backend)
+	if err != nil {
+		return 0, err
+	}
+	return tokenContract.Decimals(nil)
+}
+
 func (e *AirswapClient) EventSubscription() error {
 	// subscribe on-chain swap event of SwapERC20.
 	chSwapEvent := make(chan *swaperc20.Swaperc20SwapERC20)
@@ -198,14 +285,16 @@ func (e *AirswapClient) StartWatcher() {
 			e.ticker.Stop()
 			e.logger.Info("air-swap events watcher stopped")
 			return
-		case err := <-e.subSwapEvent.Err():
+		case err := <-e.subscriptionErr():
 			if err != nil {
 				e.logger.Info("subscription error of swap event", "error", err)
 				e.handleConnectivityError()
-				e.subSwapEvent.Unsubscribe()
 			}
 		case airSwapEvent := <-e.chSwapEvent:
 			e.logger.Debug("receiving a SwapERC20 event", "event", airSwapEvent, "nonce", airSwapEvent.Nonce.Uint64())
+			if !e.takeSwapEvent(airSwapEvent) {
+				continue
+			}
 			if err := e.handleSwapEvent(airSwapEvent.Raw.TxHash, airSwapEvent); err != nil {
 				e.logger.Error("handle swap event failed", "error", err)
 				continue
@@ -217,40 +306,97 @@ func (e *AirswapClient) StartWatcher() {
 	}
 }
 
+// subscriptionErr returns the error channel of the swap event subscription, it is nil once the subscription is dropped
+// until it is rebuilt, as the error channel of a dropped subscription is closed.
+func (e *AirswapClient) subscriptionErr() <-chan error {
+	if e.lostSync {
+		return nil
+	}
+	return e.subSwapEvent.Err()
+}
+
+// backfill takes the SwapERC20 events which were emitted while the swap contract was not subscribed, they are the ones
+// since the synced block, or the ones of the backfill blocks on start, up to the head block. Thus, the order books are
+// complete after a start or a re-subscription.
+func (e *AirswapClient) backfill() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.conf.Timeout)*time.Second)
+	defer cancel()
+	header, err := e.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	head := header.Number.Uint64()
+
+	start := common.BackfillStart(head, e.syncedBlock, e.conf.BackfillBlocks)
+	if start <= head {
+		iter, err := e.swapContract.FilterSwapERC20(&bind.FilterOpts{Start: start, End: &head, Context: ctx}, nil, nil)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		events := 0
+		for iter.Next() {
+			events++
+			if !e.takeSwapEvent(iter.Event) {
+				continue
+			}
+			if err = e.handleSwapEvent(iter.Event.Raw.TxHash, iter.Event); err != nil {
+				e.logger.Error("handle backfilled swap event failed", "error", err)
+			}
+		}
+		if err = iter.Error(); err != nil {
+			return err
+		}
+		e.logger.Info("backfilled swap history", "from", start, "to", head, "events", events)
+	}
+	e.backfilledTo = head
+	if head > e.syncedBlock {
+		e.syncedBlock = head
+	}
+	return nil
+}
+
+// takeSwapEvent tells if a swap event is to be taken, the swaps up to the backfilled block are taken by the backfill.
+func (e *AirswapClient) takeSwapEvent(swapEvent *swaperc20.Swaperc20SwapERC20) bool {
+	if swapEvent.Raw.Removed || swapEvent.Raw.BlockNumber <= e.backfilledTo {
+		return false
+	}
+	if swapEvent.Raw.BlockNumber > e.syncedBlock {
+		e.syncedBlock = swapEvent.Raw.BlockNumber
+	}
+	return true
+}
+
 // handleSwapEvent, handles a single swap event at a time, if a txn contains multiple swap events, this function will
 // be called with multiple times as the client subscribe every single swap event from L1. Processing one event at a
 // time also make the logic simple and clear.
 func (e *AirswapClient) handleSwapEvent(txnHash ecommon.Hash, swapEvent *swaperc20.Swaperc20SwapERC20) error {
 	// pull the logs of the txn which issues the swap event.
-	txnReceipt, err := e.client.TransactionReceipt(context.Background(), txnHash)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.conf.Timeout)*time.Second)
+	defer cancel()
+	txnReceipt, err := e.backend.TransactionReceipt(ctx, txnHash)
 	if err != nil {
 		e.logger.Error("cannot get transaction receipt", "error", err, "txnHash", txnHash)
 		return err
 	}
 
 	logs := txnReceipt.Logs
-	order, err := e.extractOrder(logs, swapEvent)
+	p, order, err := e.extractOrder(logs, swapEvent)
 	if err != nil {
-		e.logger.Error("failed to extract the exchanges from txn receipts", "error", err, "txnHash", txnHash)
+		e.logger.Debug("failed to extract the exchanges from txn receipts", "error", err, "txnHash", txnHash)
 		return err
 	}
 
-	// then do the computing of price of ATN-USDC or NTN-USDC
-	var orderBook *ring.Ring
-	if order.cryptoToken == e.atnAddress {
-		orderBook = &e.atnOrderBooks
-	} else {
-		orderBook = &e.ntnOrderBooks
-	}
-
-	lastAggregatedPrice, volumes, err := aggregatePrice(orderBook, order)
+	// then do the computing of price of the pair in its own order book.
+	lastAggregatedPrice, volumes, err := aggregatePrice(p, order)
 	if err != nil {
 		e.logger.Error("failed to compute new price", "error", err, "txnHash", txnHash, "order", order)
 		return err
 	}
 
 	// update the last aggregated price.
-	e.updatePrice(order.cryptoToken, lastAggregatedPrice.FloatString(common.CryptoToUsdcDecimals), volumes)
+	e.updatePrice(p.symbol, lastAggregatedPrice.FloatString(common.CryptoToUsdcDecimals), volumes)
 	return nil
 }
 
@@ -282,11 +428,10 @@ log5, event: airswapERC20.SwapERC20(nonce, signerWallet);
 // from the log, and finally paired the log2 with log1 events as the exchange. With the signerWallet address is emitted
 // by the SwapErc20(nonce, signerWallet) event, we can get the senderAmount of senderToken received by signerWallet, and
 // the signerAmount of signerToken transfer by the signerWallet to get the exchange. In this plugin, we only care about
-// the NTN token and the USDC token as our targeting liquidity market.
-func (e *AirswapClient) extractOrder(logs []*types2.Log, targetSwapEvent *swaperc20.Swaperc20SwapERC20) (Order, error) {
+// the tokens of the configured pairs, the exchange is resolved as an order of the pair of the signer and sender tokens.
+func (e *AirswapClient) extractOrder(logs []*types2.Log, targetSwapEvent *swaperc20.Swaperc20SwapERC20) (*SwapPair, Order, error) {
 	var order Order
 
-	// todo: Jason, refine this implementation once the comment of https://github.com/airswap/airswap-protocols/issues/1341 is resolved.
 	// iterate the logs to address the subscribed swapEvent,
 	index := -1
 	for i := len(logs) - 1; i >= 0; i-- {
@@ -305,7 +450,7 @@ func (e *AirswapClient) extractOrder(logs []*types2.Log, targetSwapEvent *swaper
 	}
 
 	if index == -1 {
-		return order, errors.New("failed to find matching swap in receipt")
+		return nil, order, errors.New("failed to find matching swap in receipt")
 	}
 
 	var signerTokenAmount *big.Int
@@ -315,20 +460,20 @@ func (e *AirswapClient) extractOrder(logs []*types2.Log, targetSwapEvent *swaper
 
 	// swap event is addressed, find the signerToken.Transfers and the senderToken.Transfer close to it.
 	for i := index - 1; i >= 0; i-- {
-		// just parse the ERC20 transfer events, the events could be ATN, NTN or USDC transfer events.
-		transfer, err := e.erc20LogParser.ParseTransfer(*logs[i])
+		// just parse the ERC20 transfer events, the events could be the transfers of any tokens.
+		transfer, err := e.transferParser.ParseTransfer(*logs[i])
 		if err != nil {
 			e.logger.Debug("failed to parse log with ERC20 transfer", "error", err)
 			continue
 		}
 
 		eventEmitter := transfer.Raw.Address
-		if eventEmitter != e.usdcAddress && eventEmitter != e.ntnAddress && eventEmitter != e.atnAddress {
-			e.logger.Debug("skip none ATN, NTN & USDC swap event")
-			return order, errors.New("skip none ATN, NTN & USDC swap event")
+		if !e.tokens[eventEmitter] {
+			e.logger.Debug("skip swap event of the tokens which are not configured", "token", eventEmitter)
+			return nil, order, errors.New("skip swap event of the tokens which are not configured")
 		}
 
-		// now only transfers of ATN, NTN or USDC token can come to here.
+		// now only transfers of the configured tokens can come to here.
 		if transfer.From == targetSwapEvent.SignerWallet {
 			if signerTokenAmount == nil || transfer.Value.Cmp(signerTokenAmount) > 0 {
 				signerTokenAmount = transfer.Value
@@ -344,49 +489,27 @@ func (e *AirswapClient) extractOrder(logs []*types2.Log, targetSwapEvent *swaper
 	}
 
 	if signerTokenAmount == nil || senderTokenAmount == nil {
-		return order, errors.New("skip none ATN, NTN & USDC swap event")
+		return nil, order, errors.New("skip swap event without the transfers of both tokens")
 	}
 
-	if (signerTokenAddress == e.usdcAddress && senderTokenAddress == e.atnAddress) ||
-		(signerTokenAddress == e.atnAddress && senderTokenAddress == e.usdcAddress) {
-		order.cryptoToken = e.atnAddress
-		if senderTokenAddress == e.atnAddress {
-			order.cryptoAmount = senderTokenAmount
-			order.usdcAmount = signerTokenAmount
-		} else {
-			order.cryptoAmount = signerTokenAmount
-			order.usdcAmount = senderTokenAmount
+	for _, p := range e.pairs {
+		if signerTokenAddress == p.baseToken && senderTokenAddress == p.quoteToken {
+			return p, Order{baseAmount: signerTokenAmount, quoteAmount: senderTokenAmount}, nil
 		}
-		return order, nil
-	}
-
-	if (signerTokenAddress == e.usdcAddress && senderTokenAddress == e.ntnAddress) ||
-		(signerTokenAddress == e.ntnAddress && senderTokenAddress == e.usdcAddress) {
-		order.cryptoToken = e.ntnAddress
-		if senderTokenAddress == e.ntnAddress {
-			order.cryptoAmount = senderTokenAmount
-			order.usdcAmount = signerTokenAmount
-		} else {
-			order.cryptoAmount = signerTokenAmount
-			order.usdcAmount = senderTokenAmount
+		if senderTokenAddress == p.baseToken && signerTokenAddress == p.quoteToken {
+			return p, Order{baseAmount: senderTokenAmount, quoteAmount: signerTokenAmount}, nil
 		}
-		return order, nil
 	}
 
-	// exchange of ATN and NTN is not watched, we skip the order.
-	return order, errors.New("skip process swap event of ATN and NTN from airswap")
+	// exchange of the tokens which are not paired is not watched, we skip the order.
+	return nil, order, fmt.Errorf("skip swap event of %s and %s which are not a configured pair", signerTokenAddress, senderTokenAddress)
 }
 
-func (e *AirswapClient) updatePrice(tokenAddress ecommon.Address, price string, volumes *big.Int) {
+func (e *AirswapClient) updatePrice(symbol string, price string, volumes *big.Int) {
 	e.priceMutex.Lock()
 	defer e.priceMutex.Unlock()
 
-	symbol := ATNUSDC
-	if tokenAddress == e.ntnAddress {
-		symbol = NTNUSDC
-	}
-
-	e.lastAggregatedPrices[tokenAddress] = common.Price{
+	e.lastAggregatedPrices[symbol] = common.Price{
 		Symbol: symbol,
 		Price:  price,
 		Volume: volumes.String(),
@@ -401,6 +524,10 @@ func (e *AirswapClient) checkHealth() {
 			return
 		}
 		e.lostSync = false
+		// backfill the swaps lost in between.
+		if err = e.backfill(); err != nil {
+			e.logger.Warn("cannot backfill swap history", "error", err)
+		}
 		return
 	}
 
@@ -409,6 +536,7 @@ func (e *AirswapClient) checkHealth() {
 
 func (e *AirswapClient) handleConnectivityError() {
 	e.lostSync = true
+	e.subSwapEvent.Unsubscribe()
 }
 
 func (e *AirswapClient) KeyRequired() bool {
@@ -420,18 +548,24 @@ func (e *AirswapClient) FetchPrice(_ []string) (common.Prices, error) {
 	defer e.priceMutex.RUnlock()
 	var prices common.Prices
 
-	for _, p := range e.lastAggregatedPrices {
-		prices = append(prices, p)
+	for _, p := range e.pairs {
+		if price, ok := e.lastAggregatedPrices[p.symbol]; ok {
+			prices = append(prices, price)
+		}
 	}
 
 	if len(prices) == 0 {
-		return prices, errors.New("dex-pluign hasn't receive any realtime swap event yet")
+		return prices, errors.New("airswap plugin hasn't received any swap event yet")
+	}
+
+	if !e.derivesNTNATN() {
+		return prices, nil
 	}
 
 	// both ATN-USDC and NTN-USDC price are collected, compute NTN-ATN price.
-	if len(prices) == 2 {
-		atnPrice := e.lastAggregatedPrices[e.atnAddress]
-		ntnPrice := e.lastAggregatedPrices[e.ntnAddress]
+	atnPrice, okATN := e.lastAggregatedPrices[ATNUSDC]
+	ntnPrice, okNTN := e.lastAggregatedPrices[NTNUSDC]
+	if okATN && okNTN {
 		ntnATNPrice, err := common.ComputeDerivedPrice(ntnPrice.Price, atnPrice.Price)
 		if err != nil {
 			e.logger.Error("cannot compute NTN-ATN price", "error", err.Error())
@@ -443,15 +577,34 @@ func (e *AirswapClient) FetchPrice(_ []string) (common.Prices, error) {
 	return prices, nil
 }
 
+// derivesNTNATN tells if the NTN-ATN price is derived from the ATN-USDC and NTN-USDC prices, it is not if there is no
+// such pairs, or if the NTN-ATN pair is configured by itself.
+func (e *AirswapClient) derivesNTNATN() bool {
+	symbols := make(map[string]bool)
+	for _, p := range e.pairs {
+		symbols[p.symbol] = true
+	}
+	return symbols[ATNUSDC] && symbols[NTNUSDC] && !symbols[common.NTNATNSymbol]
+}
+
 func (e *AirswapClient) AvailableSymbols() ([]string, error) {
-	return supportedSymbols, nil
+	symbols := make([]string, 0, len(e.pairs)+1)
+	for _, p := range e.pairs {
+		symbols = append(symbols, p.symbol)
+	}
+	if e.derivesNTNATN() {
+		symbols = append(symbols, common.NTNATNSymbol)
+	}
+	return symbols, nil
 }
 
 func (e *AirswapClient) Close() {
-	if e.client != nil {
-		e.client.Close()
+	if !e.lostSync {
+		e.subSwapEvent.Unsubscribe()
+	}
+	if e.closer != nil {
+		e.closer()
 	}
-	e.subSwapEvent.Unsubscribe()
 	e.doneCh <- struct{}{}
 }
 
@@ -462,7 +615,7 @@ func main() {
 		return
 	}
 
-	// start the SwapERC20 event watching for price aggregation of NTN-USDC & ATN-USDC
+	// start the SwapERC20 event watching for price aggregation of the configured pairs.
 	go client.StartWatcher()
 
 	adapter := common.NewPlugin(conf, client, version, types.SrcAFQ, common.ChainIDPiccadilly)
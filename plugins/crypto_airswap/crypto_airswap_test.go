@@ -1,11 +1,18 @@
 package main
 
 import (
+	"autonity-oracle/config"
+	tokenmeta "autonity-oracle/plugins/common/contracts/erc20"
+	"autonity-oracle/plugins/common/simchain"
+	"autonity-oracle/plugins/crypto_airswap/erc20"
 	swaperc20 "autonity-oracle/plugins/crypto_airswap/swap_erc20"
 	"github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
 	"math/big"
 	"testing"
+	"time"
 )
 
 // todo: Jason, the best way to test this could be dump the swap event logs into a json file, then we can remove the
@@ -13,10 +20,13 @@ import (
 func TestAirswapClientWithPiccadilly(t *testing.T) {
 	config := defaultConfig
 	config.Endpoint = "rpc2.piccadilly.autonity.org/ws"
+	config.SwapAddress = "0x28363983213F88C759b501E3a5888458178cD5E7"
+	// the swap history is not taken, as the order books are checked with the swaps below.
+	config.BackfillBlocks = 1
 
 	client, err := NewAirswapClient(&config)
 	require.NoError(t, err)
-	defer client.client.Close()
+	defer client.closer()
 	defer client.subSwapEvent.Unsubscribe()
 
 	swapEvents := []struct {
@@ -80,3 +90,160 @@ func TestAirswapClientWithPiccadilly(t *testing.T) {
 		require.Equal(t, swaps.ratio, prices[0].Price)
 	}
 }
+
+var (
+	testATN  = common.HexToAddress("0x0a00000000000000000000000000000000000001")
+	testUSDC = common.HexToAddress("0x0c00000000000000000000000000000000000001")
+	testNTN  = common.HexToAddress("0x0e00000000000000000000000000000000000001")
+	testSwap = common.HexToAddress("0x5000000000000000000000000000000000000001")
+	// the signer wallet of the orders, and the protocol fee wallet.
+	testSigner = common.HexToAddress("0x5100000000000000000000000000000000000001")
+	testFee    = common.HexToAddress("0xfee0000000000000000000000000000000000001")
+	// the taker of the orders.
+	testSender = common.HexToAddress("0x5200000000000000000000000000000000000001")
+)
+
+// mockCall is an event of 3 topics emitted by a mock contract.
+type mockCall struct {
+	contract common.Address
+	topics   [3]common.Hash
+	data     []byte
+}
+
+// sendCalls emits the events of the mock contracts in order by a transaction of the pending block.
+func sendCalls(sim *simchain.Chain, calls ...mockCall) {
+	logs := make([]tp.Log, len(calls))
+	for i, call := range calls {
+		logs[i] = tp.Log{Address: call.contract, Topics: append([]common.Hash(nil), call.topics[:]...), Data: call.data}
+	}
+	sim.Emit(logs...)
+}
+
+func TestAirswapClient(t *testing.T) {
+	tokenABI, err := tokenmeta.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	transferABI, err := erc20.Erc20MetaData.GetAbi()
+	require.NoError(t, err)
+	swapABI, err := swaperc20.Swaperc20MetaData.GetAbi()
+	require.NoError(t, err)
+
+	alloc := simchain.NewAlloc()
+	alloc.Deploy(testSwap)
+	for token, decimals := range map[common.Address]uint8{testATN: 18, testNTN: 18, testUSDC: 6} {
+		require.NoError(t, alloc.SetView(token, tokenABI, "decimals", nil, decimals))
+	}
+	sim := simchain.New(t, alloc)
+	defer sim.Close()
+
+	transfer := func(token, from, to common.Address, value *big.Int) mockCall {
+		data, err := transferABI.Events["Transfer"].Inputs.NonIndexed().Pack(value)
+		require.NoError(t, err)
+		return mockCall{contract: token, topics: [3]common.Hash{transferABI.Events["Transfer"].ID,
+			common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}, data: data}
+	}
+	nonce := int64(0)
+	// swap sends an order of the sender token of the sender for the signer token of the signer, a fee of 1% of the
+	// signer amount is paid to the protocol fee wallet.
+	swap := func(senderToken common.Address, senderAmount *big.Int, signerToken common.Address, signerAmount *big.Int) {
+		nonce++
+		fee := new(big.Int).Div(signerAmount, big.NewInt(100))
+		sendCalls(sim,
+			transfer(senderToken, testSender, testSigner, senderAmount),
+			transfer(signerToken, testSigner, testSender, signerAmount),
+			transfer(signerToken, testSigner, testFee, fee),
+			mockCall{contract: testSwap, topics: [3]common.Hash{swapABI.Events["SwapERC20"].ID,
+				common.BigToHash(big.NewInt(nonce)), common.BytesToHash(testSigner.Bytes())}})
+	}
+	amount := func(units int64, decimals int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(units), new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil))
+	}
+
+	// the swap history: 100 USDC for 400 ATN, 1600 NTN for 100 USDC, and 10 ATN for 40 NTN which is not a pair.
+	swap(testUSDC, amount(100, 6), testATN, amount(400, 18))
+	swap(testNTN, amount(1600, 18), testUSDC, amount(100, 6))
+	swap(testATN, amount(10, 18), testNTN, amount(40, 18))
+	sim.Commit()
+
+	conf := &config.PluginConfig{
+		Name:             "crypto_airswap",
+		Timeout:          10,
+		ATNTokenAddress:  testATN.Hex(),
+		NTNTokenAddress:  testNTN.Hex(),
+		USDCTokenAddress: testUSDC.Hex(),
+		SwapAddress:      testSwap.Hex(),
+	}
+
+	t.Run("default pairs with the swap history", func(t *testing.T) {
+		client, err := newAirswapClient(conf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		require.Len(t, client.pairs, 2)
+		require.Equal(t, uint8(18), client.pairs[0].baseDecimals)
+		require.Equal(t, uint8(6), client.pairs[0].quoteDecimals)
+
+		go client.StartWatcher()
+		defer client.Close()
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{ATNUSDC, NTNUSDC, "NTN-ATN"}, symbols)
+
+		// the swaps of the pairs are backfilled into their own order books, whichever side the tokens are.
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, ATNUSDC, prices[0].Symbol)
+		require.Equal(t, "4.000000000000000000", prices[0].Price)
+		require.Equal(t, "100000000", prices[0].Volume)
+		require.Equal(t, NTNUSDC, prices[1].Symbol)
+		require.Equal(t, "16.000000000000000000", prices[1].Price)
+		require.Equal(t, "4", prices[2].Price)
+
+		// 500 ATN for 100 USDC, the VWAP of ATN-USDC is 4.5.
+		swap(testATN, amount(500, 18), testUSDC, amount(100, 6))
+		sim.Commit()
+		require.Eventually(t, func() bool {
+			prices, err := client.FetchPrice(symbols)
+			return err == nil && prices[0].Volume == "200000000"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		prices, err = client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Equal(t, "4.500000000000000000", prices[0].Price)
+		require.Equal(t, "16.000000000000000000", prices[1].Price)
+	})
+
+	t.Run("configured pair is re-synced after a dropped subscription", func(t *testing.T) {
+		pairConf := *conf
+		pairConf.Pairs = []config.PairConfig{
+			{Symbol: "NTN-USDCx", Base: testNTN.Hex(), Quote: testUSDC.Hex(), BaseDecimals: 18, QuoteDecimals: 6},
+		}
+		client, err := newAirswapClient(&pairConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{"NTN-USDCx"}, symbols)
+
+		// 2000 NTN for 100 USDC while the subscription is dropped, the swap is backfilled once it is re-subscribed.
+		client.handleConnectivityError()
+		swap(testUSDC, amount(100, 6), testNTN, amount(2000, 18))
+		sim.Commit()
+		client.checkHealth()
+		require.False(t, client.lostSync)
+		defer client.subSwapEvent.Unsubscribe()
+
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 1)
+		require.Equal(t, "NTN-USDCx", prices[0].Symbol)
+		require.Equal(t, "18.000000000000000000", prices[0].Price)
+		require.Equal(t, "200000000", prices[0].Volume)
+	})
+
+	t.Run("swap contract address is required", func(t *testing.T) {
+		noSwapConf := *conf
+		noSwapConf.SwapAddress = ""
+		_, err := newAirswapClient(&noSwapConf, sim, nil, hclog.NewNullLogger())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"os"
+	"strings"
+	"time"
+)
+
+const version = "v0.0.1"
+
+var defaultSymbols = []string{common.DefaultUSDCSymbol}
+
+var defaultConfig = config.PluginConfig{
+	Name:     "crypto_binance_stream",
+	Scheme:   "wss",
+	Endpoint: "stream.binance.us:9443/ws",
+	Timeout:  10, // 10s
+	// the prices are read from the local cache of the stream, thus there is no rate limit.
+	DataUpdateInterval: 1,
+}
+
+// subscribeRequest is the live subscription request of the Binance streams.
+type subscribeRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int      `json:"id"`
+}
+
+// tickerEvent is the event of the individual symbol ticker stream <symbol>@ticker. The keys of Binance differ in case
+// only, i.e. b for the best bid and B for its quantity, and the JSON keys are matched case-insensitively, thus both keys
+// of a pair are declared.
+type tickerEvent struct {
+	Event      string          `json:"e"`
+	EventTime  int64           `json:"E"` // in milliseconds.
+	Symbol     string          `json:"s"`
+	Last       decimal.Decimal `json:"c"`
+	CloseTime  int64           `json:"C"` // in milliseconds.
+	BestBid    decimal.Decimal `json:"b"`
+	BestBidQty decimal.Decimal `json:"B"`
+	BestAsk    decimal.Decimal `json:"a"`
+	BestAskQty decimal.Decimal `json:"A"`
+}
+
+// BinanceAdapter adapts the ticker streams of Binance, the last price of a ticker is taken with its best bid and ask.
+type BinanceAdapter struct {
+	symbols map[string]string // the protocol symbols by the Binance symbols, i.e. USDC-USD by USDCUSD.
+}
+
+func NewBinanceAdapter(symbols []string) *BinanceAdapter {
+	a := &BinanceAdapter{symbols: make(map[string]string)}
+	for _, symbol := range symbols {
+		a.symbols[binanceSymbol(symbol)] = symbol
+	}
+	return a
+}
+
+// binanceSymbol converts a protocol symbol into the Binance symbol, i.e. USDC-USD into USDCUSD.
+func binanceSymbol(symbol string) string {
+	return strings.ToUpper(strings.ReplaceAll(symbol, "-", ""))
+}
+
+func (a *BinanceAdapter) Subscriptions(symbols []string) ([]interface{}, error) {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = strings.ToLower(binanceSymbol(symbol)) + "@ticker"
+	}
+	return []interface{}{subscribeRequest{Method: "SUBSCRIBE", Params: streams, ID: 1}}, nil
+}
+
+func (a *BinanceAdapter) ParseMessage(msg []byte) ([]common.Tick, error) {
+	var event tickerEvent
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return nil, err
+	}
+	// the responses of the subscriptions are not events.
+	if event.Event != "24hrTicker" {
+		return nil, nil
+	}
+
+	symbol, ok := a.symbols[event.Symbol]
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is not subscribed", event.Symbol)
+	}
+	return []common.Tick{{
+		Symbol:    symbol,
+		Price:     event.Last,
+		Bid:       event.BestBid.String(),
+		Ask:       event.BestAsk.String(),
+		Timestamp: time.UnixMilli(event.EventTime),
+	}}, nil
+}
+
+// PingMessage returns nil, as Binance pings the client, and it takes the WebSocket pings of the client.
+func (a *BinanceAdapter) PingMessage() interface{} {
+	return nil
+}
+
+func NewBinanceStreamClient(conf *config.PluginConfig) *common.StreamClient {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	symbols := conf.Symbols
+	if len(symbols) == 0 {
+		symbols = defaultSymbols
+	}
+	return common.NewStreamClient(conf, NewBinanceAdapter(symbols), symbols, logger)
+}
+
+func main() {
+	conf := common.ResolveConf(os.Args[0], &defaultConfig)
+	client := NewBinanceStreamClient(conf)
+	client.Start()
+
+	adapter := common.NewPlugin(conf, client, version, types.SrcCEX, nil)
+	defer adapter.Close()
+	common.PluginServe(adapter)
+}
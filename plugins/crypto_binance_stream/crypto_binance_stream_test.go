@@ -0,0 +1,102 @@
+package main
+
+import (
+	"autonity-oracle/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinanceStreamClient(t *testing.T) {
+	subscribed := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	// the local stand-in of the Binance stream, it acks the subscription and sends the tickers.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		subscribed <- string(msg)
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		for _, m := range []string{
+			`{"result":null,"id":1}`,
+			`{"e":"24hrTicker","E":` + ts + `,"s":"USDCUSD","c":"0.9990","C":` + ts + `,"b":"0.9989","B":"300","a":"0.9991","A":"100"}`,
+			`{"e":"24hrTicker","E":` + ts + `,"s":"USDCUSD","c":"0.9995","C":` + ts + `,"b":"0.9994","B":"300","a":"0.9996","A":"100"}`,
+			`{"e":"24hrTicker","E":` + ts + `,"s":"BTCUSD","c":"60000","C":` + ts + `,"b":"59999","B":"1","a":"60001","A":"1"}`,
+		} {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(m))
+		}
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conf := defaultConfig
+	conf.Scheme = "ws"
+	conf.Endpoint = strings.TrimPrefix(server.URL, "http://")
+	conf.Symbols = nil
+	client := NewBinanceStreamClient(&conf)
+	client.Start()
+	defer client.Close()
+
+	select {
+	case msg := <-subscribed:
+		require.JSONEq(t, `{"method":"SUBSCRIBE","params":["usdcusd@ticker"],"id":1}`, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is not subscribed")
+	}
+
+	require.Eventually(t, func() bool {
+		prices, err := client.FetchPrice([]string{"USDC-USD"})
+		return err == nil && prices[0].Price == "0.9995"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the last price of the ticker is taken with its best bid and ask.
+	prices, err := client.FetchPrice([]string{"USDC-USD"})
+	require.NoError(t, err)
+	require.Equal(t, "0.9994", prices[0].Bid)
+	require.Equal(t, "0.9996", prices[0].Ask)
+	require.Equal(t, types.DefaultVolume.String(), prices[0].Volume)
+}
+
+func TestBinanceAdapter(t *testing.T) {
+	adapter := NewBinanceAdapter([]string{"USDC-USD", "BTC-USDT"})
+	subscriptions, err := adapter.Subscriptions([]string{"USDC-USD", "BTC-USDT"})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{subscribeRequest{Method: "SUBSCRIBE", Params: []string{"usdcusd@ticker", "btcusdt@ticker"}, ID: 1}},
+		subscriptions)
+
+	ticks, err := adapter.ParseMessage([]byte(`{"e":"24hrTicker","E":1700000000123,"s":"BTCUSDT","p":"-12.5","P":"-0.02",
+		"c":"60000.5","Q":"0.01","b":"60000.4","B":"2.5","a":"60000.6","A":"1.5","o":"60013","O":1699913600123,
+		"C":1700000000100,"q":"1000000","l":"59000","L":12345}`))
+	require.NoError(t, err)
+	require.Len(t, ticks, 1)
+	require.Equal(t, "BTC-USDT", ticks[0].Symbol)
+	require.Equal(t, "60000.5", ticks[0].Price.String())
+	require.Equal(t, "60000.4", ticks[0].Bid)
+	require.Equal(t, "60000.6", ticks[0].Ask)
+	require.False(t, ticks[0].Trade)
+	require.Equal(t, int64(1700000000123), ticks[0].Timestamp.UnixMilli())
+
+	ticks, err = adapter.ParseMessage([]byte(`{"result":null,"id":1}`))
+	require.NoError(t, err)
+	require.Empty(t, ticks)
+
+	_, err = adapter.ParseMessage([]byte(`{"e":"24hrTicker","E":1700000000123,"s":"ETHUSDT","c":"3000"}`))
+	require.Error(t, err)
+	require.Nil(t, adapter.PingMessage())
+}
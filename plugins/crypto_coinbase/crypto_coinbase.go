@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/url"
 	"os"
-	"time"
 )
 
 const (
@@ -43,7 +42,7 @@ type CoinBaseClient struct {
 }
 
 func NewCoinBaseClient(conf *config.PluginConfig) *CoinBaseClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   conf.Name,
 		Level:  hclog.Info,
@@ -10,7 +10,6 @@ import (
 	"net/url"
 	"os"
 	"strconv"
-	"time"
 )
 
 const (
@@ -46,7 +45,7 @@ type CoinGeckoClient struct {
 }
 
 func NewCoinGeckoClient(conf *config.PluginConfig) *CoinGeckoClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   conf.Name,
 		Level:  hclog.Info,
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var version = "v0.0.1"
+
+var (
+	errNoCalls      = errors.New("no view call is configured")
+	errStaleData    = errors.New("the data of the view call is stale")
+	errInvalidPrice = errors.New("the price of the view call is not positive")
+)
+
+var defaultConfig = config.PluginConfig{
+	Name:               "crypto_evm_call",
+	Scheme:             "wss",                                       // both http/s ws/s works for this plugin
+	Endpoint:           "rpc-internal-1.piccadilly.autonity.org/ws", // default websocket endpoint for piccadilly network.
+	Timeout:            10,                                          // 10s
+	DataUpdateInterval: common.DefaultAMMDataUpdateInterval,         // 1s, the view calls are served by the operator's own node.
+}
+
+// viewCall is a configured view call, its input is packed on start, and its output is unpacked on each fetch.
+type viewCall struct {
+	symbol    string
+	contract  ecommon.Address
+	method    abi.Method
+	input     []byte
+	output    int // the index of the output field of the price.
+	updatedAt int // the index of the output field of the update time, it is -1 if it is not configured.
+	decimals  uint8
+	maxAge    int
+}
+
+type EVMCallClient struct {
+	conf    *config.PluginConfig
+	backend bind.ContractCaller
+	closer  func()
+	logger  hclog.Logger
+
+	calls   []*viewCall
+	symbols []string // the symbols of the calls in the order of configuration.
+}
+
+func NewEVMCallClient(conf *config.PluginConfig) (*EVMCallClient, error) {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	url := conf.Scheme + "://" + conf.Endpoint
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		logger.Error("cannot dial to L1 node", "error", err)
+		return nil, err
+	}
+
+	ec, err := newEVMCallClient(conf, client, client.Close, logger)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return ec, nil
+}
+
+func newEVMCallClient(conf *config.PluginConfig, backend bind.ContractCaller, closer func(), logger hclog.Logger) (*EVMCallClient, error) {
+	if len(conf.Calls) == 0 {
+		logger.Error("cannot start plugin", "error", errNoCalls)
+		return nil, errNoCalls
+	}
+
+	ec := &EVMCallClient{
+		conf:    conf,
+		backend: backend,
+		closer:  closer,
+		logger:  logger,
+	}
+
+	known := make(map[string]bool)
+	for i, callConf := range conf.Calls {
+		call, err := newViewCall(callConf)
+		if err != nil {
+			logger.Error("cannot bind view call", "index", i, "symbol", callConf.Symbol, "error", err)
+			return nil, fmt.Errorf("call %d of %s: %w", i, callConf.Symbol, err)
+		}
+		ec.calls = append(ec.calls, call)
+		if !known[call.symbol] {
+			known[call.symbol] = true
+			ec.symbols = append(ec.symbols, call.symbol)
+		}
+	}
+	return ec, nil
+}
+
+// newViewCall resolves the view method of the ABI fragment, it packs the input with the arguments, and it resolves the
+// output fields of the price and of the update time.
+func newViewCall(conf config.CallConfig) (*viewCall, error) {
+	fragment := strings.TrimSpace(conf.ABI)
+	if strings.HasPrefix(fragment, "{") {
+		fragment = "[" + fragment + "]"
+	}
+	parsed, err := abi.JSON(strings.NewReader(fragment))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI fragment: %w", err)
+	}
+
+	var method abi.Method
+	if conf.Method == "" {
+		if len(parsed.Methods) != 1 {
+			return nil, fmt.Errorf("the method is required as the ABI fragment has %d methods", len(parsed.Methods))
+		}
+		for _, m := range parsed.Methods {
+			method = m
+		}
+	} else {
+		var ok bool
+		if method, ok = parsed.Methods[conf.Method]; !ok {
+			return nil, fmt.Errorf("method %s is not found in the ABI fragment", conf.Method)
+		}
+	}
+	if !method.IsConstant() {
+		return nil, fmt.Errorf("method %s is not a view method", method.Name)
+	}
+
+	if len(conf.Args) != len(method.Inputs) {
+		return nil, fmt.Errorf("method %s takes %d arguments, %d are configured", method.Name, len(method.Inputs), len(conf.Args))
+	}
+	args := make([]interface{}, len(conf.Args))
+	for i, arg := range conf.Args {
+		if args[i], err = parseArg(method.Inputs[i].Type, arg); err != nil {
+			return nil, fmt.Errorf("argument %d of method %s: %w", i, method.Name, err)
+		}
+	}
+	input, err := parsed.Pack(method.Name, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := outputIndex(method, conf.Output)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt := -1
+	if conf.UpdatedAt != "" {
+		if updatedAt, err = outputIndex(method, conf.UpdatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return &viewCall{
+		symbol:    conf.Symbol,
+		contract:  ecommon.HexToAddress(conf.Contract),
+		method:    method,
+		input:     input,
+		output:    output,
+		updatedAt: updatedAt,
+		decimals:  conf.Decimals,
+		maxAge:    conf.MaxAge,
+	}, nil
+}
+
+// outputIndex resolves an output field of the method by its name or by its index, it is the first output if omitted.
+func outputIndex(method abi.Method, field string) (int, error) {
+	if len(method.Outputs) == 0 {
+		return 0, fmt.Errorf("method %s has no output", method.Name)
+	}
+	if field == "" {
+		return 0, nil
+	}
+	for i, output := range method.Outputs {
+		if output.Name == field {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(field); err == nil && i >= 0 && i < len(method.Outputs) {
+		return i, nil
+	}
+	return 0, fmt.Errorf("output %s is not found in method %s", field, method.Name)
+}
+
+// parseArg parses a configured argument by the type of the method input, the integers, the addresses, the bools, the
+// strings and the fixed bytes are supported.
+func parseArg(t abi.Type, arg string) (interface{}, error) {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		value, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", arg)
+		}
+		if t.T == abi.UintTy && (value.Sign() < 0 || value.BitLen() > t.Size) ||
+			t.T == abi.IntTy && value.BitLen() > t.Size-1 && value.Cmp(new(big.Int).Lsh(big.NewInt(-1), uint(t.Size-1))) != 0 {
+			return nil, fmt.Errorf("%s overflows %s", arg, t.String())
+		}
+		goType := t.GetType()
+		if goType == reflect.TypeOf(value) {
+			return value, nil
+		}
+		// the integers up to 64 bits are packed from their go types.
+		v := reflect.New(goType).Elem()
+		if t.T == abi.IntTy {
+			v.SetInt(value.Int64())
+		} else {
+			v.SetUint(value.Uint64())
+		}
+		return v.Interface(), nil
+	case abi.AddressTy:
+		if !ecommon.IsHexAddress(arg) {
+			return nil, fmt.Errorf("%q is not a valid hex address", arg)
+		}
+		return ecommon.HexToAddress(arg), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(arg)
+	case abi.StringTy:
+		return arg, nil
+	case abi.FixedBytesTy:
+		b, err := hex.DecodeString(strings.TrimPrefix(arg, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.Size {
+			return nil, fmt.Errorf("%q overflows %s", arg, t.String())
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	default:
+		return nil, fmt.Errorf("type %s is not supported", t.String())
+	}
+}
+
+// toBigInt converts an unpacked integer output into a big integer.
+func toBigInt(value interface{}) (*big.Int, error) {
+	if v, ok := value.(*big.Int); ok {
+		return v, nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(v.Uint()), nil
+	default:
+		return nil, fmt.Errorf("output of type %T is not an integer", value)
+	}
+}
+
+// fetch calls the view method, and it returns the output field of the price scaled by the decimals, the data is stale
+// if its update time is older than the max age.
+func (e *EVMCallClient) fetch(call *viewCall) (common.Price, error) {
+	var price common.Price
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.conf.Timeout)*time.Second)
+	defer cancel()
+	data, err := e.backend.CallContract(ctx, ethereum.CallMsg{To: &call.contract, Data: call.input}, nil)
+	if err != nil {
+		return price, err
+	}
+
+	outputs, err := call.method.Outputs.Unpack(data)
+	if err != nil {
+		return price, err
+	}
+	if len(outputs) != len(call.method.Outputs) {
+		return price, fmt.Errorf("method %s returns %d outputs, %d are expected", call.method.Name, len(outputs),
+			len(call.method.Outputs))
+	}
+
+	value, err := toBigInt(outputs[call.output])
+	if err != nil {
+		return price, err
+	}
+	if value.Sign() <= 0 {
+		return price, errInvalidPrice
+	}
+
+	if call.updatedAt >= 0 {
+		updatedAt, err := toBigInt(outputs[call.updatedAt])
+		if err != nil {
+			return price, err
+		}
+		price.Timestamp = updatedAt.Int64()
+		if call.maxAge > 0 && time.Now().Unix()-price.Timestamp > int64(call.maxAge) {
+			return price, fmt.Errorf("%w: updated at %d", errStaleData, price.Timestamp)
+		}
+	}
+
+	price.Symbol = call.symbol
+	price.Price = decimal.NewFromBigInt(value, -int32(call.decimals)).String()
+	price.Volume = types.DefaultVolume.String()
+	price.Venue = call.contract.Hex()
+	return price, nil
+}
+
+// FetchPrice prices each symbol by its calls in order, a symbol is not reported if all of its calls fail.
+func (e *EVMCallClient) FetchPrice(symbols []string) (common.Prices, error) {
+	var prices common.Prices
+	for _, symbol := range symbols {
+		for _, call := range e.calls {
+			if call.symbol != symbol {
+				continue
+			}
+			price, err := e.fetch(call)
+			if err != nil {
+				e.logger.Warn("failed to price by view call, falling back to the next one", "symbol", symbol,
+					"contract", call.contract, "method", call.method.Name, "error", err)
+				continue
+			}
+			prices = append(prices, price)
+			break
+		}
+	}
+	return prices, nil
+}
+
+func (e *EVMCallClient) KeyRequired() bool {
+	return false
+}
+
+func (e *EVMCallClient) AvailableSymbols() ([]string, error) {
+	return e.symbols, nil
+}
+
+func (e *EVMCallClient) Close() {
+	if e.closer != nil {
+		e.closer()
+	}
+}
+
+func main() {
+	conf := common.ResolveConf(os.Args[0], &defaultConfig)
+	client, err := NewEVMCallClient(conf)
+	if err != nil {
+		return
+	}
+
+	adapter := common.NewPlugin(conf, client, version, types.SrcCEX, nil)
+	defer adapter.Close()
+	common.PluginServe(adapter)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common/simchain"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	aggregatorABI = `{"name":"latestRoundData","type":"function","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}]}`
+	vaultABI = `[{"name":"convertToAssets","type":"function","stateMutability":"view","inputs":[{"name":"shares","type":"uint256"}],
+		"outputs":[{"name":"assets","type":"uint256"}]},
+		{"name":"deposit","type":"function","stateMutability":"nonpayable","inputs":[{"name":"assets","type":"uint256"},
+		{"name":"receiver","type":"address"}],"outputs":[{"name":"shares","type":"uint256"}]}]`
+)
+
+var (
+	testFeed      = ecommon.HexToAddress("0xfeed000000000000000000000000000000000001")
+	testStaleFeed = ecommon.HexToAddress("0xfeed000000000000000000000000000000000002")
+	testBadFeed   = ecommon.HexToAddress("0xfeed000000000000000000000000000000000003")
+	testVault     = ecommon.HexToAddress("0x7a00000000000000000000000000000000000001")
+)
+
+func mockFeed(t *testing.T, alloc *simchain.Alloc, feed ecommon.Address, feedABI *abi.ABI, answer int64, updatedAt int64) {
+	require.NoError(t, alloc.SetView(feed, feedABI, "latestRoundData", nil, big.NewInt(7), big.NewInt(answer),
+		big.NewInt(updatedAt), big.NewInt(updatedAt), big.NewInt(7)))
+}
+
+func TestEVMCallClient(t *testing.T) {
+	feedABI, err := abi.JSON(strings.NewReader("[" + aggregatorABI + "]"))
+	require.NoError(t, err)
+	vaultContractABI, err := abi.JSON(strings.NewReader(vaultABI))
+	require.NoError(t, err)
+
+	alloc := simchain.NewAlloc()
+	oneShare := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	require.NoError(t, alloc.SetView(testVault, &vaultContractABI, "convertToAssets", []interface{}{oneShare}, big.NewInt(1_050_000)))
+
+	now := time.Now().Unix()
+	mockFeed(t, alloc, testFeed, &feedABI, 123456789, now)
+	mockFeed(t, alloc, testStaleFeed, &feedABI, 200000000, now-3600)
+	mockFeed(t, alloc, testBadFeed, &feedABI, -1, now)
+	sim := simchain.New(t, alloc)
+	defer sim.Close()
+
+	feedCall := func(symbol string, feed ecommon.Address) config.CallConfig {
+		return config.CallConfig{Symbol: symbol, Contract: feed.Hex(), ABI: aggregatorABI, Output: "answer", Decimals: 8,
+			UpdatedAt: "updatedAt", MaxAge: 600}
+	}
+	conf := &config.PluginConfig{
+		Name:    "crypto_evm_call",
+		Timeout: 10,
+		Calls: []config.CallConfig{
+			feedCall("ATN-USD", testFeed),
+			// the stale feed falls back to the vault share price.
+			feedCall("NTN-USD", testStaleFeed),
+			{Symbol: "NTN-USD", Contract: testVault.Hex(), ABI: vaultABI, Method: "convertToAssets",
+				Args: []string{oneShare.String()}, Decimals: 6},
+			feedCall("USDC-USD", testBadFeed),
+		},
+	}
+
+	t.Run("view calls are priced by symbol", func(t *testing.T) {
+		client, err := newEVMCallClient(conf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		defer client.Close()
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{"ATN-USD", "NTN-USD", "USDC-USD"}, symbols)
+
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 2)
+		require.Equal(t, "ATN-USD", prices[0].Symbol)
+		require.Equal(t, "1.23456789", prices[0].Price)
+		require.Equal(t, now, prices[0].Timestamp)
+		require.Equal(t, testFeed.Hex(), prices[0].Venue)
+
+		require.Equal(t, "NTN-USD", prices[1].Symbol)
+		require.Equal(t, "1.05", prices[1].Price)
+		require.Equal(t, int64(0), prices[1].Timestamp)
+		require.Equal(t, testVault.Hex(), prices[1].Venue)
+	})
+
+	t.Run("stale data is checked against the max age", func(t *testing.T) {
+		client, err := newEVMCallClient(conf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+
+		_, err = client.fetch(client.calls[1])
+		require.ErrorIs(t, err, errStaleData)
+		_, err = client.fetch(client.calls[3])
+		require.ErrorIs(t, err, errInvalidPrice)
+	})
+
+	t.Run("view calls are resolved on start", func(t *testing.T) {
+		tests := []struct {
+			call config.CallConfig
+			err  string
+		}{
+			{config.CallConfig{ABI: vaultABI}, "the method is required as the ABI fragment has 2 methods"},
+			{config.CallConfig{ABI: vaultABI, Method: "totalAssets"}, "method totalAssets is not found in the ABI fragment"},
+			{config.CallConfig{ABI: vaultABI, Method: "deposit", Args: []string{"1", testVault.Hex()}}, "method deposit is not a view method"},
+			{config.CallConfig{ABI: vaultABI, Method: "convertToAssets"}, "method convertToAssets takes 1 arguments, 0 are configured"},
+			{config.CallConfig{ABI: vaultABI, Method: "convertToAssets", Args: []string{"-1"}}, "argument 0 of method convertToAssets: -1 overflows uint256"},
+			{config.CallConfig{ABI: aggregatorABI, Output: "price"}, "output price is not found in method latestRoundData"},
+			{config.CallConfig{ABI: aggregatorABI, UpdatedAt: "5"}, "output 5 is not found in method latestRoundData"},
+		}
+		for _, test := range tests {
+			_, err := newViewCall(test.call)
+			require.EqualError(t, err, test.err)
+		}
+
+		call, err := newViewCall(config.CallConfig{ABI: aggregatorABI, Output: "1", UpdatedAt: "updatedAt"})
+		require.NoError(t, err)
+		require.Equal(t, 1, call.output)
+		require.Equal(t, 3, call.updatedAt)
+
+		_, err = newEVMCallClient(&config.PluginConfig{Name: "crypto_evm_call"}, sim, nil, hclog.NewNullLogger())
+		require.ErrorIs(t, err, errNoCalls)
+	})
+}
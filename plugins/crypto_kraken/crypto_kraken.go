@@ -10,7 +10,6 @@ import (
 	"io"
 	"net/url"
 	"os"
-	"time"
 )
 
 const (
@@ -53,7 +52,7 @@ type KrakenClient struct {
 }
 
 func NewKrakenClient(conf *config.PluginConfig) *KrakenClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   conf.Name,
 		Level:  hclog.Info,
@@ -123,6 +122,13 @@ func (k *KrakenClient) toPrice(symbol string, res *Response) (common.Price, erro
 	price.Symbol = symbol
 	price.Price = usdcResult.P[0] // take the volume weighted average price of today.
 	price.Volume = types.DefaultVolume.String()
+	price.Venue = supportedSymbol
+	if len(usdcResult.B) > 0 {
+		price.Bid = usdcResult.B[0]
+	}
+	if len(usdcResult.A) > 0 {
+		price.Ask = usdcResult.A[0]
+	}
 	return price, nil
 }
 
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"os"
+	"strings"
+	"time"
+)
+
+const version = "v0.0.1"
+
+var defaultSymbols = []string{common.DefaultUSDCSymbol}
+
+var defaultConfig = config.PluginConfig{
+	Name:     "crypto_kraken_stream",
+	Scheme:   "wss",
+	Endpoint: "ws.kraken.com/v2",
+	Timeout:  10, // 10s
+	// the prices are read from the local cache of the stream, thus there is no rate limit.
+	DataUpdateInterval: 1,
+}
+
+type subscribeParams struct {
+	Channel string   `json:"channel"`
+	Symbol  []string `json:"symbol"`
+}
+
+// request is a request of the Kraken v2 WebSocket API, i.e. subscribe or ping.
+type request struct {
+	Method string           `json:"method"`
+	Params *subscribeParams `json:"params,omitempty"`
+}
+
+type ticker struct {
+	Symbol string          `json:"symbol"`
+	Bid    decimal.Decimal `json:"bid"`
+	Ask    decimal.Decimal `json:"ask"`
+	Last   decimal.Decimal `json:"last"`
+}
+
+// message is a message of the Kraken v2 WebSocket API, it is a response of a request if its method is set, otherwise it
+// is the data of a channel.
+type message struct {
+	Method  string   `json:"method"`
+	Success *bool    `json:"success"`
+	Error   string   `json:"error"`
+	Channel string   `json:"channel"`
+	Data    []ticker `json:"data"`
+}
+
+// KrakenAdapter adapts the ticker channel of the Kraken v2 WebSocket API, the last trade price of a ticker is taken.
+type KrakenAdapter struct {
+	symbols map[string]string // the protocol symbols by the Kraken symbols, i.e. USDC-USD by USDC/USD.
+}
+
+func NewKrakenAdapter(symbols []string) *KrakenAdapter {
+	a := &KrakenAdapter{symbols: make(map[string]string)}
+	for _, symbol := range symbols {
+		a.symbols[krakenSymbol(symbol)] = symbol
+	}
+	return a
+}
+
+// krakenSymbol converts a protocol symbol into the Kraken symbol, i.e. USDC-USD into USDC/USD.
+func krakenSymbol(symbol string) string {
+	return strings.ReplaceAll(symbol, "-", "/")
+}
+
+func (a *KrakenAdapter) Subscriptions(symbols []string) ([]interface{}, error) {
+	krakenSymbols := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		krakenSymbols[i] = krakenSymbol(symbol)
+	}
+	return []interface{}{request{Method: "subscribe", Params: &subscribeParams{Channel: "ticker", Symbol: krakenSymbols}}}, nil
+}
+
+func (a *KrakenAdapter) ParseMessage(msg []byte) ([]common.Tick, error) {
+	var m message
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	if m.Method != "" {
+		if m.Success != nil && !*m.Success {
+			return nil, fmt.Errorf("%s request failed: %s", m.Method, m.Error)
+		}
+		return nil, nil
+	}
+	// the heartbeats and the status of the channels are not tickers.
+	if m.Channel != "ticker" {
+		return nil, nil
+	}
+
+	// the tickers of Kraken carry no event time, the receive time is taken.
+	now := time.Now()
+	var ticks []common.Tick
+	for _, t := range m.Data {
+		symbol, ok := a.symbols[t.Symbol]
+		if !ok {
+			return nil, fmt.Errorf("symbol %s is not subscribed", t.Symbol)
+		}
+		ticks = append(ticks, common.Tick{
+			Symbol:    symbol,
+			Price:     t.Last,
+			Bid:       t.Bid.String(),
+			Ask:       t.Ask.String(),
+			Timestamp: now,
+		})
+	}
+	return ticks, nil
+}
+
+// PingMessage returns the application level ping of Kraken, as it drops the silent connections.
+func (a *KrakenAdapter) PingMessage() interface{} {
+	return request{Method: "ping"}
+}
+
+func NewKrakenStreamClient(conf *config.PluginConfig) *common.StreamClient {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	symbols := conf.Symbols
+	if len(symbols) == 0 {
+		symbols = defaultSymbols
+	}
+	return common.NewStreamClient(conf, NewKrakenAdapter(symbols), symbols, logger)
+}
+
+func main() {
+	conf := common.ResolveConf(os.Args[0], &defaultConfig)
+	client := NewKrakenStreamClient(conf)
+	client.Start()
+
+	adapter := common.NewPlugin(conf, client, version, types.SrcCEX, nil)
+	defer adapter.Close()
+	common.PluginServe(adapter)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKrakenStreamClient(t *testing.T) {
+	subscribed := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	// the local stand-in of the Kraken v2 WebSocket API, it acks the subscription and sends the tickers.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		subscribed <- string(msg)
+		for _, m := range []string{
+			`{"method":"subscribe","result":{"channel":"ticker","symbol":"USDC/USD"},"success":true}`,
+			`{"channel":"status","type":"update","data":[{"system":"online"}]}`,
+			`{"channel":"ticker","type":"snapshot","data":[{"symbol":"USDC/USD","bid":0.9998,"ask":1.0001,"last":0.9999,"volume":1000}]}`,
+			`{"channel":"heartbeat"}`,
+			`{"channel":"ticker","type":"update","data":[{"symbol":"USDC/USD","bid":0.9999,"ask":1.0002,"last":1.0001,"volume":1010}]}`,
+		} {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(m))
+		}
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conf := defaultConfig
+	conf.Scheme = "ws"
+	conf.Endpoint = strings.TrimPrefix(server.URL, "http://")
+	client := NewKrakenStreamClient(&conf)
+	client.Start()
+	defer client.Close()
+
+	select {
+	case msg := <-subscribed:
+		require.JSONEq(t, `{"method":"subscribe","params":{"channel":"ticker","symbol":["USDC/USD"]}}`, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream is not subscribed")
+	}
+
+	require.Eventually(t, func() bool {
+		prices, err := client.FetchPrice([]string{"USDC-USD"})
+		return err == nil && prices[0].Price == "1.0001"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	prices, err := client.FetchPrice([]string{"USDC-USD"})
+	require.NoError(t, err)
+	require.Equal(t, "0.9999", prices[0].Bid)
+	require.Equal(t, "1.0002", prices[0].Ask)
+	require.Equal(t, "1000000", prices[0].Volume)
+}
+
+func TestKrakenAdapter(t *testing.T) {
+	adapter := NewKrakenAdapter([]string{"USDC-USD"})
+	ticks, err := adapter.ParseMessage([]byte(`{"method":"subscribe","error":"Currency pair not supported USDC/EUR","success":false}`))
+	require.EqualError(t, err, "subscribe request failed: Currency pair not supported USDC/EUR")
+	require.Empty(t, ticks)
+
+	ticks, err = adapter.ParseMessage([]byte(`{"method":"pong","time_in":"2024-01-01T00:00:00Z"}`))
+	require.NoError(t, err)
+	require.Empty(t, ticks)
+
+	_, err = adapter.ParseMessage([]byte(`{"channel":"ticker","data":[{"symbol":"BTC/USD","last":60000}]}`))
+	require.Error(t, err)
+	require.Equal(t, request{Method: "ping"}, adapter.PingMessage())
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"autonity-oracle/plugins/common"
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"time"
+)
+
+// headNumber returns the number of the latest block of the L1 node.
+func headNumber(backend ChainBackend, timeout int) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	header, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+// filterLogs returns the logs of the events of the contracts in the blocks from start to head.
+func filterLogs(backend ChainBackend, timeout int, addresses []ecommon.Address, topics []ecommon.Hash, start, head uint64) ([]tp.Log, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(start),
+		ToBlock:   new(big.Int).SetUint64(head),
+		Addresses: addresses,
+		Topics:    [][]ecommon.Hash{topics},
+	}
+	return backend.FilterLogs(ctx, query)
+}
+
+// backfill takes the Swap and Sync events of a pair which were emitted while it was not subscribed, they are the ones
+// since its synced block, or the ones of the backfill blocks on start, up to the head block. Thus, the order book is
+// complete after a start or a re-subscription. The subscription might deliver the events of the head block again, they
+// are not taken twice.
+func (e *UniswapClient) backfill(p *WrappedPair) error {
+	head, err := headNumber(e.backend, e.conf.Timeout)
+	if err != nil {
+		return err
+	}
+
+	start := common.BackfillStart(head, p.syncedBlock, e.conf.BackfillBlocks)
+	if start <= head {
+		logs, err := filterLogs(e.backend, e.conf.Timeout, []ecommon.Address{p.pairAddress},
+			[]ecommon.Hash{e.swapEventID, e.syncEventID}, start, head)
+		if err != nil {
+			return err
+		}
+
+		// the reserves before the swap history are unknown on start, thus the price impact of the first backfilled swap
+		// is not checked.
+		if p.syncedBlock == 0 {
+			p.token0Reserves, p.token1Reserves = new(big.Int), new(big.Int)
+		}
+
+		for _, pairLog := range logs {
+			if err = e.handlePairLog(pairLog); err != nil {
+				e.logger.Error("handle backfilled pair event failed", "symbol", p.symbol, "error", err)
+			}
+		}
+		e.logger.Info("backfilled swap history", "symbol", p.symbol, "from", start, "to", head, "events", len(logs))
+	}
+	p.backfilledTo = head
+	if head > p.syncedBlock {
+		p.syncedBlock = head
+	}
+
+	// the reserves are re-synced at the head block, as the swaps without Sync events are applied to the reserves.
+	reserves, err := p.pairContract.GetReserves(&bind.CallOpts{BlockNumber: new(big.Int).SetUint64(head)})
+	if err != nil {
+		return err
+	}
+	p.token0Reserves = reserves.Reserve0
+	p.token1Reserves = reserves.Reserve1
+	p.lastSync = nil
+	return nil
+}
+
+// backfill takes the swap events of the pools which were emitted while they were not subscribed, they are the ones
+// since the synced block, or the ones of the backfill blocks on start, up to the head block.
+func (e *UniswapV3Client) backfill() error {
+	head, err := headNumber(e.backend, e.conf.Timeout)
+	if err != nil {
+		return err
+	}
+
+	start := common.BackfillStart(head, e.syncedBlock, e.conf.BackfillBlocks)
+	if start <= head {
+		addresses := make([]ecommon.Address, 0, len(e.pools))
+		for address := range e.pools {
+			addresses = append(addresses, address)
+		}
+		logs, err := filterLogs(e.backend, e.conf.Timeout, addresses, []ecommon.Hash{e.swapEventID}, start, head)
+		if err != nil {
+			return err
+		}
+
+		// the pool prices before the swap history are unknown on start, thus the price impact of the first backfilled
+		// swap of a pool is not checked.
+		firstBackfill := e.syncedBlock == 0
+		if firstBackfill {
+			for _, pool := range e.pools {
+				pool.lastPrice = decimal.Decimal{}
+			}
+		}
+
+		for _, swapLog := range logs {
+			if err = e.handleSwapLog(swapLog); err != nil {
+				e.logger.Error("handle backfilled swap event failed", "pool", swapLog.Address, "error", err)
+			}
+		}
+
+		if firstBackfill {
+			for _, pool := range e.pools {
+				if pool.lastPrice.IsZero() {
+					pool.syncLastPrice()
+				}
+			}
+		}
+		e.logger.Info("backfilled swap history", "from", start, "to", head, "events", len(logs))
+	}
+	e.backfilledTo = head
+	if head > e.syncedBlock {
+		e.syncedBlock = head
+	}
+	return nil
+}
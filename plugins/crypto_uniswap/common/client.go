@@ -3,18 +3,20 @@ package common
 import (
 	"autonity-oracle/config"
 	"autonity-oracle/plugins/common"
+	"autonity-oracle/plugins/common/contracts/erc20"
 	"autonity-oracle/plugins/crypto_uniswap/contracts/factory"
 	"autonity-oracle/plugins/crypto_uniswap/contracts/pair"
 	"autonity-oracle/types"
+	"context"
 	"fmt"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ecommon "github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/event"
 	"github.com/hashicorp/go-hclog"
 	"github.com/shopspring/decimal"
 	ring "github.com/zfjagann/golang-ring"
-	"math"
 	"math/big"
 	"os"
 	"sync"
@@ -31,45 +33,82 @@ var (
 )
 
 type Order struct {
-	cryptoToUsdcPrice decimal.Decimal // ATN-USDCx or NTN-USDCx ratio.
-	volume            *big.Int        // trade volume in usdc of per swap event.
+	cryptoToUsdcPrice decimal.Decimal // the exchange ratio of the base token to the quote token, i.e. ATN-USDCx or NTN-USDCx ratio.
+	volume            *big.Int        // trade volume in the quote token of per swap event.
 }
 
+// ChainBackend is the L1 node backend of the clients, it is dialed from the plugin config, or simulated in tests.
+type ChainBackend interface {
+	bind.ContractBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*tp.Header, error)
+}
+
+// WrappedPair is a pair contract of a configured token pair, its swaps are aggregated into its own order book.
 type WrappedPair struct {
+	symbol         string
 	pairContract   *pair.Pair
 	pairAddress    ecommon.Address
+	baseToken      ecommon.Address
+	quoteToken     ecommon.Address
+	baseDecimals   uint8
+	quoteDecimals  uint8
 	token0         ecommon.Address
 	token1         ecommon.Address
 	token0Reserves *big.Int
 	token1Reserves *big.Int
+	lastSync       *syncedReserves
+	syncedBlock    uint64 // the last block of which the events of the pair are taken.
+	backfilledTo   uint64 // the head block of the last backfill, the events up to it are taken by the backfill.
+	leg            bool   // the pair is bound as a leg of the routes only, its price is not reported by itself.
+
+	subPairEvent ethereum.Subscription
+	lostSync     bool
+	orderBooks   ring.Ring
 }
 
-type UniswapClient struct {
-	conf   *config.PluginConfig
-	client *ethclient.Client
-	logger hclog.Logger
-
-	atnTokenAddress  ecommon.Address
-	usdxTokenAddress ecommon.Address
-
-	atnUSDCPairContract *WrappedPair
-	ntnUSDCPairContract *WrappedPair
-
-	chAtnSwapEvent  chan *pair.PairSwap
-	subAtnSwapEvent event.Subscription
-
-	chNtnSwapEvent  chan *pair.PairSwap
-	subNtnSwapEvent event.Subscription
+// syncedReserves is the last Sync event of a pair with the reserves before it, the Sync event carries the authoritative
+// reserves, and it is emitted right before the Swap event in a swap transaction.
+type syncedReserves struct {
+	txHash         ecommon.Hash
+	logIndex       uint
+	reserve0Before *big.Int
+	reserve1Before *big.Int
+}
 
-	doneCh   chan struct{}
-	ticker   *time.Ticker
-	lostSync bool
+// pairSubErr is the error of the event subscription of a pair.
+type pairSubErr struct {
+	pair *WrappedPair
+	err  error
+}
 
-	atnOrderBooks ring.Ring
-	ntnOrderBooks ring.Ring
+type UniswapClient struct {
+	conf    *config.PluginConfig
+	backend ChainBackend
+	closer  func()
+	logger  hclog.Logger
+
+	pairs          []*WrappedPair
+	pairsByAddress map[ecommon.Address]*WrappedPair
+	symbols        []*pricedSymbol
+	usdcToken      ecommon.Address
+	guards         *liquidityGuards
+
+	// the Swap and Sync events of all the pairs are delivered in order into one channel, and dispatched by the pair
+	// address.
+	swapEventID ecommon.Hash
+	syncEventID ecommon.Hash
+	chPairLog   chan tp.Log
+	chSubErr    chan pairSubErr
+
+	doneCh chan struct{}
+	ticker *time.Ticker
+
+	// the time weighted average prices are computed from the snapshots of the pairs in the twap pricing mode.
+	twap           *twapOracle
+	snapshotTicker *time.Ticker
 
 	priceMutex           sync.RWMutex
-	lastAggregatedPrices map[ecommon.Address]common.Price
+	lastAggregatedPrices map[string]common.Price
 }
 
 func NewUniswapClient(conf *config.PluginConfig) (*UniswapClient, error) {
@@ -86,124 +125,218 @@ func NewUniswapClient(conf *config.PluginConfig) (*UniswapClient, error) {
 		return nil, err
 	}
 
-	// bind uniswap factory contract, it manages the pair contracts in the AMM.
-	factoryContract, err := factory.NewFactory(ecommon.HexToAddress(conf.SwapAddress), client)
+	uc, err := newUniswapClient(conf, client, client.Close, logger)
 	if err != nil {
-		logger.Error("cannot bind uniswap factory contract", "error", err)
+		client.Close()
 		return nil, err
 	}
+	return uc, nil
+}
 
-	atnTokenAddress := ecommon.HexToAddress(conf.ATNTokenAddress)
-	usdcTokenAddress := ecommon.HexToAddress(conf.USDCTokenAddress)
-	atnUSDCPairContract, err := bindWithPairContract(factoryContract, client, atnTokenAddress, usdcTokenAddress, logger)
+func newUniswapClient(conf *config.PluginConfig, backend ChainBackend, closer func(), logger hclog.Logger) (*UniswapClient, error) {
+	// bind uniswap factory contract, it manages the pair contracts in the AMM.
+	factoryContract, err := factory.NewFactory(ecommon.HexToAddress(conf.SwapAddress), backend)
 	if err != nil {
-		logger.Error("bind with ATN USDC pair contract failed", "error", err)
+		logger.Error("cannot bind uniswap factory contract", "error", err)
 		return nil, err
 	}
 
-	ntnUSDCPairContract, err := bindWithPairContract(factoryContract, client, NTNTokenAddress, usdcTokenAddress, logger)
+	pairABI, err := pair.PairMetaData.GetAbi()
 	if err != nil {
-		logger.Error("bind with NTN USDC pair contract failed", "error", err)
 		return nil, err
 	}
 
 	uc := &UniswapClient{
 		conf:                 conf,
-		client:               client,
+		backend:              backend,
+		closer:               closer,
 		logger:               logger,
-		atnUSDCPairContract:  atnUSDCPairContract,
-		ntnUSDCPairContract:  ntnUSDCPairContract,
-		atnTokenAddress:      atnTokenAddress,
-		usdxTokenAddress:     usdcTokenAddress,
+		pairsByAddress:       make(map[ecommon.Address]*WrappedPair),
+		usdcToken:            ecommon.HexToAddress(conf.USDCTokenAddress),
+		guards:               newLiquidityGuards(conf, logger),
+		swapEventID:          pairABI.Events["Swap"].ID,
+		syncEventID:          pairABI.Events["Sync"].ID,
+		chPairLog:            make(chan tp.Log),
 		doneCh:               make(chan struct{}),
 		ticker:               time.NewTicker(time.Second * 30),
-		lastAggregatedPrices: make(map[ecommon.Address]common.Price),
+		lastAggregatedPrices: make(map[string]common.Price),
 	}
 
-	uc.atnOrderBooks.SetCapacity(orderBookCapacity)
-	uc.ntnOrderBooks.SetCapacity(orderBookCapacity)
+	routed := make(map[string]bool)
+	for _, routeConf := range conf.Routes {
+		routed[routeConf.Symbol] = true
+	}
+
+	for _, pairConf := range defaultPairs(conf) {
+		wp, err := bindWithPairContract(factoryContract, backend, pairConf, logger)
+		if err != nil {
+			// a symbol without a direct pair is priced by its routes.
+			if routed[pairConf.Symbol] {
+				logger.Warn("no direct pair, the symbol is priced by its routes", "symbol", pairConf.Symbol, "error", err)
+				uc.symbols = append(uc.symbols, &pricedSymbol{symbol: pairConf.Symbol})
+				continue
+			}
+			logger.Error("bind with pair contract failed", "symbol", pairConf.Symbol, "error", err)
+			return nil, err
+		}
+		uc.addPair(wp)
+		uc.symbols = append(uc.symbols, &pricedSymbol{symbol: wp.symbol, pair: wp})
+	}
+
+	for _, routeConf := range conf.Routes {
+		r, err := uc.bindRoute(factoryContract, routeConf)
+		if err != nil {
+			logger.Warn("cannot bind route, it is skipped", "symbol", routeConf.Symbol, "path", routeConf.Path, "error", err)
+			continue
+		}
+		s := uc.pricedSymbol(routeConf.Symbol)
+		s.routes = append(s.routes, r)
+	}
+	for _, s := range uc.symbols {
+		if s.pair == nil && len(s.routes) == 0 {
+			return nil, fmt.Errorf("neither the pair nor a route of %s is found", s.symbol)
+		}
+	}
+	// each subscription reports at most one error before it is rebuilt, thus the senders are never blocked.
+	uc.chSubErr = make(chan pairSubErr, len(uc.pairs))
+
+	if conf.PricingMode == config.PricingModeTWAP {
+		uc.twap = newTWAPOracle(conf.TWAPWindow, conf.StateDir, conf.Name, logger)
+		uc.takeSnapshots()
+		uc.snapshotTicker = time.NewTicker(uc.twap.snapshotInterval())
+	}
 
 	if err = uc.EventSubscription(); err != nil {
+		uc.unsubscribe()
 		return nil, err
 	}
 
+	// the pairs are subscribed before the backfill, thus no event is missed in between.
+	for _, p := range uc.pairs {
+		if err = uc.backfill(p); err != nil {
+			logger.Warn("cannot backfill swap history", "symbol", p.symbol, "error", err)
+		}
+	}
+
 	return uc, nil
 }
 
-func (e *UniswapClient) EventSubscription() error {
-	// subscribe on-chain swap event of atn-usdc.
-	chAtnSwapEvent := make(chan *pair.PairSwap)
-	subAtnSwapEvent, err := e.atnUSDCPairContract.pairContract.WatchSwap(new(bind.WatchOpts), chAtnSwapEvent, nil, nil)
-	if err != nil {
-		e.logger.Error("cannot watch ATN USDC pair swap event", "error", err)
-		return err
+// addPair adds a bound pair, its events are subscribed and aggregated into its own order book.
+func (e *UniswapClient) addPair(wp *WrappedPair) {
+	wp.orderBooks.SetCapacity(orderBookCapacity)
+	e.pairs = append(e.pairs, wp)
+	e.pairsByAddress[wp.pairAddress] = wp
+}
+
+// pricedSymbol returns the reported symbol, it is added if it is not reported yet.
+func (e *UniswapClient) pricedSymbol(symbol string) *pricedSymbol {
+	for _, s := range e.symbols {
+		if s.symbol == symbol {
+			return s
+		}
 	}
+	s := &pricedSymbol{symbol: symbol}
+	e.symbols = append(e.symbols, s)
+	return s
+}
 
-	chNtnSwapEvent := make(chan *pair.PairSwap)
-	subNtnSwapEvent, err := e.ntnUSDCPairContract.pairContract.WatchSwap(new(bind.WatchOpts), chNtnSwapEvent, nil, nil)
-	if err != nil {
-		e.logger.Error("cannot watch NTN USDC pair swap event", "error", err)
-		return err
+// defaultPairs returns the configured pairs, they are the ATN-USDC and NTN-USDC pairs of the token addresses if there
+// is no pair configured.
+func defaultPairs(conf *config.PluginConfig) []config.PairConfig {
+	if len(conf.Pairs) > 0 {
+		return conf.Pairs
+	}
+
+	ntnTokenAddress := NTNTokenAddress.Hex()
+	if conf.NTNTokenAddress != "" {
+		ntnTokenAddress = conf.NTNTokenAddress
 	}
-	e.chAtnSwapEvent = chAtnSwapEvent
-	e.subAtnSwapEvent = subAtnSwapEvent
+	return []config.PairConfig{
+		{Symbol: ATNUSDC, Base: conf.ATNTokenAddress, Quote: conf.USDCTokenAddress},
+		{Symbol: NTNUSDC, Base: ntnTokenAddress, Quote: conf.USDCTokenAddress},
+	}
+}
 
-	e.chNtnSwapEvent = chNtnSwapEvent
-	e.subNtnSwapEvent = subNtnSwapEvent
+// EventSubscription subscribes the Swap and Sync events of the pairs which are not subscribed yet, or whose subscriptions
+// are dropped. Both events of a pair are subscribed by one log subscription, thus a Swap is delivered after its Sync.
+func (e *UniswapClient) EventSubscription() error {
+	for _, p := range e.pairs {
+		if p.subPairEvent != nil {
+			continue
+		}
 
+		query := ethereum.FilterQuery{
+			Addresses: []ecommon.Address{p.pairAddress},
+			Topics:    [][]ecommon.Hash{{e.swapEventID, e.syncEventID}},
+		}
+		sub, err := e.backend.SubscribeFilterLogs(context.Background(), query, e.chPairLog)
+		if err != nil {
+			e.logger.Error("cannot watch pair swap and sync events", "symbol", p.symbol, "error", err)
+			return err
+		}
+		p.subPairEvent = sub
+		go e.forwardSubErr(p, sub)
+	}
 	return nil
 }
 
+// forwardSubErr forwards the error of the event subscription of a pair to the watcher, the error channel is closed
+// without an error once the subscription is unsubscribed.
+func (e *UniswapClient) forwardSubErr(p *WrappedPair, sub ethereum.Subscription) {
+	err, ok := <-sub.Err()
+	if ok && err != nil {
+		e.chSubErr <- pairSubErr{pair: p, err: err}
+	}
+}
+
 func (e *UniswapClient) StartWatcher() {
 	for {
 		select {
 		case <-e.doneCh:
 			e.ticker.Stop()
+			if e.snapshotTicker != nil {
+				e.snapshotTicker.Stop()
+			}
 			e.logger.Info("uni-swap events watcher stopped")
 			return
-		case err := <-e.subAtnSwapEvent.Err():
-			if err != nil {
-				e.logger.Info("subscription error of ATN-USDCx swap event", "error", err)
-				e.handleConnectivityError()
-				e.subAtnSwapEvent.Unsubscribe()
+		case subErr := <-e.chSubErr:
+			e.logger.Info("subscription error of pair events", "symbol", subErr.pair.symbol, "error", subErr.err)
+			e.handleConnectivityError(subErr.pair)
+		case pairLog := <-e.chPairLog:
+			if err := e.handlePairLog(pairLog); err != nil {
+				e.logger.Error("handle pair event failed", "address", pairLog.Address, "error", err)
 			}
-		case err := <-e.subNtnSwapEvent.Err():
-			if err != nil {
-				e.logger.Info("subscription error of NTN-USDCx swap event", "error", err)
-				e.handleConnectivityError()
-				e.subNtnSwapEvent.Unsubscribe()
-			}
-		case atnSwapEvent := <-e.chAtnSwapEvent:
-			e.logger.Debug("receiving an ATN-USDC swap event", "event", atnSwapEvent)
-
-			if err := e.handleSwapEvent(e.atnTokenAddress, e.atnUSDCPairContract, atnSwapEvent, &e.atnOrderBooks); err != nil {
-				e.logger.Error("handle swap event failed", "error", err)
-			}
-		case ntnSwapEvent := <-e.chNtnSwapEvent:
-			e.logger.Debug("receiving a NTN-USDC swap event", "event", ntnSwapEvent)
-			if err := e.handleSwapEvent(NTNTokenAddress, e.ntnUSDCPairContract, ntnSwapEvent, &e.ntnOrderBooks); err != nil {
-				e.logger.Error("handle swap event failed", "error", err)
-			}
-
+		case <-e.snapshotCh():
+			e.takeSnapshots()
 		case <-e.ticker.C:
 			e.checkHealth()
 		}
 	}
 }
 
-func bindWithPairContract(factoryContract *factory.Factory, client *ethclient.Client, tokenAddress1, tokenAddress2 ecommon.Address, logger hclog.Logger) (*WrappedPair, error) {
-	pairAddress, err := factoryContract.GetPair(nil, tokenAddress1, tokenAddress2)
+// snapshotCh returns the channel of the snapshot ticker, it is nil out of the twap pricing mode.
+func (e *UniswapClient) snapshotCh() <-chan time.Time {
+	if e.snapshotTicker == nil {
+		return nil
+	}
+	return e.snapshotTicker.C
+}
+
+func bindWithPairContract(factoryContract *factory.Factory, backend ChainBackend, pairConf config.PairConfig, logger hclog.Logger) (*WrappedPair, error) {
+	baseToken := ecommon.HexToAddress(pairConf.Base)
+	quoteToken := ecommon.HexToAddress(pairConf.Quote)
+	pairAddress, err := factoryContract.GetPair(nil, baseToken, quoteToken)
 	if err != nil {
-		logger.Error("cannot find pair contract from uniswap factory contract", "error", err, "token1", tokenAddress1, "token2", tokenAddress2)
+		logger.Error("cannot find pair contract from uniswap factory contract", "error", err, "token1", baseToken, "token2", quoteToken)
 		return nil, err
 	}
 
 	if pairAddress == (ecommon.Address{}) {
-		logger.Error("cannot find pair contract from uniswap factory contract", "error", err, "token1", tokenAddress1, "token2", tokenAddress2)
-		return nil, fmt.Errorf("pair contract from uniswap factory not found, pair: %s, %s", tokenAddress1, tokenAddress2)
+		logger.Error("cannot find pair contract from uniswap factory contract", "error", err, "token1", baseToken, "token2", quoteToken)
+		return nil, fmt.Errorf("pair contract from uniswap factory not found, pair: %s, %s", baseToken, quoteToken)
 	}
 
-	pairContract, err := pair.NewPair(pairAddress, client)
+	pairContract, err := pair.NewPair(pairAddress, backend)
 	if err != nil {
 		logger.Error("cannot bind pair contract", "error", err, "address", pairAddress)
 		return nil, err
@@ -221,6 +354,18 @@ func bindWithPairContract(factoryContract *factory.Factory, client *ethclient.Cl
 		return nil, err
 	}
 
+	baseDecimals, err := tokenDecimals(backend, baseToken, pairConf.BaseDecimals)
+	if err != nil {
+		logger.Error("cannot resolve decimals of base token", "error", err, "token", baseToken)
+		return nil, err
+	}
+
+	quoteDecimals, err := tokenDecimals(backend, quoteToken, pairConf.QuoteDecimals)
+	if err != nil {
+		logger.Error("cannot resolve decimals of quote token", "error", err, "token", quoteToken)
+		return nil, err
+	}
+
 	reserves, err := pairContract.GetReserves(nil)
 	if err != nil {
 		logger.Error("cannot resolve reserves from liquidity pool", "error", err)
@@ -228,8 +373,13 @@ func bindWithPairContract(factoryContract *factory.Factory, client *ethclient.Cl
 	}
 
 	return &WrappedPair{
+		symbol:         pairConf.Symbol,
 		pairContract:   pairContract,
 		pairAddress:    pairAddress,
+		baseToken:      baseToken,
+		quoteToken:     quoteToken,
+		baseDecimals:   baseDecimals,
+		quoteDecimals:  quoteDecimals,
 		token0:         token0,
 		token1:         token1,
 		token0Reserves: reserves.Reserve0,
@@ -237,94 +387,163 @@ func bindWithPairContract(factoryContract *factory.Factory, client *ethclient.Cl
 	}, nil
 }
 
-func (e *UniswapClient) handleSwapEvent(cryptoToken ecommon.Address, pair *WrappedPair, swap *pair.PairSwap, orderBook *ring.Ring) error {
-	var order Order
+// tokenDecimals returns the configured decimals of a token, or reads them from the ERC20 token contract if it is 0.
+func tokenDecimals(backend ChainBackend, token ecommon.Address, configured uint8) (uint8, error) {
+	if configured != 0 {
+		return configured, nil
+	}
 
-	if swap.Amount0Out.Cmp(common.Zero) > 0 {
-		// Subtract Amount0Out from token0Reserves
-		temp0 := new(big.Int).Set(pair.token0Reserves)
-		pair.token0Reserves.Set(temp0.Sub(temp0, swap.Amount0Out))
+	tokenContract, err := erc20.NewERC20Caller(token, backend)
+	if err != nil {
+		return 0, err
+	}
+	return tokenContract.Decimals(nil)
+}
 
-		// Add Amount1In to token1Reserves
-		temp1 := new(big.Int).Set(pair.token1Reserves)
-		pair.token1Reserves.Set(temp1.Add(temp1, swap.Amount1In))
+// reserves returns the reserves of the base token and of the quote token of the pair.
+func (p *WrappedPair) reserves(reserve0, reserve1 *big.Int) (*big.Int, *big.Int) {
+	if p.token0 == p.baseToken {
+		return reserve0, reserve1
+	}
+	return reserve1, reserve0
+}
 
-		// token0 is ATN/NTN, token1 is USDCx
-		if pair.token0 == cryptoToken {
-			cryptoReserve := new(big.Int).Set(pair.token0Reserves)
-			usdcReserve := new(big.Int).Set(pair.token1Reserves)
+// usdcReserve returns the USDC reserve of a pair and the decimals of USDC, a pair which is not paired with USDC has none.
+func (e *UniswapClient) usdcReserve(p *WrappedPair, reserve0, reserve1 *big.Int) (*big.Int, uint8, bool) {
+	baseReserve, quoteReserve := p.reserves(reserve0, reserve1)
+	switch e.usdcToken {
+	case p.quoteToken:
+		return quoteReserve, p.quoteDecimals, true
+	case p.baseToken:
+		return baseReserve, p.baseDecimals, true
+	}
+	return nil, 0, false
+}
 
-			price, err := ratio(cryptoReserve, usdcReserve)
-			if err != nil {
-				return err
-			}
-			order.cryptoToUsdcPrice = price
-			order.volume = swap.Amount1In
-		} else {
-			// token0 is USDCx, token1 is ATN/NTN
-			cryptoReserve := new(big.Int).Set(pair.token1Reserves)
-			usdcReserve := new(big.Int).Set(pair.token0Reserves)
-
-			price, err := ratio(cryptoReserve, usdcReserve)
-			if err != nil {
-				return err
-			}
-			order.cryptoToUsdcPrice = price
-			order.volume = swap.Amount0Out
-		}
+// checkReserve checks the USDC reserve of a pair with the guard, a pair which is not paired with USDC is not guarded.
+func (e *UniswapClient) checkReserve(p *WrappedPair, reserve0, reserve1 *big.Int) bool {
+	reserve, decimals, ok := e.usdcReserve(p, reserve0, reserve1)
+	if !ok {
+		return true
 	}
+	return e.guards.checkReserve(p.symbol, p.pairAddress.Hex(), reserve, decimals)
+}
 
-	if swap.Amount1Out.Cmp(common.Zero) > 0 {
-		// update reserves.
-		temp0 := new(big.Int).Set(pair.token0Reserves)
-		temp1 := new(big.Int).Set(pair.token1Reserves)
-		pair.token0Reserves.Set(temp0.Add(temp0, swap.Amount0In))
-		pair.token1Reserves.Set(temp1.Sub(temp1, swap.Amount1Out))
-
-		// token1 is ATN/NTN, token0 is USDCx
-		if pair.token1 == cryptoToken {
-			cryptoReserve := new(big.Int).Set(pair.token1Reserves)
-			usdcReserve := new(big.Int).Set(pair.token0Reserves)
-			price, err := ratio(cryptoReserve, usdcReserve)
-			if err != nil {
-				return err
-			}
-			order.cryptoToUsdcPrice = price
-			order.volume = swap.Amount0In
-		} else {
-			// token1 is USDCx, token0 is ATN/NTN
-			cryptoReserve := new(big.Int).Set(pair.token0Reserves)
-			usdcReserve := new(big.Int).Set(pair.token1Reserves)
-			price, err := ratio(cryptoReserve, usdcReserve)
-			if err != nil {
-				return err
-			}
-			order.cryptoToUsdcPrice = price
-			order.volume = swap.Amount1Out
+// handlePairLog dispatches a Swap or a Sync event to its pair.
+func (e *UniswapClient) handlePairLog(pairLog tp.Log) error {
+	// the log of a re-organised block is not taken.
+	if pairLog.Removed || len(pairLog.Topics) == 0 {
+		return nil
+	}
+
+	p, ok := e.pairsByAddress[pairLog.Address]
+	if !ok {
+		return fmt.Errorf("event of unknown pair %s", pairLog.Address)
+	}
+
+	// the events up to the backfilled block are taken by the backfill.
+	if pairLog.BlockNumber <= p.backfilledTo {
+		return nil
+	}
+	if pairLog.BlockNumber > p.syncedBlock {
+		p.syncedBlock = pairLog.BlockNumber
+	}
+
+	switch pairLog.Topics[0] {
+	case e.syncEventID:
+		sync, err := p.pairContract.ParseSync(pairLog)
+		if err != nil {
+			return err
+		}
+		e.logger.Debug("receiving a sync event", "symbol", p.symbol, "event", sync)
+		e.handleSyncEvent(p, sync)
+	case e.swapEventID:
+		swap, err := p.pairContract.ParseSwap(pairLog)
+		if err != nil {
+			return err
 		}
+		e.logger.Debug("receiving a swap event", "symbol", p.symbol, "event", swap)
+		return e.handleSwapEvent(p, swap)
 	}
+	return nil
+}
+
+// handleSyncEvent takes the reserves of a Sync event as the source of truth, they cover the swaps, the liquidity adds
+// and removals, and the fee accrual of the pair.
+func (e *UniswapClient) handleSyncEvent(p *WrappedPair, sync *pair.PairSync) {
+	p.lastSync = &syncedReserves{
+		txHash:         sync.Raw.TxHash,
+		logIndex:       sync.Raw.Index,
+		reserve0Before: p.token0Reserves,
+		reserve1Before: p.token1Reserves,
+	}
+	p.token0Reserves = sync.Reserve0
+	p.token1Reserves = sync.Reserve1
+}
 
-	aggPrice, volumes, err := aggregatePrice(orderBook, order)
+func (e *UniswapClient) handleSwapEvent(p *WrappedPair, swap *pair.PairSwap) error {
+	reserve0Before, reserve1Before := p.token0Reserves, p.token1Reserves
+	if last := p.lastSync; last != nil && last.txHash == swap.Raw.TxHash && last.logIndex < swap.Raw.Index {
+		// the reserves are synced by the Sync event of the swap in the same transaction.
+		reserve0Before, reserve1Before = last.reserve0Before, last.reserve1Before
+		p.lastSync = nil
+	} else {
+		// without its Sync event, the swap amounts are applied to the reserves.
+		e.logger.Debug("no sync event of the swap, applying swap amounts", "symbol", p.symbol, "tx", swap.Raw.TxHash)
+		reserve0 := new(big.Int).Add(p.token0Reserves, swap.Amount0In)
+		p.token0Reserves = reserve0.Sub(reserve0, swap.Amount0Out)
+		reserve1 := new(big.Int).Add(p.token1Reserves, swap.Amount1In)
+		p.token1Reserves = reserve1.Sub(reserve1, swap.Amount1Out)
+	}
+
+	// the pool price before the swap, it is zero if there was no reserve, then the price impact is not checked.
+	baseReserve, quoteReserve := p.reserves(reserve0Before, reserve1Before)
+	before, _ := ratio(baseReserve, quoteReserve, p.baseDecimals, p.quoteDecimals)
+
+	// the volume is the amount of the quote token swapped in or out of the pool.
+	quoteIn, quoteOut := swap.Amount1In, swap.Amount1Out
+	if p.token0 != p.baseToken {
+		quoteIn, quoteOut = swap.Amount0In, swap.Amount0Out
+	}
+	volume := quoteIn
+	if volume.Cmp(common.Zero) == 0 {
+		volume = quoteOut
+	}
+	if volume.Cmp(common.Zero) == 0 {
+		return fmt.Errorf("swap event without quote token amount")
+	}
+
+	baseReserve, quoteReserve = p.reserves(p.token0Reserves, p.token1Reserves)
+	price, err := ratio(baseReserve, quoteReserve, p.baseDecimals, p.quoteDecimals)
+	if err != nil {
+		return err
+	}
+
+	// the orders of a thin pool, or of an outsized swap are excluded from the order book.
+	order := Order{cryptoToUsdcPrice: price, volume: volume}
+	venue := p.pairAddress.Hex()
+	if !e.checkReserve(p, p.token0Reserves, p.token1Reserves) ||
+		!e.guards.checkPriceImpact(p.symbol, venue, before, price) ||
+		!e.guards.checkOrderShare(p.symbol, venue, &p.orderBooks, order) {
+		return nil
+	}
+
+	aggPrice, volumes, err := aggregatePrice(&p.orderBooks, order)
 	if err != nil {
-		e.logger.Error("aggregate atn-usdcx order book price failed", "error", err)
+		e.logger.Error("aggregate order book price failed", "symbol", p.symbol, "error", err)
 		return err
 	}
 
 	// update the last aggregated price.
-	e.updatePrice(cryptoToken, aggPrice.String(), volumes)
+	e.updatePrice(p.symbol, aggPrice.String(), volumes)
 	return nil
 }
 
-func (e *UniswapClient) updatePrice(tokenAddress ecommon.Address, price string, volumes *big.Int) {
+func (e *UniswapClient) updatePrice(symbol string, price string, volumes *big.Int) {
 	e.priceMutex.Lock()
 	defer e.priceMutex.Unlock()
 
-	symbol := ATNUSDC
-	if tokenAddress == NTNTokenAddress {
-		symbol = NTNUSDC
-	}
-
-	e.lastAggregatedPrices[tokenAddress] = common.Price{
+	e.lastAggregatedPrices[symbol] = common.Price{
 		Symbol: symbol,
 		Price:  price,
 		Volume: volumes.String(),
@@ -374,48 +593,46 @@ func volumeWeightedPrice(orders []interface{}) (decimal.Decimal, *big.Int, error
 }
 
 func (e *UniswapClient) checkHealth() {
-	if e.lostSync {
-		err := e.EventSubscription()
-		if err != nil {
-			e.logger.Info("rebuilding WS connectivity with L1 node", "error", err)
-			return
+	var lostSync []*WrappedPair
+	for _, p := range e.pairs {
+		if p.lostSync {
+			lostSync = append(lostSync, p)
 		}
+	}
 
-		// re-sync reserves from pools.
-		atnUsdcReserves, err := e.atnUSDCPairContract.pairContract.GetReserves(nil)
-		if err != nil {
-			e.logger.Error("re-sync atn-usdcx pair contract", "error", err)
-			return
-		}
-		e.atnUSDCPairContract.token0Reserves = atnUsdcReserves.Reserve0
-		e.atnUSDCPairContract.token1Reserves = atnUsdcReserves.Reserve1
-
-		ntnUsdcReservers, err := e.ntnUSDCPairContract.pairContract.GetReserves(nil)
-		if err != nil {
-			e.logger.Error("re-sync ntn-usdcx pair contract", "error", err)
-			return
-		}
-		e.ntnUSDCPairContract.token0Reserves = ntnUsdcReservers.Reserve0
-		e.ntnUSDCPairContract.token1Reserves = ntnUsdcReservers.Reserve1
+	if len(lostSync) == 0 {
+		e.logger.Debug("checking heart beat", "alive", true)
+		return
+	}
 
-		e.lostSync = false
+	if err := e.EventSubscription(); err != nil {
+		e.logger.Info("rebuilding WS connectivity with L1 node", "error", err)
 		return
 	}
 
-	e.logger.Debug("checking heart beat", "alive", !e.lostSync)
+	// backfill the events lost in between, and re-sync reserves from pools.
+	for _, p := range lostSync {
+		if err := e.backfill(p); err != nil {
+			e.logger.Error("re-sync pair contract", "symbol", p.symbol, "error", err)
+			continue
+		}
+		p.lostSync = false
+	}
 }
 
-func (e *UniswapClient) handleConnectivityError() {
-	e.lostSync = true
+func (e *UniswapClient) handleConnectivityError(p *WrappedPair) {
+	p.lostSync = true
+	p.subPairEvent.Unsubscribe()
+	p.subPairEvent = nil
 }
 
 func (e *UniswapClient) KeyRequired() bool {
 	return false
 }
 
-func (e *UniswapClient) fetchPrice(pair *WrappedPair, symbol string) (common.Price, error) {
+func (e *UniswapClient) fetchPrice(p *WrappedPair) (common.Price, error) {
 	var price common.Price
-	reserves, err := pair.pairContract.GetReserves(nil)
+	reserves, err := p.pairContract.GetReserves(nil)
 	if err != nil {
 		e.logger.Error("cannot get reserves from uni-swap liquidity pool", "error", err)
 		return price, err
@@ -426,119 +643,147 @@ func (e *UniswapClient) fetchPrice(pair *WrappedPair, symbol string) (common.Pri
 		return price, fmt.Errorf("nil reserves get from liquidity pool")
 	}
 
-	var cryptoReserve *big.Int
-	var usdcReserve *big.Int
-
-	if pair.token0 == ecommon.HexToAddress(e.conf.ATNTokenAddress) || pair.token0 == NTNTokenAddress {
-		// ATN or NTN is token0, compute ATN-USDC or NTN-USDC ratio with reserves0 and reserves1.
-		cryptoReserve = reserves.Reserve0
-		usdcReserve = reserves.Reserve1
-	} else {
-		// ATN or NTN is token1, compute ATN-USDC or NTN-USDC ratio with reserves0 and reserves1.
-		cryptoReserve = reserves.Reserve1
-		usdcReserve = reserves.Reserve0
+	if !e.checkReserve(p, reserves.Reserve0, reserves.Reserve1) {
+		return price, fmt.Errorf("the reserve of the pool of %s is below the guard", p.symbol)
 	}
 
-	p, err := ratio(cryptoReserve, usdcReserve)
+	baseReserve, quoteReserve := p.reserves(reserves.Reserve0, reserves.Reserve1)
+	r, err := ratio(baseReserve, quoteReserve, p.baseDecimals, p.quoteDecimals)
 	if err != nil {
-		e.logger.Error("cannot compute exchange ratio of ATN-USDC", "error", err)
+		e.logger.Error("cannot compute exchange ratio", "symbol", p.symbol, "error", err)
 		return price, err
 	}
 
-	price.Symbol = symbol
-	price.Price = p.String()
+	price.Symbol = p.symbol
+	price.Price = r.String()
 	price.Volume = types.DefaultVolume.String()
 	return price, nil
 }
 
-func (e *UniswapClient) FetchPrice(_ []string) (common.Prices, error) {
-	var prices common.Prices
-
-	atnUSDCPrice, err := e.lastAggregatedPrice(e.atnTokenAddress)
-	if err == nil {
-		prices = append(prices, atnUSDCPrice)
-	} else {
-		e.logger.Debug("no aggregated ATN-USDCx price yet, going to fetch from pool", "error", err)
-		// no swap event accumulated, compute price from current pool reserves.
-		price, er := e.fetchPrice(e.atnUSDCPairContract, ATNUSDC)
-		if er == nil {
-			prices = append(prices, price)
-		} else {
-			e.logger.Error("failed to fetch ATN-USDC price", "error", er)
+// pairPrice returns the price of a pair, and tells if it is aggregated from the swaps of the pair.
+func (e *UniswapClient) pairPrice(p *WrappedPair) (common.Price, bool, error) {
+	// the spot price is not taken in the twap pricing mode, as it is the one which can be moved by a single swap.
+	if e.twap != nil {
+		price, err := e.fetchTWAPPrice(p)
+		if err != nil {
+			e.logger.Info("failed to fetch TWAP price", "symbol", p.symbol, "error", err)
 		}
+		return price, false, err
 	}
 
-	ntnUSDCPrice, err := e.lastAggregatedPrice(NTNTokenAddress)
+	price, err := e.lastAggregatedPrice(p.symbol)
 	if err == nil {
-		prices = append(prices, ntnUSDCPrice)
-	} else {
-		e.logger.Debug("no aggregated NTN-USDCx price yet, going to fetch from pool", "error", err)
-		// no swap event accumulated, compute price from current pool reserves.
-		price, er := e.fetchPrice(e.ntnUSDCPairContract, NTNUSDC)
-		if er == nil {
-			prices = append(prices, price)
-		} else {
-			e.logger.Error("failed to fetch NTN-USDC price", "error", er)
+		return price, true, nil
+	}
+
+	e.logger.Debug("no aggregated price yet, going to fetch from pool", "symbol", p.symbol, "error", err)
+	// no swap event accumulated, compute price from current pool reserves.
+	price, err = e.fetchPrice(p)
+	if err != nil {
+		e.logger.Error("failed to fetch price", "symbol", p.symbol, "error", err)
+	}
+	return price, false, err
+}
+
+func (e *UniswapClient) FetchPrice(_ []string) (common.Prices, error) {
+	var prices common.Prices
+	bySymbol := make(map[string]common.Price)
+	for _, s := range e.symbols {
+		price, err := e.symbolPrice(s)
+		if err != nil {
+			continue
 		}
+		prices = append(prices, price)
+		bySymbol[s.symbol] = price
+	}
+
+	if !e.derivesNTNATN() {
+		return prices, nil
 	}
 
-	if len(prices) == 2 {
-		ntnATNPrice, err := common.ComputeDerivedPrice(prices[1].Price, prices[0].Price)
+	atnUSDCPrice, okATN := bySymbol[ATNUSDC]
+	ntnUSDCPrice, okNTN := bySymbol[NTNUSDC]
+	if okATN && okNTN {
+		ntnATNPrice, err := common.ComputeDerivedPrice(ntnUSDCPrice.Price, atnUSDCPrice.Price)
 		if err != nil {
 			e.logger.Error("failed to compute NTN-ATN price", "error", err)
 			return prices, nil
 		}
-		ntnATNPrice.Volume = prices[0].Volume
+		ntnATNPrice.Volume = atnUSDCPrice.Volume
 		prices = append(prices, ntnATNPrice)
 	}
 
 	return prices, nil
 }
 
-func (e *UniswapClient) lastAggregatedPrice(tokenAddress ecommon.Address) (common.Price, error) {
+// derivesNTNATN tells if the NTN-ATN price is derived from the ATN-USDC and NTN-USDC prices, it is not if there is no
+// such symbols, or if the NTN-ATN symbol is configured by itself.
+func (e *UniswapClient) derivesNTNATN() bool {
+	symbols := make(map[string]bool)
+	for _, s := range e.symbols {
+		symbols[s.symbol] = true
+	}
+	return symbols[ATNUSDC] && symbols[NTNUSDC] && !symbols[common.NTNATNSymbol]
+}
+
+func (e *UniswapClient) lastAggregatedPrice(symbol string) (common.Price, error) {
 	e.priceMutex.RLock()
 	defer e.priceMutex.RUnlock()
 
-	var price common.Price
-	latestPrice, ok := e.lastAggregatedPrices[tokenAddress]
+	latestPrice, ok := e.lastAggregatedPrices[symbol]
 	if !ok {
-		return price, fmt.Errorf("no available price yet for token %s", tokenAddress.Hex())
+		return common.Price{}, fmt.Errorf("no available price yet for %s", symbol)
 	}
 
 	return latestPrice, nil
 }
 
 func (e *UniswapClient) AvailableSymbols() ([]string, error) {
-	return supportedSymbols, nil
+	symbols := make([]string, 0, len(e.symbols)+1)
+	for _, s := range e.symbols {
+		symbols = append(symbols, s.symbol)
+	}
+	if e.derivesNTNATN() {
+		symbols = append(symbols, common.NTNATNSymbol)
+	}
+	return symbols, nil
+}
+
+func (e *UniswapClient) unsubscribe() {
+	for _, p := range e.pairs {
+		if p.subPairEvent != nil {
+			p.subPairEvent.Unsubscribe()
+		}
+	}
 }
 
 func (e *UniswapClient) Close() {
-	if e.client != nil {
-		e.client.Close()
+	e.unsubscribe()
+	if e.closer != nil {
+		e.closer()
 	}
-	e.subAtnSwapEvent.Unsubscribe()
-	e.subNtnSwapEvent.Unsubscribe()
 	e.doneCh <- struct{}{}
 }
 
-func ratio(cryptoReserve, usdcReserve *big.Int) (decimal.Decimal, error) {
+// ratio computes the exchange ratio of the base token to the quote token from their reserves in the raw amounts.
+func ratio(baseReserve, quoteReserve *big.Int, baseDecimals, quoteDecimals uint8) (decimal.Decimal, error) {
 	var r decimal.Decimal
 
-	if usdcReserve.Cmp(common.Zero) == 0 {
-		return r, fmt.Errorf("usdcReserve is zero, cannot compute exchange ratio")
+	if quoteReserve.Cmp(common.Zero) == 0 {
+		return r, fmt.Errorf("quote reserve is zero, cannot compute exchange ratio")
 	}
 
-	if cryptoReserve.Cmp(common.Zero) < 0 || usdcReserve.Cmp(common.Zero) < 0 {
+	if baseReserve.Cmp(common.Zero) < 0 || quoteReserve.Cmp(common.Zero) < 0 {
 		return r, fmt.Errorf("negative reserve value")
 	}
 
-	// ratio == (cryptoReserve/cryptoDecimals) / (usdcReserve/usdcDecimals)
-	//       == (cryptoReserve*usdcDecimals) / (usdcReserve*cryptoDecimals)
-	scaledCryptoReserve := new(big.Int).Mul(cryptoReserve, big.NewInt(int64(math.Pow(10, float64(common.USDCDecimals)))))
-	scaledUsdcReserve := new(big.Int).Mul(usdcReserve, big.NewInt(int64(math.Pow(10, float64(common.AutonityCryptoDecimals)))))
+	// ratio == (baseReserve/baseDecimals) / (quoteReserve/quoteDecimals)
+	//       == (baseReserve*quoteDecimals) / (quoteReserve*baseDecimals)
+	scaledBaseReserve := new(big.Int).Mul(baseReserve, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(quoteDecimals)), nil))
+	scaledQuoteReserve := new(big.Int).Mul(quoteReserve, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(baseDecimals)), nil))
 
 	// Calculate the exchange ratio as a big.Rat
-	price := new(big.Rat).SetFrac(scaledCryptoReserve, scaledUsdcReserve)
+	price := new(big.Rat).SetFrac(scaledBaseReserve, scaledQuoteReserve)
 
 	r, err := decimal.NewFromString(price.FloatString(common.CryptoToUsdcDecimals))
 	if err != nil {
@@ -2,9 +2,21 @@ package common
 
 import (
 	config2 "autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/plugins/common/contracts/erc20"
+	"autonity-oracle/plugins/common/simchain"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/factory"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/pair"
+	"autonity-oracle/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/go-hclog"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
+	"math/big"
 	"testing"
+	"time"
 )
 
 func TestNewUniswapClient(t *testing.T) {
@@ -37,3 +49,358 @@ func TestNewUniswapClient(t *testing.T) {
 	}
 	t.Log(prices)
 }
+
+var (
+	testATNPair = ecommon.HexToAddress("0xa000000000000000000000000000000000000002")
+	testNTNPair = ecommon.HexToAddress("0xe000000000000000000000000000000000000002")
+	testXTN     = ecommon.HexToAddress("0x0b00000000000000000000000000000000000001")
+	testXTNPair = ecommon.HexToAddress("0xb000000000000000000000000000000000000002")
+	testSender  = ecommon.HexToAddress("0x5000000000000000000000000000000000000001")
+)
+
+// mockEvent is an event emitted by a mock contract.
+type mockEvent struct {
+	topics []ecommon.Hash
+	data   []byte
+}
+
+// emitEvents emits the events of the contract in order by a transaction of the pending block.
+func emitEvents(sim *simchain.Chain, contract ecommon.Address, events ...mockEvent) {
+	logs := make([]tp.Log, len(events))
+	for i, ev := range events {
+		logs[i] = tp.Log{Address: contract, Topics: ev.topics, Data: ev.data}
+	}
+	sim.Emit(logs...)
+}
+
+func mockToken(t *testing.T, alloc *simchain.Alloc, token ecommon.Address, tokenABI *abi.ABI, decimals uint8) {
+	mockView(t, alloc, token, tokenABI, "decimals", nil, decimals)
+}
+
+func mockPair(t *testing.T, alloc *simchain.Alloc, pair ecommon.Address, pairABI *abi.ABI, token0, token1 ecommon.Address, reserve0, reserve1 *big.Int) {
+	mockView(t, alloc, pair, pairABI, "token0", nil, token0)
+	mockView(t, alloc, pair, pairABI, "token1", nil, token1)
+	mockView(t, alloc, pair, pairABI, "getReserves", nil, reserve0, reserve1, uint32(0))
+	mockView(t, alloc, pair, pairABI, "price0CumulativeLast", nil, new(big.Int))
+	mockView(t, alloc, pair, pairABI, "price1CumulativeLast", nil, new(big.Int))
+}
+
+func TestUniswapClientPairs(t *testing.T) {
+	factoryABI, err := factory.FactoryMetaData.GetAbi()
+	require.NoError(t, err)
+	pairABI, err := pair.PairMetaData.GetAbi()
+	require.NoError(t, err)
+	tokenABI, err := erc20.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	swapEvent := func(amount0In, amount1In, amount0Out, amount1Out *big.Int) mockEvent {
+		data, err := pairABI.Events["Swap"].Inputs.NonIndexed().Pack(amount0In, amount1In, amount0Out, amount1Out)
+		require.NoError(t, err)
+		return mockEvent{topics: []ecommon.Hash{pairABI.Events["Swap"].ID, ecommon.BytesToHash(testSender.Bytes()),
+			ecommon.BytesToHash(testSender.Bytes())}, data: data}
+	}
+	syncEvent := func(reserve0, reserve1 *big.Int) mockEvent {
+		data, err := pairABI.Events["Sync"].Inputs.NonIndexed().Pack(reserve0, reserve1)
+		require.NoError(t, err)
+		return mockEvent{topics: []ecommon.Hash{pairABI.Events["Sync"].ID}, data: data}
+	}
+	// mockPairs stubs the factory, the tokens and the pairs of the tests.
+	mockPairs := func(t *testing.T, alloc *simchain.Alloc) {
+		mockView(t, alloc, testFactory, factoryABI, "getPair", []interface{}{testATN, testUSDC}, testATNPair)
+		mockView(t, alloc, testFactory, factoryABI, "getPair", []interface{}{testNTN, testUSDC}, testNTNPair)
+		// XTN is traded against ATN only.
+		mockView(t, alloc, testFactory, factoryABI, "getPair", []interface{}{testXTN, testATN}, testXTNPair)
+		mockView(t, alloc, testFactory, factoryABI, "getPair", []interface{}{testATN, testXTN}, testXTNPair)
+
+		mockToken(t, alloc, testATN, tokenABI, 18)
+		mockToken(t, alloc, testNTN, tokenABI, 18)
+		mockToken(t, alloc, testUSDC, tokenABI, 6)
+		mockToken(t, alloc, testXTN, tokenABI, 18)
+		// 4000 ATN and 1000 USDC, ATN is token0.
+		mockPair(t, alloc, testATNPair, pairABI, testATN, testUSDC, new(big.Int).Mul(big.NewInt(4000), e18), big.NewInt(1000e6))
+		// 1000 USDC and 16000 NTN, NTN is token1.
+		mockPair(t, alloc, testNTNPair, pairABI, testUSDC, testNTN, big.NewInt(1000e6), new(big.Int).Mul(big.NewInt(16000), e18))
+		// 1000 XTN and 2000 ATN, XTN is token0.
+		mockPair(t, alloc, testXTNPair, pairABI, testXTN, testATN, new(big.Int).Mul(big.NewInt(1000), e18), new(big.Int).Mul(big.NewInt(2000), e18))
+	}
+	// newChain creates the simulated chain of the pairs of a test.
+	newChain := func(t *testing.T) *simchain.Chain {
+		alloc := simchain.NewAlloc()
+		mockPairs(t, alloc)
+		sim := simchain.New(t, alloc)
+		t.Cleanup(func() { sim.Close() })
+		return sim
+	}
+
+	conf := &config2.PluginConfig{
+		Name:             "crypto_uniswap",
+		Timeout:          10,
+		ATNTokenAddress:  testATN.Hex(),
+		NTNTokenAddress:  testNTN.Hex(),
+		USDCTokenAddress: testUSDC.Hex(),
+		SwapAddress:      testFactory.Hex(),
+	}
+
+	t.Run("default pairs with decimals from token contracts", func(t *testing.T) {
+		sim := newChain(t)
+		client, err := newUniswapClient(conf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		require.Len(t, client.pairs, 2)
+		require.Equal(t, uint8(18), client.pairs[0].baseDecimals)
+		require.Equal(t, uint8(6), client.pairs[0].quoteDecimals)
+
+		go client.StartWatcher()
+		defer client.Close()
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, supportedSymbols, symbols)
+
+		// without swaps, the prices are from the pool reserves.
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, ATNUSDC, prices[0].Symbol)
+		require.Equal(t, "4", prices[0].Price)
+		require.Equal(t, NTNUSDC, prices[1].Symbol)
+		require.Equal(t, "16", prices[1].Price)
+		require.Equal(t, common.NTNATNSymbol, prices[2].Symbol)
+		require.Equal(t, "4", prices[2].Price)
+
+		// the liquidity of the ATN-USDC pair is doubled, there is no swap but a Sync event.
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(8000), e18), big.NewInt(2000e6)))
+		// 1000 ATN in and 200 USDC out of the ATN-USDC pair, the swap is matched with its Sync event.
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(9000), e18), big.NewInt(1800e6)),
+			swapEvent(new(big.Int).Mul(big.NewInt(1000), e18), big.NewInt(0), big.NewInt(0), big.NewInt(200e6)))
+		sim.Commit()
+
+		require.Eventually(t, func() bool {
+			price, err := client.lastAggregatedPrice(ATNUSDC)
+			return err == nil && price.Volume == "200000000"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		prices, err = client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, "5", prices[0].Price)
+		require.Equal(t, "16", prices[1].Price)
+		require.Equal(t, "3.2", prices[2].Price)
+	})
+
+	t.Run("swap history is backfilled on start and on re-subscription", func(t *testing.T) {
+		sim := newChain(t)
+		// 1000 ATN in and 200 USDC out of the ATN-USDC pair, the swap is taken from the history by a new client.
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(9000), e18), big.NewInt(1800e6)),
+			swapEvent(new(big.Int).Mul(big.NewInt(1000), e18), big.NewInt(0), big.NewInt(0), big.NewInt(200e6)))
+		sim.Commit()
+
+		client, err := newUniswapClient(conf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		defer client.unsubscribe()
+
+		price, err := client.lastAggregatedPrice(ATNUSDC)
+		require.NoError(t, err)
+		require.Equal(t, "5", price.Price)
+		require.Equal(t, "200000000", price.Volume)
+		_, err = client.lastAggregatedPrice(NTNUSDC)
+		require.Error(t, err)
+
+		// 400 ATN in and 100 USDC out of the ATN-USDC pair while the subscription is dropped.
+		atnPair := client.pairs[0]
+		client.handleConnectivityError(atnPair)
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(4400), e18), big.NewInt(900e6)),
+			swapEvent(new(big.Int).Mul(big.NewInt(400), e18), big.NewInt(0), big.NewInt(0), big.NewInt(100e6)))
+		sim.Commit()
+
+		// the lost swap is backfilled once, the earlier ones are not taken again.
+		client.checkHealth()
+		require.False(t, atnPair.lostSync)
+		require.NotNil(t, atnPair.subPairEvent)
+		price, err = client.lastAggregatedPrice(ATNUSDC)
+		require.NoError(t, err)
+		require.Equal(t, "300000000", price.Volume)
+		require.Equal(t, "4.96296", decimal.RequireFromString(price.Price).Round(5).String())
+
+		// the events up to the backfilled block are not taken from the subscription again.
+		logs, err := filterLogs(sim, 10, []ecommon.Address{testATNPair}, []ecommon.Hash{client.swapEventID}, 0, atnPair.backfilledTo)
+		require.NoError(t, err)
+		require.NotEmpty(t, logs)
+		require.NoError(t, client.handlePairLog(logs[len(logs)-1]))
+		price, err = client.lastAggregatedPrice(ATNUSDC)
+		require.NoError(t, err)
+		require.Equal(t, "300000000", price.Volume)
+	})
+
+	t.Run("configured pairs with decimals", func(t *testing.T) {
+		sim := newChain(t)
+		pairsConf := *conf
+		pairsConf.Pairs = []config2.PairConfig{
+			{Symbol: "NTN-USDCx", Base: testNTN.Hex(), Quote: testUSDC.Hex(), BaseDecimals: 18, QuoteDecimals: 6},
+		}
+		client, err := newUniswapClient(&pairsConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+
+		go client.StartWatcher()
+		defer client.Close()
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{"NTN-USDCx"}, symbols)
+
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 1)
+		require.Equal(t, "NTN-USDCx", prices[0].Symbol)
+		require.Equal(t, "16", prices[0].Price)
+	})
+
+	t.Run("twap pricing mode", func(t *testing.T) {
+		sim := newChain(t)
+		twapConf := *conf
+		twapConf.PricingMode = config2.PricingModeTWAP
+		twapConf.TWAPWindow = 1800
+		twapConf.StateDir = t.TempDir()
+		client, err := newUniswapClient(&twapConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		require.Equal(t, 150*time.Second, client.twap.snapshotInterval())
+		go client.StartWatcher()
+
+		// the window is not covered by the snapshots yet, the spot price is not taken.
+		prices, err := client.FetchPrice(supportedSymbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 0)
+		client.Close()
+
+		// the snapshots survive the restart of the plugin.
+		sim.AdjustTime(1800 * time.Second)
+		sim.Commit()
+		client, err = newUniswapClient(&twapConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		go client.StartWatcher()
+		defer client.Close()
+
+		prices, err = client.FetchPrice(supportedSymbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, "4", prices[0].Price)
+		require.Equal(t, testATNPair.Hex(), prices[0].Venue)
+		require.Equal(t, "16", prices[1].Price)
+		require.Equal(t, "4", prices[2].Price)
+	})
+
+	t.Run("guarded pools and orders are excluded", func(t *testing.T) {
+		sim := newChain(t)
+		guardedConf := *conf
+		guardedConf.MinReserveUSD = 900
+		guardedConf.MaxPriceImpact = 0.1
+		client, err := newUniswapClient(&guardedConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		go client.StartWatcher()
+		defer client.Close()
+
+		// 250 ATN in and 50 USDC out of the ATN-USDC pair moves its price from 4 to 4.47, the impact is 11.8%.
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(4250), e18), big.NewInt(950e6)),
+			swapEvent(new(big.Int).Mul(big.NewInt(250), e18), big.NewInt(0), big.NewInt(0), big.NewInt(50e6)))
+		// 50 USDC in and 800 NTN out of the NTN-USDC pair moves its price from 16 to 14.48, the impact is 9.5%. Without
+		// its Sync event, the swap amounts are applied to the reserves.
+		emitEvents(sim, testNTNPair,
+			swapEvent(big.NewInt(50e6), big.NewInt(0), big.NewInt(0), new(big.Int).Mul(big.NewInt(800), e18)))
+		sim.Commit()
+
+		require.Eventually(t, func() bool {
+			_, err := client.lastAggregatedPrice(NTNUSDC)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		price, err := client.lastAggregatedPrice(NTNUSDC)
+		require.NoError(t, err)
+		require.Equal(t, "14.47619", decimal.RequireFromString(price.Price).Round(5).String())
+		_, err = client.lastAggregatedPrice(ATNUSDC)
+		require.Error(t, err)
+
+		// the USDC reserve of the ATN-USDC pair is 1000, it is below the guard.
+		client.guards.minReserveUSD = decimal.NewFromInt(1001)
+		_, err = client.fetchPrice(client.pairs[0])
+		require.Error(t, err)
+	})
+
+	t.Run("multi-hop routes", func(t *testing.T) {
+		sim := newChain(t)
+		routeConf := *conf
+		routeConf.Pairs = []config2.PairConfig{
+			{Symbol: ATNUSDC, Base: testATN.Hex(), Quote: testUSDC.Hex()},
+			{Symbol: "XTN-USDC", Base: testXTN.Hex(), Quote: testUSDC.Hex()},
+		}
+		routeConf.Routes = []config2.RouteConfig{
+			// there is no XTN-NTN pair, the route is skipped.
+			{Symbol: "XTN-USDC", Path: []string{testXTN.Hex(), testNTN.Hex(), testUSDC.Hex()}},
+			{Symbol: "XTN-USDC", Path: []string{testXTN.Hex(), testATN.Hex(), testUSDC.Hex()}},
+			// the XTN-ATN pair is shared with the route above in the other direction.
+			{Symbol: "ATN-XTN", Path: []string{testATN.Hex(), testXTN.Hex()}},
+		}
+		client, err := newUniswapClient(&routeConf, sim, nil, hclog.NewNullLogger())
+		require.NoError(t, err)
+		go client.StartWatcher()
+		defer client.Close()
+
+		// the ATN-USDC pair is shared with the XTN-USDC route, while the XTN-ATN pair is bound as a leg.
+		require.Len(t, client.pairs, 2)
+		require.True(t, client.pairs[1].leg)
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{ATNUSDC, "XTN-USDC", "ATN-XTN"}, symbols)
+
+		// without swaps, the legs are priced by their reserves.
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, "4", prices[0].Price)
+		require.Equal(t, "XTN-USDC", prices[1].Symbol)
+		require.Equal(t, "2", prices[1].Price)
+		require.Equal(t, types.DefaultVolume.String(), prices[1].Volume)
+		require.Equal(t, testXTNPair.Hex()+">"+testATNPair.Hex(), prices[1].Venue)
+		require.Equal(t, "ATN-XTN", prices[2].Symbol)
+		require.Equal(t, "2", prices[2].Price)
+
+		// 100 XTN in and 200 ATN out of the XTN-ATN pair, and 400 ATN in and 100 USDC out of the ATN-USDC pair.
+		emitEvents(sim, testXTNPair, syncEvent(new(big.Int).Mul(big.NewInt(1100), e18), new(big.Int).Mul(big.NewInt(1800), e18)),
+			swapEvent(new(big.Int).Mul(big.NewInt(100), e18), big.NewInt(0), big.NewInt(0), new(big.Int).Mul(big.NewInt(200), e18)))
+		emitEvents(sim, testATNPair, syncEvent(new(big.Int).Mul(big.NewInt(4400), e18), big.NewInt(900e6)),
+			swapEvent(new(big.Int).Mul(big.NewInt(400), e18), big.NewInt(0), big.NewInt(0), big.NewInt(100e6)))
+		sim.Commit()
+
+		require.Eventually(t, func() bool {
+			_, errXTN := client.lastAggregatedPrice(client.pairs[1].symbol)
+			_, errATN := client.lastAggregatedPrice(ATNUSDC)
+			return errXTN == nil && errATN == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// the ratios of the legs are 0.6111 and 4.8889, the 200 ATN of the XTN-ATN swap are 40.909090 USDC, it is the
+		// bottleneck of the route.
+		prices, err = client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		require.Equal(t, "2.987654", decimal.RequireFromString(prices[1].Price).Round(6).String())
+		require.Equal(t, "40909090", prices[1].Volume)
+		// the inverted hop of the XTN-ATN pair, the 200 ATN are 122.222222 XTN.
+		require.Equal(t, "1.636364", decimal.RequireFromString(prices[2].Price).Round(6).String())
+		require.Equal(t, "122.222222", decimal.RequireFromString(prices[2].Volume).Shift(-18).Round(6).String())
+	})
+
+	t.Run("pair not found from factory", func(t *testing.T) {
+		sim := newChain(t)
+		pairsConf := *conf
+		pairsConf.Pairs = []config2.PairConfig{{Symbol: "NTN-ATN", Base: testNTN.Hex(), Quote: testATN.Hex()}}
+		_, err := newUniswapClient(&pairsConf, sim, nil, hclog.NewNullLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestRatio(t *testing.T) {
+	r, err := ratio(big.NewInt(4e6), big.NewInt(1e8), 6, 8)
+	require.NoError(t, err)
+	require.Equal(t, "4", r.String())
+
+	_, err = ratio(big.NewInt(1), big.NewInt(0), 18, 6)
+	require.Error(t, err)
+}
@@ -0,0 +1,629 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/plugins/common/contracts/erc20"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/v3factory"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/v3pool"
+	"autonity-oracle/types"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	tp "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	ring "github.com/zfjagann/golang-ring"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	VersionV3 = "v0.1.0"
+	// FeeTiers are the fee tiers of the Uniswap V3 pools in hundredths of a bip: 0.01%, 0.05%, 0.3% and 1%.
+	FeeTiers = []int64{100, 500, 3000, 10000}
+	q192     = new(big.Int).Lsh(big.NewInt(1), 192)
+)
+
+// V3Pool is a Uniswap V3 pool of the base token and the quote token of a configured pair in one of the fee tiers.
+type V3Pool struct {
+	poolContract  *v3pool.Pool
+	poolAddress   ecommon.Address
+	fee           *big.Int
+	baseIsToken0  bool
+	baseDecimals  uint8
+	quoteDecimals uint8
+	lastPrice     decimal.Decimal // the pool price after its last swap, the price impact of a swap is from it.
+}
+
+// V3Market is the market of a configured pair, the swaps of its pools of all the fee tiers are aggregated into one
+// order book.
+type V3Market struct {
+	symbol        string
+	baseToken     ecommon.Address
+	quoteToken    ecommon.Address
+	baseDecimals  uint8
+	quoteDecimals uint8
+	pools         []*V3Pool
+	orderBooks    ring.Ring
+}
+
+type UniswapV3Client struct {
+	conf    *config.PluginConfig
+	backend ChainBackend
+	closer  func()
+	logger  hclog.Logger
+
+	markets     []*V3Market
+	poolMarkets map[ecommon.Address]*V3Market
+	pools       map[ecommon.Address]*V3Pool
+
+	usdcToken ecommon.Address
+	usdc      *erc20.ERC20Caller
+	guards    *liquidityGuards
+
+	swapEventID  ecommon.Hash
+	swapFilterer *v3pool.PoolFilterer
+	chSwapLog    chan tp.Log
+	subSwapLog   ethereum.Subscription
+
+	doneCh   chan struct{}
+	ticker   *time.Ticker
+	lostSync bool
+
+	syncedBlock  uint64 // the last block of which the swap events are taken.
+	backfilledTo uint64 // the head block of the last backfill, the swap events up to it are taken by the backfill.
+
+	priceMutex           sync.RWMutex
+	lastAggregatedPrices map[string]common.Price
+}
+
+func NewUniswapV3Client(conf *config.PluginConfig) (*UniswapV3Client, error) {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	url := conf.Scheme + "://" + conf.Endpoint
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		logger.Error("cannot dial to L1 node", "error", err)
+		return nil, err
+	}
+
+	uc, err := newUniswapV3Client(conf, client, client.Close, logger)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return uc, nil
+}
+
+func newUniswapV3Client(conf *config.PluginConfig, backend ChainBackend, closer func(), logger hclog.Logger) (*UniswapV3Client, error) {
+	if conf.SwapAddress == "" {
+		logger.Error("uniswap V3 factory contract address is not configured")
+		return nil, fmt.Errorf("uniswap V3 factory contract address is not configured")
+	}
+
+	// bind uniswap V3 factory contract, it manages the pool contracts of the fee tiers in the AMM.
+	factoryContract, err := v3factory.NewFactory(ecommon.HexToAddress(conf.SwapAddress), backend)
+	if err != nil {
+		logger.Error("cannot bind uniswap V3 factory contract", "error", err)
+		return nil, err
+	}
+
+	swapFilterer, err := v3pool.NewPoolFilterer(ecommon.Address{}, backend)
+	if err != nil {
+		logger.Error("cannot bind uniswap V3 pool filterer", "error", err)
+		return nil, err
+	}
+
+	poolABI, err := v3pool.PoolMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	usdcTokenAddress := ecommon.HexToAddress(conf.USDCTokenAddress)
+	usdc, err := erc20.NewERC20Caller(usdcTokenAddress, backend)
+	if err != nil {
+		logger.Error("cannot bind USDC token contract", "error", err)
+		return nil, err
+	}
+
+	uc := &UniswapV3Client{
+		conf:                 conf,
+		backend:              backend,
+		closer:               closer,
+		logger:               logger,
+		usdcToken:            usdcTokenAddress,
+		usdc:                 usdc,
+		guards:               newLiquidityGuards(conf, logger),
+		poolMarkets:          make(map[ecommon.Address]*V3Market),
+		pools:                make(map[ecommon.Address]*V3Pool),
+		swapEventID:          poolABI.Events["Swap"].ID,
+		swapFilterer:         swapFilterer,
+		doneCh:               make(chan struct{}),
+		ticker:               time.NewTicker(time.Second * 30),
+		lastAggregatedPrices: make(map[string]common.Price),
+	}
+
+	for _, pairConf := range defaultPairs(conf) {
+		market, err := bindWithPoolContracts(factoryContract, backend, pairConf, logger)
+		if err != nil {
+			logger.Error("bind with pool contracts failed", "symbol", pairConf.Symbol, "error", err)
+			return nil, err
+		}
+
+		market.orderBooks.SetCapacity(orderBookCapacity)
+		uc.markets = append(uc.markets, market)
+		for _, p := range market.pools {
+			uc.pools[p.poolAddress] = p
+			uc.poolMarkets[p.poolAddress] = market
+		}
+	}
+
+	if err = uc.EventSubscription(); err != nil {
+		return nil, err
+	}
+
+	// the pools are subscribed before the backfill, thus no swap is missed in between.
+	if err = uc.backfill(); err != nil {
+		logger.Warn("cannot backfill swap history", "error", err)
+	}
+
+	return uc, nil
+}
+
+// bindWithPoolContracts binds the market of a configured pair with its pools of all the fee tiers, a fee tier without a
+// pool is skipped, while there should be at least one pool of the pair.
+func bindWithPoolContracts(factoryContract *v3factory.Factory, backend ChainBackend, pairConf config.PairConfig, logger hclog.Logger) (*V3Market, error) {
+	baseToken := ecommon.HexToAddress(pairConf.Base)
+	quoteToken := ecommon.HexToAddress(pairConf.Quote)
+
+	baseDecimals, err := tokenDecimals(backend, baseToken, pairConf.BaseDecimals)
+	if err != nil {
+		logger.Error("cannot resolve decimals of base token", "error", err, "token", baseToken)
+		return nil, err
+	}
+
+	quoteDecimals, err := tokenDecimals(backend, quoteToken, pairConf.QuoteDecimals)
+	if err != nil {
+		logger.Error("cannot resolve decimals of quote token", "error", err, "token", quoteToken)
+		return nil, err
+	}
+
+	market := &V3Market{
+		symbol:        pairConf.Symbol,
+		baseToken:     baseToken,
+		quoteToken:    quoteToken,
+		baseDecimals:  baseDecimals,
+		quoteDecimals: quoteDecimals,
+	}
+	for _, tier := range FeeTiers {
+		fee := big.NewInt(tier)
+		poolAddress, err := factoryContract.GetPool(nil, baseToken, quoteToken, fee)
+		if err != nil {
+			logger.Error("cannot find pool contract from uniswap V3 factory contract", "error", err, "token1", baseToken, "token2", quoteToken, "fee", tier)
+			return nil, err
+		}
+
+		if poolAddress == (ecommon.Address{}) {
+			logger.Debug("no pool of the fee tier", "token1", baseToken, "token2", quoteToken, "fee", tier)
+			continue
+		}
+
+		poolContract, err := v3pool.NewPool(poolAddress, backend)
+		if err != nil {
+			logger.Error("cannot bind pool contract", "error", err, "address", poolAddress)
+			return nil, err
+		}
+
+		token0, err := poolContract.Token0(nil)
+		if err != nil {
+			logger.Error("cannot resolve token 0 from liquidity pool", "error", err)
+			return nil, err
+		}
+
+		pool := &V3Pool{
+			poolContract:  poolContract,
+			poolAddress:   poolAddress,
+			fee:           fee,
+			baseIsToken0:  token0 == baseToken,
+			baseDecimals:  baseDecimals,
+			quoteDecimals: quoteDecimals,
+		}
+		pool.syncLastPrice()
+		market.pools = append(market.pools, pool)
+	}
+
+	if len(market.pools) == 0 {
+		return nil, fmt.Errorf("pool contract from uniswap V3 factory not found, pair: %s, %s", baseToken, quoteToken)
+	}
+	return market, nil
+}
+
+// syncLastPrice takes the current price of the pool from its slot0, without it, the price impact of the next swap is
+// not checked.
+func (p *V3Pool) syncLastPrice() {
+	if slot0, err := p.poolContract.Slot0(nil); err == nil {
+		if price, err := p.ratio(slot0.SqrtPriceX96); err == nil {
+			p.lastPrice = price
+		}
+	}
+}
+
+// EventSubscription subscribes the swap events of all the bound pools with a single log subscription.
+func (e *UniswapV3Client) EventSubscription() error {
+	addresses := make([]ecommon.Address, 0, len(e.pools))
+	for address := range e.pools {
+		addresses = append(addresses, address)
+	}
+
+	query := ethereum.FilterQuery{Addresses: addresses, Topics: [][]ecommon.Hash{{e.swapEventID}}}
+	chSwapLog := make(chan tp.Log)
+	subSwapLog, err := e.backend.SubscribeFilterLogs(context.Background(), query, chSwapLog)
+	if err != nil {
+		e.logger.Error("cannot watch uniswap V3 pool swap events", "error", err)
+		return err
+	}
+
+	e.chSwapLog = chSwapLog
+	e.subSwapLog = subSwapLog
+	return nil
+}
+
+func (e *UniswapV3Client) StartWatcher() {
+	for {
+		select {
+		case <-e.doneCh:
+			e.ticker.Stop()
+			e.logger.Info("uni-swap V3 events watcher stopped")
+			return
+		case err := <-e.subscriptionErr():
+			if err != nil {
+				e.logger.Info("subscription error of uniswap V3 swap events", "error", err)
+				e.handleConnectivityError()
+				e.subSwapLog.Unsubscribe()
+			}
+		case swapLog := <-e.chSwapLog:
+			e.logger.Debug("receiving a uniswap V3 swap event", "pool", swapLog.Address, "block", swapLog.BlockNumber)
+			if err := e.handleSwapLog(swapLog); err != nil {
+				e.logger.Error("handle swap event failed", "error", err)
+			}
+		case <-e.ticker.C:
+			e.checkHealth()
+		}
+	}
+}
+
+// subscriptionErr returns the error channel of the swap event subscription, it is nil once the subscription is dropped
+// until it is rebuilt, as the error channel of a dropped subscription is closed.
+func (e *UniswapV3Client) subscriptionErr() <-chan error {
+	if e.lostSync {
+		return nil
+	}
+	return e.subSwapLog.Err()
+}
+
+func (e *UniswapV3Client) handleSwapLog(swapLog tp.Log) error {
+	// the log of a re-organised block is not taken.
+	if swapLog.Removed {
+		return nil
+	}
+
+	pool, ok := e.pools[swapLog.Address]
+	if !ok {
+		return fmt.Errorf("swap event of unknown pool %s", swapLog.Address)
+	}
+	market := e.poolMarkets[swapLog.Address]
+
+	// the swaps up to the backfilled block are taken by the backfill.
+	if swapLog.BlockNumber <= e.backfilledTo {
+		return nil
+	}
+	if swapLog.BlockNumber > e.syncedBlock {
+		e.syncedBlock = swapLog.BlockNumber
+	}
+
+	swap, err := e.swapFilterer.ParseSwap(swapLog)
+	if err != nil {
+		return err
+	}
+
+	// every swap moves the pool price, including the ones which are not taken.
+	before := pool.lastPrice
+	if after, err := pool.ratio(swap.SqrtPriceX96); err == nil {
+		pool.lastPrice = after
+	}
+
+	// a swap which drains the in-range liquidity moves the price to where there is no depth, it is not taken.
+	if swap.Liquidity == nil || swap.Liquidity.Cmp(common.Zero) == 0 {
+		e.logger.Debug("skip swap event without in-range liquidity", "pool", pool.poolAddress, "fee", pool.fee)
+		return nil
+	}
+
+	order, err := swapOrder(pool, swap)
+	if err != nil {
+		return err
+	}
+
+	// the orders of a thin pool, or of an outsized swap are excluded from the order book.
+	venue := pool.poolAddress.Hex()
+	if !e.checkReserve(market, pool) ||
+		!e.guards.checkPriceImpact(market.symbol, venue, before, order.cryptoToUsdcPrice) ||
+		!e.guards.checkOrderShare(market.symbol, venue, &market.orderBooks, order) {
+		return nil
+	}
+
+	aggPrice, volumes, err := aggregatePrice(&market.orderBooks, order)
+	if err != nil {
+		e.logger.Error("aggregate uniswap V3 order book price failed", "symbol", market.symbol, "error", err)
+		return err
+	}
+
+	// update the last aggregated price.
+	e.updatePrice(market.symbol, aggPrice.String(), volumes, e.blockTime(swapLog.BlockNumber), pool.poolAddress)
+	return nil
+}
+
+// checkReserve checks the USDC balance of a pool with the guard, a pool which is not paired with USDC is not guarded,
+// and a pool of which the balance cannot be resolved is excluded.
+func (e *UniswapV3Client) checkReserve(market *V3Market, pool *V3Pool) bool {
+	if !e.guards.reserveRequired() {
+		return true
+	}
+
+	var decimals uint8
+	switch e.usdcToken {
+	case market.quoteToken:
+		decimals = market.quoteDecimals
+	case market.baseToken:
+		decimals = market.baseDecimals
+	default:
+		return true
+	}
+
+	balance, err := e.usdc.BalanceOf(nil, pool.poolAddress)
+	if err != nil {
+		e.logger.Error("cannot get USDC balance of uni-swap V3 pool", "pool", pool.poolAddress, "error", err)
+		return false
+	}
+	return e.guards.checkReserve(market.symbol, pool.poolAddress.Hex(), balance, decimals)
+}
+
+// swapOrder resolves the order of a swap, its price is the pool price after the swap, and its volume is the quote token
+// amount in or out of the pool.
+func swapOrder(pool *V3Pool, swap *v3pool.PoolSwap) (Order, error) {
+	var order Order
+	price, err := pool.ratio(swap.SqrtPriceX96)
+	if err != nil {
+		return order, err
+	}
+
+	quoteAmount := swap.Amount0
+	if pool.baseIsToken0 {
+		quoteAmount = swap.Amount1
+	}
+	if quoteAmount == nil || quoteAmount.Sign() == 0 {
+		return order, fmt.Errorf("swap event without quote token amount")
+	}
+
+	order.cryptoToUsdcPrice = price
+	order.volume = new(big.Int).Abs(quoteAmount)
+	return order, nil
+}
+
+// ratio converts the sqrt price of the pool to the exchange ratio of its base token to its quote token.
+func (p *V3Pool) ratio(sqrtPriceX96 *big.Int) (decimal.Decimal, error) {
+	return poolRatio(sqrtPriceX96, p.baseIsToken0, p.baseDecimals, p.quoteDecimals)
+}
+
+// poolRatio converts the sqrt price of a pool to the exchange ratio of the base token to the quote token as ratio() does
+// for the V2 reserves. The sqrt price is the Q64.96 of sqrt(token1/token0) in the raw amounts, thus the raw price of
+// token0 in token1 is sqrtPriceX96^2 / 2^192.
+func poolRatio(sqrtPriceX96 *big.Int, baseIsToken0 bool, baseDecimals, quoteDecimals uint8) (decimal.Decimal, error) {
+	var r decimal.Decimal
+	if sqrtPriceX96 == nil || sqrtPriceX96.Sign() <= 0 {
+		return r, fmt.Errorf("invalid sqrt price of pool, cannot compute exchange ratio")
+	}
+
+	priceX192 := new(big.Int).Mul(sqrtPriceX96, sqrtPriceX96)
+	// the raw amount of base per raw amount of quote.
+	price := new(big.Rat).SetFrac(priceX192, q192)
+	if baseIsToken0 {
+		price.Inv(price)
+	}
+
+	// ratio == (base/baseDecimals) / (quote/quoteDecimals) == base/quote * quoteDecimals / baseDecimals
+	scale := new(big.Rat).SetFrac(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(quoteDecimals)), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(baseDecimals)), nil))
+	price.Mul(price, scale)
+
+	return decimal.NewFromString(price.FloatString(common.CryptoToUsdcDecimals))
+}
+
+// blockTime resolves the timestamp of the block of a swap event as the quote TS, it is 0 if it cannot be resolved, then
+// the fetch TS is taken.
+func (e *UniswapV3Client) blockTime(number uint64) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.conf.Timeout)*time.Second)
+	defer cancel()
+	header, err := e.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		e.logger.Debug("cannot resolve block time of swap event", "block", number, "error", err)
+		return 0
+	}
+	return int64(header.Time)
+}
+
+func (e *UniswapV3Client) updatePrice(symbol string, price string, volumes *big.Int, ts int64, pool ecommon.Address) {
+	e.priceMutex.Lock()
+	defer e.priceMutex.Unlock()
+
+	e.lastAggregatedPrices[symbol] = common.Price{
+		Symbol:    symbol,
+		Price:     price,
+		Volume:    volumes.String(),
+		Timestamp: ts,
+		Venue:     pool.Hex(),
+	}
+}
+
+func (e *UniswapV3Client) checkHealth() {
+	if e.lostSync {
+		err := e.EventSubscription()
+		if err != nil {
+			e.logger.Info("rebuilding WS connectivity with L1 node", "error", err)
+			return
+		}
+
+		e.lostSync = false
+		// backfill the swaps lost in between.
+		if err = e.backfill(); err != nil {
+			e.logger.Warn("cannot backfill swap history", "error", err)
+		}
+		return
+	}
+
+	e.logger.Debug("checking heart beat", "alive", !e.lostSync)
+}
+
+func (e *UniswapV3Client) handleConnectivityError() {
+	e.lostSync = true
+}
+
+func (e *UniswapV3Client) KeyRequired() bool {
+	return false
+}
+
+// fetchPrice computes the price of a market from the slot0 of its pool with the most in-range liquidity, it is taken
+// when there is no swap event accumulated yet.
+func (e *UniswapV3Client) fetchPrice(market *V3Market) (common.Price, error) {
+	var price common.Price
+	var deepest *V3Pool
+	maxLiquidity := new(big.Int)
+	for _, p := range market.pools {
+		liquidity, err := p.poolContract.Liquidity(nil)
+		if err != nil {
+			e.logger.Error("cannot get liquidity from uni-swap V3 pool", "pool", p.poolAddress, "error", err)
+			continue
+		}
+		if liquidity.Cmp(maxLiquidity) > 0 {
+			deepest = p
+			maxLiquidity = liquidity
+		}
+	}
+
+	if deepest == nil {
+		return price, fmt.Errorf("no in-range liquidity in the pools of %s", market.symbol)
+	}
+
+	if !e.checkReserve(market, deepest) {
+		return price, fmt.Errorf("the reserve of the pool of %s is below the guard", market.symbol)
+	}
+
+	slot0, err := deepest.poolContract.Slot0(nil)
+	if err != nil {
+		e.logger.Error("cannot get slot0 from uni-swap V3 pool", "pool", deepest.poolAddress, "error", err)
+		return price, err
+	}
+
+	p, err := deepest.ratio(slot0.SqrtPriceX96)
+	if err != nil {
+		e.logger.Error("cannot compute exchange ratio from slot0", "symbol", market.symbol, "error", err)
+		return price, err
+	}
+
+	price.Symbol = market.symbol
+	price.Price = p.String()
+	price.Volume = types.DefaultVolume.String()
+	price.Venue = deepest.poolAddress.Hex()
+	return price, nil
+}
+
+func (e *UniswapV3Client) FetchPrice(_ []string) (common.Prices, error) {
+	var prices common.Prices
+	bySymbol := make(map[string]common.Price)
+	for _, market := range e.markets {
+		price, err := e.lastAggregatedPrice(market.symbol)
+		if err == nil {
+			prices = append(prices, price)
+			bySymbol[market.symbol] = price
+			continue
+		}
+
+		e.logger.Debug("no aggregated price yet, going to fetch from pools", "symbol", market.symbol, "error", err)
+		// no swap event accumulated, compute price from current pool state.
+		price, err = e.fetchPrice(market)
+		if err != nil {
+			e.logger.Error("failed to fetch price", "symbol", market.symbol, "error", err)
+			continue
+		}
+		prices = append(prices, price)
+		bySymbol[market.symbol] = price
+	}
+
+	if !e.derivesNTNATN() {
+		return prices, nil
+	}
+
+	atnUSDCPrice, okATN := bySymbol[ATNUSDC]
+	ntnUSDCPrice, okNTN := bySymbol[NTNUSDC]
+	if okATN && okNTN {
+		ntnATNPrice, err := common.ComputeDerivedPrice(ntnUSDCPrice.Price, atnUSDCPrice.Price)
+		if err != nil {
+			e.logger.Error("failed to compute NTN-ATN price", "error", err)
+			return prices, nil
+		}
+		ntnATNPrice.Volume = atnUSDCPrice.Volume
+		prices = append(prices, ntnATNPrice)
+	}
+
+	return prices, nil
+}
+
+// derivesNTNATN tells if the NTN-ATN price is derived from the ATN-USDC and NTN-USDC prices, as the V2 client does.
+func (e *UniswapV3Client) derivesNTNATN() bool {
+	symbols := make(map[string]bool)
+	for _, m := range e.markets {
+		symbols[m.symbol] = true
+	}
+	return symbols[ATNUSDC] && symbols[NTNUSDC] && !symbols[common.NTNATNSymbol]
+}
+
+func (e *UniswapV3Client) lastAggregatedPrice(symbol string) (common.Price, error) {
+	e.priceMutex.RLock()
+	defer e.priceMutex.RUnlock()
+
+	latestPrice, ok := e.lastAggregatedPrices[symbol]
+	if !ok {
+		return common.Price{}, fmt.Errorf("no available price yet for %s", symbol)
+	}
+
+	return latestPrice, nil
+}
+
+func (e *UniswapV3Client) AvailableSymbols() ([]string, error) {
+	symbols := make([]string, 0, len(e.markets)+1)
+	for _, m := range e.markets {
+		symbols = append(symbols, m.symbol)
+	}
+	if e.derivesNTNATN() {
+		symbols = append(symbols, common.NTNATNSymbol)
+	}
+	return symbols, nil
+}
+
+func (e *UniswapV3Client) Close() {
+	if !e.lostSync {
+		e.subSwapLog.Unsubscribe()
+	}
+	if e.closer != nil {
+		e.closer()
+	}
+	e.doneCh <- struct{}{}
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common/contracts/erc20"
+	"autonity-oracle/plugins/common/simchain"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/v3factory"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/v3pool"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var (
+	testATN     = ecommon.HexToAddress("0x0a00000000000000000000000000000000000001")
+	testUSDC    = ecommon.HexToAddress("0x0c00000000000000000000000000000000000001")
+	testNTN     = ecommon.HexToAddress("0x0e00000000000000000000000000000000000001")
+	testFactory = ecommon.HexToAddress("0xf000000000000000000000000000000000000001")
+	// the ATN-USDC pools of the 0.05% and 0.3% fee tiers, and the NTN-USDC pool of the 0.3% fee tier.
+	testATNPool500  = ecommon.HexToAddress("0xa000000000000000000000000000000000000500")
+	testATNPool3000 = ecommon.HexToAddress("0xa000000000000000000000000000000000003000")
+	testNTNPool3000 = ecommon.HexToAddress("0xe000000000000000000000000000000000003000")
+)
+
+// mockView stubs the view of the method of a contract with the args to return the outputs.
+func mockView(t *testing.T, alloc *simchain.Alloc, contract ecommon.Address, contractABI *abi.ABI, method string, args []interface{}, outputs ...interface{}) {
+	require.NoError(t, alloc.SetView(contract, contractABI, method, args, outputs...))
+}
+
+// sqrtPriceOf computes the sqrt price of a pool for the exchange ratio of an 18 decimals base token to USDC.
+func sqrtPriceOf(ratio float64, baseIsToken0 bool) *big.Int {
+	raw := ratio * 1e12 // the raw amount of base per raw amount of USDC.
+	if baseIsToken0 {
+		raw = 1 / raw
+	}
+	f := new(big.Float).SetPrec(256).SetFloat64(raw)
+	f.Sqrt(f)
+	f.Mul(f, new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 96)))
+	sqrtPrice, _ := f.Int(nil)
+	return sqrtPrice
+}
+
+func mockPool(t *testing.T, alloc *simchain.Alloc, pool ecommon.Address, poolABI *abi.ABI, token0, token1 ecommon.Address, fee int64, liquidity int64, sqrtPrice *big.Int) {
+	mockView(t, alloc, pool, poolABI, "token0", nil, token0)
+	mockView(t, alloc, pool, poolABI, "token1", nil, token1)
+	mockView(t, alloc, pool, poolABI, "fee", nil, big.NewInt(fee))
+	mockView(t, alloc, pool, poolABI, "liquidity", nil, big.NewInt(liquidity))
+	mockView(t, alloc, pool, poolABI, "slot0", nil, sqrtPrice, big.NewInt(0), uint16(0), uint16(1), uint16(1), uint8(0), true)
+}
+
+func TestPoolRatio(t *testing.T) {
+	// crypto as token1: 1 NTN = 1/16 USDC, the raw price is 16e12 NTN per USDC, its sqrt price is exact.
+	r, err := poolRatio(new(big.Int).Mul(new(big.Int).Lsh(big.NewInt(1), 98), big.NewInt(1_000_000)), false, 18, 6)
+	require.NoError(t, err)
+	require.Equal(t, "16", r.String())
+
+	// crypto as token0: 1 ATN = 0.25 USDC.
+	r, err = poolRatio(sqrtPriceOf(4, true), true, 18, 6)
+	require.NoError(t, err)
+	require.Equal(t, "4", r.Round(9).String())
+
+	// the same sqrt price of a pair of the 6 decimals tokens, the raw price is taken as is.
+	r, err = poolRatio(new(big.Int).Lsh(big.NewInt(1), 98), false, 6, 6)
+	require.NoError(t, err)
+	require.Equal(t, "16", r.String())
+
+	_, err = poolRatio(new(big.Int), true, 18, 6)
+	require.Error(t, err)
+}
+
+func TestUniswapV3Client(t *testing.T) {
+	factoryABI, err := v3factory.FactoryMetaData.GetAbi()
+	require.NoError(t, err)
+	poolABI, err := v3pool.PoolMetaData.GetAbi()
+	require.NoError(t, err)
+	tokenABI, err := erc20.ERC20MetaData.GetAbi()
+	require.NoError(t, err)
+
+	alloc := simchain.NewAlloc()
+	mockView(t, alloc, testATN, tokenABI, "decimals", nil, uint8(18))
+	mockView(t, alloc, testNTN, tokenABI, "decimals", nil, uint8(18))
+	mockView(t, alloc, testUSDC, tokenABI, "decimals", nil, uint8(6))
+	pools := map[ecommon.Address]map[int64]ecommon.Address{
+		testATN: {500: testATNPool500, 3000: testATNPool3000},
+		testNTN: {3000: testNTNPool3000},
+	}
+	for token, tiers := range pools {
+		for _, fee := range FeeTiers {
+			mockView(t, alloc, testFactory, factoryABI, "getPool", []interface{}{token, testUSDC, big.NewInt(fee)}, tiers[fee])
+		}
+	}
+	// ATN is the token0 of its pools, the 0.05% pool is deeper than the 0.3% pool.
+	mockPool(t, alloc, testATNPool500, poolABI, testATN, testUSDC, 500, 1000, sqrtPriceOf(4, true))
+	mockPool(t, alloc, testATNPool3000, poolABI, testATN, testUSDC, 3000, 10, sqrtPriceOf(8, true))
+	// NTN is the token1 of its pool.
+	mockPool(t, alloc, testNTNPool3000, poolABI, testUSDC, testNTN, 3000, 1000, sqrtPriceOf(16, false))
+	sim := simchain.New(t, alloc)
+
+	conf := &config.PluginConfig{
+		Name:             "crypto_uniswap_v3",
+		Timeout:          10,
+		ATNTokenAddress:  testATN.Hex(),
+		NTNTokenAddress:  testNTN.Hex(),
+		USDCTokenAddress: testUSDC.Hex(),
+		SwapAddress:      testFactory.Hex(),
+	}
+	client, err := newUniswapV3Client(conf, sim, func() { sim.Close() }, hclog.NewNullLogger())
+	require.NoError(t, err)
+	require.Len(t, client.markets[0].pools, 2)
+	require.Len(t, client.markets[1].pools, 1)
+	require.False(t, client.markets[1].pools[0].baseIsToken0)
+	symbols, err := client.AvailableSymbols()
+	require.NoError(t, err)
+	require.Equal(t, []string{ATNUSDC, NTNUSDC, "NTN-ATN"}, symbols)
+
+	go client.StartWatcher()
+	defer client.Close()
+
+	// without swaps, the prices are from the slot0 of the deepest pools.
+	prices, err := client.FetchPrice(supportedSymbols)
+	require.NoError(t, err)
+	require.Len(t, prices, 3)
+	require.Equal(t, ATNUSDC, prices[0].Symbol)
+	require.Equal(t, "4", decimal.RequireFromString(prices[0].Price).Round(9).String())
+	require.Equal(t, testATNPool500.Hex(), prices[0].Venue)
+	require.Equal(t, "16", decimal.RequireFromString(prices[1].Price).Round(9).String())
+	require.Equal(t, "4", decimal.RequireFromString(prices[2].Price).Round(9).String())
+
+	// the swaps of the pools of both fee tiers are aggregated, the one without in-range liquidity is skipped.
+	emitSwap := func(pool ecommon.Address, amount0, amount1 int64, ratio float64, liquidity int64) {
+		data, err := poolABI.Events["Swap"].Inputs.NonIndexed().Pack(big.NewInt(amount0), big.NewInt(amount1),
+			sqrtPriceOf(ratio, true), big.NewInt(liquidity), big.NewInt(0))
+		require.NoError(t, err)
+		emitEvents(sim, pool, mockEvent{topics: []ecommon.Hash{poolABI.Events["Swap"].ID,
+			ecommon.BytesToHash(testSender.Bytes()), ecommon.BytesToHash(testSender.Bytes())}, data: data})
+	}
+	emitSwap(testATNPool500, 4e18, -1e6, 4.2, 1000)
+	emitSwap(testATNPool3000, -3e18, 3e6, 3.8, 10)
+	emitSwap(testATNPool500, 1e18, -1e6, 100, 0)
+	sim.Commit()
+
+	require.Eventually(t, func() bool {
+		price, err := client.lastAggregatedPrice(ATNUSDC)
+		return err == nil && price.Volume == "4000000"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	prices, err = client.FetchPrice(supportedSymbols)
+	require.NoError(t, err)
+	require.Len(t, prices, 3)
+	require.Equal(t, "3.9", decimal.RequireFromString(prices[0].Price).Round(6).String())
+	require.Equal(t, testATNPool3000.Hex(), prices[0].Venue)
+	require.Greater(t, prices[0].Timestamp, int64(0))
+	require.Equal(t, "16", decimal.RequireFromString(prices[1].Price).Round(9).String())
+}
+
+func TestUniswapV3ClientPairs(t *testing.T) {
+	factoryABI, err := v3factory.FactoryMetaData.GetAbi()
+	require.NoError(t, err)
+	poolABI, err := v3pool.PoolMetaData.GetAbi()
+	require.NoError(t, err)
+
+	// a configured pair of NTN to ATN without USDC, ATN is the token0 of its pool, and 1 NTN = 0.25 ATN.
+	alloc := simchain.NewAlloc()
+	pool := ecommon.HexToAddress("0xea00000000000000000000000000000000003000")
+	for _, fee := range FeeTiers {
+		var address ecommon.Address
+		if fee == 3000 {
+			address = pool
+		}
+		mockView(t, alloc, testFactory, factoryABI, "getPool", []interface{}{testNTN, testATN, big.NewInt(fee)}, address)
+	}
+	mockPool(t, alloc, pool, poolABI, testATN, testNTN, 3000, 1000, new(big.Int).Lsh(big.NewInt(1), 97))
+	sim := simchain.New(t, alloc)
+
+	conf := &config.PluginConfig{
+		Name:             "crypto_uniswap_v3",
+		Timeout:          10,
+		USDCTokenAddress: testUSDC.Hex(),
+		SwapAddress:      testFactory.Hex(),
+		MinReserveUSD:    1000,
+		Pairs: []config.PairConfig{{Symbol: "NTN-ATN", Base: testNTN.Hex(), Quote: testATN.Hex(), BaseDecimals: 18,
+			QuoteDecimals: 18}},
+	}
+	client, err := newUniswapV3Client(conf, sim, func() { sim.Close() }, hclog.NewNullLogger())
+	require.NoError(t, err)
+	go client.StartWatcher()
+	defer client.Close()
+
+	symbols, err := client.AvailableSymbols()
+	require.NoError(t, err)
+	require.Equal(t, []string{"NTN-ATN"}, symbols)
+
+	// the pool is not paired with USDC, thus its reserve is not guarded.
+	prices, err := client.FetchPrice(symbols)
+	require.NoError(t, err)
+	require.Len(t, prices, 1)
+	require.Equal(t, "NTN-ATN", prices[0].Symbol)
+	require.Equal(t, "4", decimal.RequireFromString(prices[0].Price).String())
+	require.Equal(t, pool.Hex(), prices[0].Venue)
+}
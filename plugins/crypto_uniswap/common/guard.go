@@ -0,0 +1,112 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	ring "github.com/zfjagann/golang-ring"
+	"math"
+	"math/big"
+)
+
+// minShareWindowOrders is the min number of orders in the window before the order share is checked, thus a thin
+// window, i.e. of a single dust swap, cannot reject all the normal swaps after it.
+const minShareWindowOrders = 8
+
+// liquidityGuards are the guards of the AMM data, a pool or an order which fails a guard is excluded as low quality,
+// thus a thin pool or an outsized swap cannot dominate the price. A guard of a zero limit is disabled.
+type liquidityGuards struct {
+	minReserveUSD  decimal.Decimal
+	maxPriceImpact decimal.Decimal
+	maxOrderShare  decimal.Decimal
+	logger         hclog.Logger
+}
+
+func newLiquidityGuards(conf *config.PluginConfig, logger hclog.Logger) *liquidityGuards {
+	return &liquidityGuards{
+		minReserveUSD:  decimal.NewFromFloat(conf.MinReserveUSD),
+		maxPriceImpact: decimal.NewFromFloat(conf.MaxPriceImpact),
+		maxOrderShare:  decimal.NewFromFloat(conf.MaxOrderShare),
+		logger:         logger,
+	}
+}
+
+// lowQuality reports the AMM data which is excluded by a guard.
+func (g *liquidityGuards) lowQuality(guard, symbol, venue string, value, limit decimal.Decimal) {
+	g.logger.Warn("excluded low quality AMM data", "guard", guard, "symbol", symbol, "venue", venue,
+		"value", value.String(), "limit", limit.String())
+}
+
+// reserveRequired tells if the USD reserve of the pools is guarded, thus the reserve is resolved only if it is required.
+func (g *liquidityGuards) reserveRequired() bool {
+	return g.minReserveUSD.IsPositive()
+}
+
+// checkReserve checks the USDC reserve of a pool, the USDC is taken at 1 USD.
+func (g *liquidityGuards) checkReserve(symbol, venue string, usdcReserve *big.Int, usdcDecimals uint8) bool {
+	if !g.reserveRequired() {
+		return true
+	}
+
+	reserve := decimal.NewFromBigInt(usdcReserve, -int32(usdcDecimals))
+	if reserve.LessThan(g.minReserveUSD) {
+		g.lowQuality("minReserveUSD", symbol, venue, reserve, g.minReserveUSD)
+		return false
+	}
+	return true
+}
+
+// checkPriceImpact checks the relative move of the pool price by a swap.
+func (g *liquidityGuards) checkPriceImpact(symbol, venue string, before, after decimal.Decimal) bool {
+	if !g.maxPriceImpact.IsPositive() || before.IsZero() {
+		return true
+	}
+
+	impact := after.Sub(before).Div(before).Abs()
+	if impact.GreaterThan(g.maxPriceImpact) {
+		g.lowQuality("maxPriceImpact", symbol, venue, impact, g.maxPriceImpact)
+		return false
+	}
+	return true
+}
+
+// minWindowOrders is the min number of orders in the window before the order share is checked. It is no less than the
+// number of orders of equal size that fit the max share, thus a swap of the usual size is never rejected.
+func (g *liquidityGuards) minWindowOrders() int {
+	share, _ := g.maxOrderShare.Float64()
+	equalOrders := int(math.Ceil(1/share)) - 1
+	if equalOrders > minShareWindowOrders {
+		return equalOrders
+	}
+	return minShareWindowOrders
+}
+
+// checkOrderShare checks the share of an order in the volume of the order book window with the order. The orders are
+// not checked until the window holds the min number of orders, as there is not enough volume to compare with.
+func (g *liquidityGuards) checkOrderShare(symbol, venue string, orderBook *ring.Ring, order Order) bool {
+	if !g.maxOrderShare.IsPositive() {
+		return true
+	}
+
+	orders := orderBook.Values()
+	if len(orders) < g.minWindowOrders() {
+		return true
+	}
+
+	windowVolume := new(big.Int).Set(order.volume)
+	for _, o := range orders {
+		if recent, ok := o.(Order); ok {
+			windowVolume.Add(windowVolume, recent.volume)
+		}
+	}
+	if windowVolume.Sign() == 0 {
+		return true
+	}
+
+	share := decimal.NewFromBigInt(order.volume, 0).Div(decimal.NewFromBigInt(windowVolume, 0))
+	if share.GreaterThan(g.maxOrderShare) {
+		g.lowQuality("maxOrderShare", symbol, venue, share, g.maxOrderShare)
+		return false
+	}
+	return true
+}
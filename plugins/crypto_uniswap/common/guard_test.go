@@ -0,0 +1,77 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	ring "github.com/zfjagann/golang-ring"
+	"math/big"
+	"testing"
+)
+
+func TestLiquidityGuards(t *testing.T) {
+	t.Run("guards of zero limits are disabled", func(t *testing.T) {
+		g := newLiquidityGuards(&config.PluginConfig{}, hclog.NewNullLogger())
+		var orderBook ring.Ring
+		orderBook.SetCapacity(orderBookCapacity)
+		orderBook.Enqueue(Order{cryptoToUsdcPrice: decimal.NewFromInt(4), volume: big.NewInt(1)})
+
+		require.False(t, g.reserveRequired())
+		require.True(t, g.checkReserve(ATNUSDC, "", big.NewInt(1), 6))
+		require.True(t, g.checkPriceImpact(ATNUSDC, "", decimal.NewFromInt(4), decimal.NewFromInt(400)))
+		require.True(t, g.checkOrderShare(ATNUSDC, "", &orderBook, Order{volume: big.NewInt(1000)}))
+	})
+
+	g := newLiquidityGuards(&config.PluginConfig{MinReserveUSD: 1000, MaxPriceImpact: 0.05, MaxOrderShare: 0.5}, hclog.NewNullLogger())
+
+	t.Run("min reserve in USD", func(t *testing.T) {
+		require.True(t, g.reserveRequired())
+		require.True(t, g.checkReserve(ATNUSDC, "", big.NewInt(1000e6), 6))
+		require.False(t, g.checkReserve(ATNUSDC, "", big.NewInt(999e6), 6))
+	})
+
+	t.Run("max price impact", func(t *testing.T) {
+		require.True(t, g.checkPriceImpact(ATNUSDC, "", decimal.NewFromInt(4), decimal.RequireFromString("4.2")))
+		require.True(t, g.checkPriceImpact(ATNUSDC, "", decimal.NewFromInt(4), decimal.RequireFromString("3.8")))
+		require.False(t, g.checkPriceImpact(ATNUSDC, "", decimal.NewFromInt(4), decimal.RequireFromString("4.21")))
+		// without the price before the swap, the price impact is not checked.
+		require.True(t, g.checkPriceImpact(ATNUSDC, "", decimal.Zero, decimal.NewFromInt(4)))
+	})
+
+	t.Run("max order share of window volume", func(t *testing.T) {
+		var orderBook ring.Ring
+		orderBook.SetCapacity(orderBookCapacity)
+		// the orders of a thin window are not checked.
+		require.True(t, g.checkOrderShare(ATNUSDC, "", &orderBook, Order{volume: big.NewInt(1000)}))
+
+		for i := 0; i < minShareWindowOrders; i++ {
+			orderBook.Enqueue(Order{cryptoToUsdcPrice: decimal.NewFromInt(4), volume: big.NewInt(100)})
+		}
+		require.True(t, g.checkOrderShare(ATNUSDC, "", &orderBook, Order{volume: big.NewInt(800)}))
+		require.False(t, g.checkOrderShare(ATNUSDC, "", &orderBook, Order{volume: big.NewInt(801)}))
+	})
+
+	t.Run("a dust swap does not lock the order book", func(t *testing.T) {
+		g := newLiquidityGuards(&config.PluginConfig{MaxOrderShare: 0.2}, hclog.NewNullLogger())
+		var orderBook ring.Ring
+		orderBook.SetCapacity(orderBookCapacity)
+
+		// the orders passing the guard are enqueued to the window, as the plugins do.
+		swap := func(volume int64) bool {
+			order := Order{cryptoToUsdcPrice: decimal.NewFromInt(4), volume: big.NewInt(volume)}
+			if !g.checkOrderShare(ATNUSDC, "", &orderBook, order) {
+				return false
+			}
+			orderBook.Enqueue(order)
+			return true
+		}
+
+		require.True(t, swap(1))
+		for i := 0; i < 2*minShareWindowOrders; i++ {
+			require.True(t, swap(1000), "swap %d", i)
+		}
+		// an outsized swap is still rejected once the window is filled.
+		require.False(t, swap(10000))
+	})
+}
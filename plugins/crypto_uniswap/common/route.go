@@ -0,0 +1,177 @@
+package common
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/plugins/crypto_uniswap/contracts/factory"
+	"autonity-oracle/types"
+	"fmt"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"strings"
+)
+
+// pricedSymbol is a symbol reported by the client, it is priced by its direct pair, or by its routes in order if there
+// is no direct pair or if the pair fails.
+type pricedSymbol struct {
+	symbol string
+	pair   *WrappedPair
+	routes []*route
+}
+
+// route is a multi-hop route of a symbol, it hops through the pairs of the consecutive tokens of its path.
+type route struct {
+	symbol string
+	legs   []routeLeg
+}
+
+// routeLeg is a hop of a route through a pair, the hop is inverted if it is from the quote token to the base token of
+// the pair, as the pair is shared with a pair or a route of the other direction.
+type routeLeg struct {
+	pair     *WrappedPair
+	inverted bool
+}
+
+// venue returns the pair addresses of the route as its market identifier.
+func (r *route) venue() string {
+	venues := make([]string, 0, len(r.legs))
+	for _, leg := range r.legs {
+		venues = append(venues, leg.pair.pairAddress.Hex())
+	}
+	return strings.Join(venues, ">")
+}
+
+// quoteDecimals returns the decimals of the quote token of the route, which is the last token of its path.
+func (r *route) quoteDecimals() uint8 {
+	last := r.legs[len(r.legs)-1]
+	if last.inverted {
+		return last.pair.baseDecimals
+	}
+	return last.pair.quoteDecimals
+}
+
+// bindRoute binds the pairs of the consecutive tokens of a route, a pair which is bound already is shared with the
+// route, the others are bound as the legs of the routes, their prices are not reported by themselves.
+func (e *UniswapClient) bindRoute(factoryContract *factory.Factory, routeConf config.RouteConfig) (*route, error) {
+	r := &route{symbol: routeConf.Symbol}
+	for i := 0; i+1 < len(routeConf.Path); i++ {
+		from := ecommon.HexToAddress(routeConf.Path[i])
+		to := ecommon.HexToAddress(routeConf.Path[i+1])
+		legConf := config.PairConfig{Symbol: from.Hex() + "-" + to.Hex(), Base: from.Hex(), Quote: to.Hex()}
+		wp, err := bindWithPairContract(factoryContract, e.backend, legConf, e.logger)
+		if err != nil {
+			return nil, err
+		}
+		wp.leg = true
+		r.legs = append(r.legs, routeLeg{pair: wp})
+	}
+
+	for i, leg := range r.legs {
+		if bound, ok := e.pairsByAddress[leg.pair.pairAddress]; ok {
+			r.legs[i] = routeLeg{pair: bound, inverted: bound.baseToken != leg.pair.baseToken}
+			continue
+		}
+		e.addPair(leg.pair)
+	}
+	return r, nil
+}
+
+// symbolPrice prices a symbol by its direct pair, and it falls back to the routes of the symbol in order.
+func (e *UniswapClient) symbolPrice(s *pricedSymbol) (common.Price, error) {
+	var err error
+	if s.pair != nil {
+		var price common.Price
+		if price, _, err = e.pairPrice(s.pair); err == nil {
+			return price, nil
+		}
+	}
+
+	for _, r := range s.routes {
+		var price common.Price
+		if price, err = e.routePrice(r); err == nil {
+			return price, nil
+		}
+		e.logger.Info("failed to price by route, falling back to the next one", "symbol", s.symbol,
+			"route", r.venue(), "error", err)
+	}
+	return common.Price{}, err
+}
+
+// routePrice combines the prices of the legs of a route, the exchange ratio of the route is the product of the ratios
+// of its legs, and its volume is the volume of the bottleneck leg in the quote token of the route. The volume is the
+// default one if a leg is priced without swaps.
+func (e *UniswapClient) routePrice(r *route) (common.Price, error) {
+	var price common.Price
+	ratios := make([]decimal.Decimal, len(r.legs))
+	volumes := make([]*big.Int, len(r.legs))
+	traded := true
+	for i, leg := range r.legs {
+		legPrice, legTraded, err := e.pairPrice(leg.pair)
+		if err != nil {
+			return price, fmt.Errorf("leg %d of the route: %w", i, err)
+		}
+
+		legRatio, err := decimal.NewFromString(legPrice.Price)
+		if err != nil {
+			return price, err
+		}
+		if leg.inverted {
+			if legRatio.IsZero() {
+				return price, fmt.Errorf("leg %d of the route: zero exchange ratio", i)
+			}
+			legRatio = decimal.NewFromInt(1).DivRound(legRatio, common.CryptoToUsdcDecimals)
+		}
+		ratios[i] = legRatio
+
+		if legTraded {
+			volumes[i], _ = new(big.Int).SetString(legPrice.Volume, 10)
+		}
+		traded = traded && volumes[i] != nil
+
+		if legPrice.Timestamp != 0 && (price.Timestamp == 0 || legPrice.Timestamp < price.Timestamp) {
+			price.Timestamp = legPrice.Timestamp
+		}
+	}
+
+	routeRatio := decimal.NewFromInt(1)
+	for _, legRatio := range ratios {
+		routeRatio = routeRatio.Mul(legRatio)
+	}
+
+	price.Symbol = r.symbol
+	price.Price = routeRatio.Round(common.CryptoToUsdcDecimals).String()
+	price.Volume = types.DefaultVolume.String()
+	price.Venue = r.venue()
+	if traded {
+		price.Volume = r.bottleneckVolume(ratios, volumes).String()
+	}
+	return price, nil
+}
+
+// bottleneckVolume converts the volumes of the legs into the quote token of the route, and returns the smallest one.
+// The volume of a leg is in the quote token of its pair, which is the token after the hop, or the one before the hop if
+// the hop is inverted, an amount of a token of the path is converted by the ratios of the hops after it.
+func (r *route) bottleneckVolume(ratios []decimal.Decimal, volumes []*big.Int) *big.Int {
+	var bottleneck *big.Int
+	for i, leg := range r.legs {
+		token := i + 1
+		if leg.inverted {
+			token = i
+		}
+
+		amount := decimal.NewFromBigInt(volumes[i], -int32(leg.pair.quoteDecimals))
+		for _, hopRatio := range ratios[token:] {
+			if hopRatio.IsZero() {
+				return new(big.Int)
+			}
+			amount = amount.DivRound(hopRatio, common.CryptoToUsdcDecimals)
+		}
+
+		volume := amount.Shift(int32(r.quoteDecimals())).BigInt()
+		if bottleneck == nil || volume.Cmp(bottleneck) < 0 {
+			bottleneck = volume
+		}
+	}
+	return bottleneck
+}
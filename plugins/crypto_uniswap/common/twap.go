@@ -0,0 +1,266 @@
+package common
+
+import (
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ecommon "github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	defaultTWAPWindow      = 1800 // 30 minutes.
+	twapSnapshotsPerWindow = 12   // the snapshots are taken 12 times in a window.
+	q112                   = new(big.Int).Lsh(big.NewInt(1), 112)
+	uint256Modulus         = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+// TWAPSnapshot is a snapshot of the cumulative price of the base token in the quote token of a pair at a block time.
+type TWAPSnapshot struct {
+	Block      uint64 `json:"block"`
+	Timestamp  int64  `json:"timestamp"`
+	Cumulative string `json:"cumulative"` // the UQ112x112 price accumulated over the seconds in decimal.
+}
+
+// twapOracle keeps the cumulative price snapshots of the pairs over a window, it persists them in a file, thus the
+// window survives the restarts of the plugin.
+type twapOracle struct {
+	window int64
+	file   string
+	logger hclog.Logger
+
+	mutex     sync.RWMutex
+	snapshots map[ecommon.Address][]TWAPSnapshot
+}
+
+// newTWAPOracle creates the TWAP oracle of a window in seconds, and it loads the snapshots persisted by the plugin.
+func newTWAPOracle(window int, stateDir, name string, logger hclog.Logger) *twapOracle {
+	if window == 0 {
+		window = defaultTWAPWindow
+	}
+
+	t := &twapOracle{
+		window:    int64(window),
+		file:      filepath.Join(stateDir, name+"_twap_snapshots.json"),
+		logger:    logger,
+		snapshots: make(map[ecommon.Address][]TWAPSnapshot),
+	}
+
+	content, err := os.ReadFile(t.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("cannot read TWAP snapshots", "file", t.file, "error", err)
+		}
+		return t
+	}
+	if err = json.Unmarshal(content, &t.snapshots); err != nil {
+		logger.Warn("cannot parse TWAP snapshots, the window starts over", "file", t.file, "error", err)
+		t.snapshots = make(map[ecommon.Address][]TWAPSnapshot)
+	}
+	return t
+}
+
+// snapshotInterval is the interval to take the snapshots of the pairs.
+func (t *twapOracle) snapshotInterval() time.Duration {
+	interval := time.Duration(t.window) * time.Second / time.Duration(twapSnapshotsPerWindow)
+	if interval < time.Second {
+		return time.Second
+	}
+	return interval
+}
+
+// add appends a snapshot of a pair, the snapshots which are older than the window start are pruned except the latest
+// one of them, as it is the start of the window.
+func (t *twapOracle) add(pair ecommon.Address, snapshot TWAPSnapshot) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	snapshots := t.snapshots[pair]
+	if len(snapshots) > 0 && snapshots[len(snapshots)-1].Timestamp >= snapshot.Timestamp {
+		return
+	}
+	snapshots = append(snapshots, snapshot)
+
+	windowStart := snapshot.Timestamp - t.window
+	for len(snapshots) > 1 && snapshots[1].Timestamp <= windowStart {
+		snapshots = snapshots[1:]
+	}
+	t.snapshots[pair] = snapshots
+}
+
+// windowStart returns the latest snapshot of a pair which is not after the start of the window ending at now.
+func (t *twapOracle) windowStart(pair ecommon.Address, now int64) (TWAPSnapshot, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	snapshots := t.snapshots[pair]
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Timestamp <= now-t.window {
+			return snapshots[i], nil
+		}
+	}
+	return TWAPSnapshot{}, fmt.Errorf("the TWAP window of %d seconds is not covered by the snapshots yet", t.window)
+}
+
+// flush persists the snapshots into the file of the plugin.
+func (t *twapOracle) flush() error {
+	t.mutex.RLock()
+	content, err := json.Marshal(t.snapshots)
+	t.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(t.file), 0700); err != nil {
+		return err
+	}
+	tmp := t.file + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.file)
+}
+
+// twapRatio computes the time weighted average exchange ratio of the base token to the quote token between two
+// snapshots, as ratio() does for the reserves.
+func twapRatio(start, end TWAPSnapshot, baseDecimals, quoteDecimals uint8) (decimal.Decimal, error) {
+	var r decimal.Decimal
+	elapsed := end.Timestamp - start.Timestamp
+	if elapsed <= 0 {
+		return r, fmt.Errorf("no time elapsed between the snapshots, cannot compute exchange ratio")
+	}
+
+	startCumulative, ok := new(big.Int).SetString(start.Cumulative, 10)
+	if !ok {
+		return r, fmt.Errorf("invalid cumulative price %q", start.Cumulative)
+	}
+	endCumulative, ok := new(big.Int).SetString(end.Cumulative, 10)
+	if !ok {
+		return r, fmt.Errorf("invalid cumulative price %q", end.Cumulative)
+	}
+
+	// the cumulative prices are accumulated with the overflow of uint256 on-chain.
+	delta := new(big.Int).Sub(endCumulative, startCumulative)
+	delta.Mod(delta, uint256Modulus)
+
+	// the raw amount of base per raw amount of quote.
+	price := new(big.Rat).SetFrac(delta, new(big.Int).Mul(q112, big.NewInt(elapsed)))
+
+	// ratio == (base/baseDecimals) / (quote/quoteDecimals) == base/quote * quoteDecimals / baseDecimals
+	scale := new(big.Rat).SetFrac(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(quoteDecimals)), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(baseDecimals)), nil))
+	price.Mul(price, scale)
+
+	return decimal.NewFromString(price.FloatString(common.CryptoToUsdcDecimals))
+}
+
+// cumulativeSnapshot takes the snapshot of the cumulative price of the base token in the quote token of a pair at the
+// latest block, the header, the cumulative price and the reserves are all read at the number of the header, thus a
+// block mined in between does not skew the snapshot. The pair accumulates the price only on its updates, thus the price
+// of its reserves is accumulated for the time elapsed since its last update, as the UniswapV2OracleLibrary does.
+func (e *UniswapClient) cumulativeSnapshot(p *WrappedPair) (TWAPSnapshot, error) {
+	var snapshot TWAPSnapshot
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.conf.Timeout)*time.Second)
+	defer cancel()
+	header, err := e.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// the price1 accumulates reserve0/reserve1, i.e. the raw amount of token0 per raw amount of token1, and the price0
+	// accumulates reserve1/reserve0, thus the base per quote is the price1 if the base is token0.
+	opts := &bind.CallOpts{BlockNumber: header.Number, Context: ctx}
+	var cumulative *big.Int
+	if p.token0 == p.baseToken {
+		cumulative, err = p.pairContract.Price1CumulativeLast(opts)
+	} else {
+		cumulative, err = p.pairContract.Price0CumulativeLast(opts)
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	reserves, err := p.pairContract.GetReserves(opts)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// the block timestamps are accumulated in uint32 with the overflow on-chain.
+	elapsed := uint32(header.Time) - reserves.BlockTimestampLast
+	baseReserve, quoteReserve := p.reserves(reserves.Reserve0, reserves.Reserve1)
+	if elapsed > 0 && baseReserve.Sign() > 0 && quoteReserve.Sign() > 0 {
+		price := new(big.Int).Div(new(big.Int).Lsh(baseReserve, 112), quoteReserve)
+		cumulative = new(big.Int).Add(cumulative, price.Mul(price, big.NewInt(int64(elapsed))))
+		cumulative.Mod(cumulative, uint256Modulus)
+	}
+
+	snapshot.Block = header.Number.Uint64()
+	snapshot.Timestamp = int64(header.Time)
+	snapshot.Cumulative = cumulative.String()
+	return snapshot, nil
+}
+
+// takeSnapshots takes the cumulative price snapshots of all the pairs, and persists them.
+func (e *UniswapClient) takeSnapshots() {
+	for _, p := range e.pairs {
+		snapshot, err := e.cumulativeSnapshot(p)
+		if err != nil {
+			e.logger.Error("cannot take TWAP snapshot", "symbol", p.symbol, "error", err)
+			continue
+		}
+		e.twap.add(p.pairAddress, snapshot)
+	}
+
+	if err := e.twap.flush(); err != nil {
+		e.logger.Error("cannot persist TWAP snapshots", "error", err)
+	}
+}
+
+// fetchTWAPPrice computes the price of a pair by the time weighted average over the window ending at the latest block.
+func (e *UniswapClient) fetchTWAPPrice(p *WrappedPair) (common.Price, error) {
+	var price common.Price
+	end, err := e.cumulativeSnapshot(p)
+	if err != nil {
+		e.logger.Error("cannot take cumulative price snapshot", "symbol", p.symbol, "error", err)
+		return price, err
+	}
+
+	start, err := e.twap.windowStart(p.pairAddress, end.Timestamp)
+	if err != nil {
+		return price, err
+	}
+
+	// the reserves are guarded at the block of the end snapshot, as its cumulative price.
+	if e.guards.reserveRequired() {
+		reserves, err := p.pairContract.GetReserves(&bind.CallOpts{BlockNumber: new(big.Int).SetUint64(end.Block)})
+		if err != nil {
+			e.logger.Error("cannot get reserves from uni-swap liquidity pool", "error", err)
+			return price, err
+		}
+		if !e.checkReserve(p, reserves.Reserve0, reserves.Reserve1) {
+			return price, fmt.Errorf("the reserve of the pool of %s is below the guard", p.symbol)
+		}
+	}
+
+	r, err := twapRatio(start, end, p.baseDecimals, p.quoteDecimals)
+	if err != nil {
+		e.logger.Error("cannot compute time weighted exchange ratio", "symbol", p.symbol, "error", err)
+		return price, err
+	}
+
+	price.Symbol = p.symbol
+	price.Price = r.String()
+	price.Volume = types.DefaultVolume.String()
+	price.Timestamp = end.Timestamp
+	price.Venue = p.pairAddress.Hex()
+	return price, nil
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+// cumulativeOf returns the UQ112x112 cumulative price of a raw price over the seconds.
+func cumulativeOf(price, seconds int64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).Lsh(big.NewInt(price), 112), big.NewInt(seconds))
+}
+
+func TestTWAPRatio(t *testing.T) {
+	// a price of 4 for 900s and 8 for another 900s.
+	start := TWAPSnapshot{Timestamp: 100, Cumulative: "0"}
+	end := TWAPSnapshot{Timestamp: 1900, Cumulative: new(big.Int).Add(cumulativeOf(4, 900), cumulativeOf(8, 900)).String()}
+	r, err := twapRatio(start, end, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, "6", r.String())
+
+	// the raw price is scaled by the decimals, 6e12 raw ATN per raw USDC is 6 ATN per USDC.
+	end.Cumulative = cumulativeOf(6e12, 1800).String()
+	r, err = twapRatio(start, end, 18, 6)
+	require.NoError(t, err)
+	require.Equal(t, "6", r.String())
+
+	// the cumulative price overflows uint256.
+	wrapped := new(big.Int).Sub(uint256Modulus, cumulativeOf(1, 100))
+	start.Cumulative = wrapped.String()
+	end.Cumulative = new(big.Int).Sub(cumulativeOf(6, 1800), cumulativeOf(1, 100)).String()
+	r, err = twapRatio(start, end, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, "6", r.String())
+
+	_, err = twapRatio(end, end, 0, 0)
+	require.Error(t, err)
+}
+
+func TestTWAPOracle(t *testing.T) {
+	dir := t.TempDir()
+	oracle := newTWAPOracle(1800, dir, "crypto_uniswap", hclog.NewNullLogger())
+	require.Equal(t, 150*1e9, float64(oracle.snapshotInterval()))
+
+	for ts := int64(0); ts <= 1200; ts += 600 {
+		oracle.add(testATNPair, TWAPSnapshot{Timestamp: ts, Cumulative: cumulativeOf(1, ts).String()})
+	}
+	_, err := oracle.windowStart(testATNPair, 1700)
+	require.Error(t, err)
+
+	// the snapshots before the window start are pruned except the latest one of them.
+	oracle.add(testATNPair, TWAPSnapshot{Timestamp: 1800, Cumulative: cumulativeOf(1, 1800).String()})
+	oracle.add(testATNPair, TWAPSnapshot{Timestamp: 2400, Cumulative: cumulativeOf(1, 2400).String()})
+	require.Len(t, oracle.snapshots[testATNPair], 4)
+	start, err := oracle.windowStart(testATNPair, 2500)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), start.Timestamp)
+
+	// a snapshot which is not after the latest one is dropped.
+	oracle.add(testATNPair, TWAPSnapshot{Timestamp: 2400, Cumulative: "0"})
+	require.Len(t, oracle.snapshots[testATNPair], 4)
+
+	require.NoError(t, oracle.flush())
+	reloaded := newTWAPOracle(1800, dir, "crypto_uniswap", hclog.NewNullLogger())
+	require.Equal(t, oracle.snapshots, reloaded.snapshots)
+}
@@ -12,7 +12,6 @@ import (
 	"net/url"
 	"os"
 	"strings"
-	"time"
 )
 
 const (
@@ -55,7 +54,7 @@ type CLClient struct {
 }
 
 func NewCLClient(conf *config.PluginConfig) *CLClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   conf.Name,
 		Level:  hclog.Info,
@@ -140,6 +139,7 @@ func (cl *CLClient) symbolsToPrice(s string, res *CLResult) (common.Price, error
 
 	price.Symbol = s
 	price.Volume = types.DefaultVolume.String()
+	price.Timestamp = res.Timestamp
 	switch from {
 	case "EUR":
 		price.Price = decimal.NewFromInt(1).Div(res.Quotes.USDEUR).String()
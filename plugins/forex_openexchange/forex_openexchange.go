@@ -12,7 +12,6 @@ import (
 	"net/url"
 	"os"
 	"strings"
-	"time"
 )
 
 const (
@@ -55,7 +54,7 @@ type OXClient struct {
 }
 
 func NewOXClient(conf *config.PluginConfig) *OXClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   "OpenExchangeRate",
 		Level:  hclog.Info,
@@ -140,6 +139,7 @@ func (oe *OXClient) symbolsToPrice(s string, res *OEResult) (common.Price, error
 	}
 	price.Symbol = s
 	price.Volume = types.DefaultVolume.String()
+	price.Timestamp = res.Timestamp // the rates are published hourly, the quote TS is the publishing TS.
 	switch from {
 	case "EUR":
 		price.Price = decimal.NewFromInt(1).Div(res.Rates.EUR).String()
@@ -11,7 +11,6 @@ import (
 	"net/url"
 	"os"
 	"strings"
-	"time"
 )
 
 // This plugin is only used for autonity round 4 game purpose, the data of NTN-USDC & ATN-USDC come from a simulated
@@ -52,7 +51,7 @@ type CAXClient struct {
 }
 
 func NewCAXClient(conf *config.PluginConfig) *CAXClient {
-	client := common.NewClient(conf.Key, time.Second*time.Duration(conf.Timeout), conf.Endpoint)
+	client := common.NewPluginClient(conf)
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:   "AutonityR4CAX",
 		Level:  hclog.Info,
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is a step of a JSONPath, it selects a field of an object, an element of an array by its index, or the first
+// element of an array which matches a filter.
+type pathStep struct {
+	key    string
+	index  int
+	isIdx  bool
+	filter *pathFilter
+}
+
+// pathFilter is the equality filter of a JSONPath step, i.e. [?(@.symbol=='EURUSD')].
+type pathFilter struct {
+	path  []pathStep
+	value string
+}
+
+// parsePath parses the JSONPath subset of the REST JSON plugin: $.field, $['field'], $.list[0], $.list[-1] for the last
+// element, and $.list[?(@.field=='value')] for the first element of which the field equals the value.
+func parsePath(path string) ([]pathStep, error) {
+	rest := strings.TrimSpace(path)
+	rest = strings.TrimPrefix(rest, "$")
+	if rest == "" {
+		return nil, nil
+	}
+	if rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var steps []pathStep
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty field in path %q", path)
+			}
+			steps = append(steps, pathStep{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in path %q", path)
+			}
+			inner := rest[1:end]
+			switch {
+			case strings.HasPrefix(inner, "?("):
+				// the filter value might contain a "]", thus the filter ends at the first ")]".
+				end = strings.Index(rest, ")]")
+				if end < 0 {
+					return nil, fmt.Errorf("unclosed filter in path %q", path)
+				}
+				filter, err := parseFilter(rest[3:end])
+				if err != nil {
+					return nil, fmt.Errorf("%w in path %q", err, path)
+				}
+				steps = append(steps, pathStep{filter: filter})
+				end++
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, pathStep{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(strings.TrimSpace(inner))
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in path %q", inner, path)
+				}
+				steps = append(steps, pathStep{index: index, isIdx: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in path %q", rest[0], path)
+		}
+	}
+	return steps, nil
+}
+
+// parseFilter parses the @.field=='value' expression of a filter.
+func parseFilter(expr string) (*pathFilter, error) {
+	parts := strings.SplitN(expr, "==", 2)
+	if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[0]), "@") {
+		return nil, fmt.Errorf("invalid filter %q", expr)
+	}
+	fieldPath, err := parsePath(strings.TrimPrefix(strings.TrimSpace(parts[0]), "@"))
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(parts[1])
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return &pathFilter{path: fieldPath, value: value}, nil
+}
+
+// evalPath selects the value of the path in a JSON document which is decoded with the numbers kept as json.Number.
+func evalPath(doc interface{}, steps []pathStep) (interface{}, error) {
+	node := doc
+	for _, step := range steps {
+		switch {
+		case step.filter != nil:
+			list, ok := node.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("filter on a non-array value")
+			}
+			var found bool
+			for _, element := range list {
+				value, err := evalPath(element, step.filter.path)
+				if err != nil {
+					continue
+				}
+				if s, err := scalarString(value); err == nil && s == step.filter.value {
+					node, found = element, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no element matches %s", step.filter.value)
+			}
+		case step.isIdx:
+			list, ok := node.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("index %d on a non-array value", step.index)
+			}
+			index := step.index
+			if index < 0 {
+				index += len(list)
+			}
+			if index < 0 || index >= len(list) {
+				return nil, fmt.Errorf("index %d is out of range", step.index)
+			}
+			node = list[index]
+		default:
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("field %s on a non-object value", step.key)
+			}
+			if node, ok = object[step.key]; !ok {
+				return nil, fmt.Errorf("field %s is not found", step.key)
+			}
+		}
+	}
+	return node, nil
+}
+
+// scalarString returns the string of a string or a number value.
+func scalarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("value of type %T is not a string or a number", value)
+	}
+}
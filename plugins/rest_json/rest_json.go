@@ -0,0 +1,329 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"autonity-oracle/types"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const version = "v0.0.1"
+
+var (
+	errNoSymbols     = errors.New("no symbol is configured")
+	errNoEndpoint    = errors.New("the endpoint of the REST API is not configured")
+	errInvalidPrice  = errors.New("the price is not positive")
+	defaultSeparator = ","
+)
+
+var defaultConfig = config.PluginConfig{
+	Name:               "rest_json",
+	Scheme:             "https",
+	Timeout:            10, // 10s
+	DataUpdateInterval: 30, // 30s, the rate limit of the provider might require a longer interval.
+}
+
+// restSymbol is a configured symbol, its JSONPath templates are expanded and parsed on start.
+type restSymbol struct {
+	symbol    string
+	remote    string
+	base      string
+	quote     string
+	invert    bool
+	price     []pathStep
+	volume    []pathStep // nil if the default volume is taken.
+	timestamp []pathStep // nil if the fetch time is taken.
+}
+
+type RESTClient struct {
+	conf    *config.PluginConfig
+	client  *common.Client
+	logger  hclog.Logger
+	symbols []*restSymbol
+}
+
+func NewRESTClient(conf *config.PluginConfig) (*RESTClient, error) {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   conf.Name,
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+	client := common.NewPluginClient(conf)
+	return newRESTClient(conf, client, logger)
+}
+
+func newRESTClient(conf *config.PluginConfig, client *common.Client, logger hclog.Logger) (*RESTClient, error) {
+	if conf.Endpoint == "" {
+		logger.Error("cannot start plugin", "error", errNoEndpoint)
+		return nil, errNoEndpoint
+	}
+	if len(conf.REST.Symbols) == 0 {
+		logger.Error("cannot start plugin", "error", errNoSymbols)
+		return nil, errNoSymbols
+	}
+
+	rc := &RESTClient{conf: conf, client: client, logger: logger}
+	for _, symbolConf := range conf.REST.Symbols {
+		s, err := newRESTSymbol(conf.REST, symbolConf)
+		if err != nil {
+			logger.Error("cannot resolve symbol", "symbol", symbolConf.Symbol, "error", err)
+			return nil, fmt.Errorf("symbol %s: %w", symbolConf.Symbol, err)
+		}
+		rc.symbols = append(rc.symbols, s)
+	}
+	return rc, nil
+}
+
+// newRESTSymbol maps a protocol symbol to the provider, and it parses the JSONPath templates expanded for the symbol.
+func newRESTSymbol(restConf config.RESTConfig, symbolConf config.RESTSymbolConfig) (*restSymbol, error) {
+	s := &restSymbol{
+		symbol: symbolConf.Symbol,
+		remote: symbolConf.Remote,
+		base:   symbolConf.Symbol,
+		invert: symbolConf.Invert,
+	}
+	if s.remote == "" {
+		s.remote = s.symbol
+	}
+	if sep := common.ResolveSeparator(s.symbol); sep != "" {
+		if parts := strings.Split(s.symbol, sep); len(parts) == 2 {
+			s.base, s.quote = parts[0], parts[1]
+		}
+	}
+
+	var err error
+	if s.price, err = parsePath(s.expand(restConf.Price)); err != nil {
+		return nil, err
+	}
+	if restConf.Volume != "" {
+		if s.volume, err = parsePath(s.expand(restConf.Volume)); err != nil {
+			return nil, err
+		}
+	}
+	if restConf.Timestamp != "" {
+		if s.timestamp, err = parsePath(s.expand(restConf.Timestamp)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// expand replaces the placeholders of a template with the provider symbol, the base and the quote of the symbol.
+func (s *restSymbol) expand(template string) string {
+	return strings.NewReplacer("{symbol}", s.remote, "{base}", s.base, "{quote}", s.quote).Replace(template)
+}
+
+func (rc *RESTClient) KeyRequired() bool {
+	return rc.conf.REST.AuthHeader != "" || rc.conf.REST.AuthQuery != ""
+}
+
+// FetchPrice requests the prices of the symbols at once in the batch mode, or symbol by symbol in the symbol mode, a
+// symbol is not reported if its price cannot be extracted from the response.
+func (rc *RESTClient) FetchPrice(symbols []string) (common.Prices, error) {
+	var asked []*restSymbol
+	for _, symbol := range symbols {
+		for _, s := range rc.symbols {
+			if s.symbol == symbol {
+				asked = append(asked, s)
+				break
+			}
+		}
+	}
+
+	var prices common.Prices
+	if rc.conf.REST.Mode == config.RESTModeBatch {
+		remotes := make([]string, len(asked))
+		for i, s := range asked {
+			remotes[i] = s.remote
+		}
+		separator := rc.conf.REST.Separator
+		if separator == "" {
+			separator = defaultSeparator
+		}
+		path := strings.ReplaceAll(rc.conf.REST.Path, "{symbols}", strings.Join(remotes, separator))
+		doc, err := rc.request(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range asked {
+			price, err := s.toPrice(doc)
+			if err != nil {
+				rc.logger.Error("cannot extract price", "symbol", s.symbol, "error", err)
+				continue
+			}
+			prices = append(prices, price)
+		}
+		return prices, nil
+	}
+
+	for _, s := range asked {
+		doc, err := rc.request(s.expand(rc.conf.REST.Path))
+		if err != nil {
+			continue
+		}
+		price, err := s.toPrice(doc)
+		if err != nil {
+			rc.logger.Error("cannot extract price", "symbol", s.symbol, "error", err)
+			continue
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// request gets the expanded path on the endpoint with the API key, and it decodes the JSON response.
+func (rc *RESTClient) request(path string) (interface{}, error) {
+	u, err := url.Parse(rc.conf.Scheme + "://" + rc.conf.Endpoint + path)
+	if err != nil {
+		rc.logger.Error("invalid url", "path", path, "error", err)
+		return nil, err
+	}
+	if rc.conf.REST.AuthQuery != "" {
+		query := u.Query()
+		query.Set(rc.conf.REST.AuthQuery, rc.client.ApiKey)
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		rc.logger.Error("cannot create request", "error", err)
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if rc.conf.REST.AuthHeader != "" {
+		req.Header.Set(rc.conf.REST.AuthHeader, rc.conf.REST.AuthPrefix+rc.client.ApiKey)
+	}
+
+	res, err := rc.client.Conn.Do(req)
+	if err != nil {
+		rc.logger.Error("https request", "path", path, "error", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err = common.CheckHTTPStatusCode(res.StatusCode); err != nil {
+		rc.logger.Error("data source return error", "path", path, "error", err)
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		rc.logger.Error("io read", "error", err)
+		return nil, err
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&doc); err != nil {
+		rc.logger.Error("unmarshal result", "error", err)
+		return nil, err
+	}
+	return doc, nil
+}
+
+// toPrice extracts the price, the volume and the timestamp of the symbol from the response, the price is inverted if
+// the provider quotes it the other way around.
+func (s *restSymbol) toPrice(doc interface{}) (common.Price, error) {
+	var price common.Price
+	value, err := extractDecimal(doc, s.price)
+	if err != nil {
+		return price, fmt.Errorf("price: %w", err)
+	}
+	if !value.IsPositive() {
+		return price, errInvalidPrice
+	}
+	if s.invert {
+		value = decimal.NewFromInt(1).DivRound(value, common.CryptoToUsdcDecimals)
+	}
+
+	price.Symbol = s.symbol
+	price.Price = value.String()
+	price.Volume = types.DefaultVolume.String()
+	price.Venue = s.remote
+	if s.volume != nil {
+		volume, err := extractDecimal(doc, s.volume)
+		if err != nil {
+			return price, fmt.Errorf("volume: %w", err)
+		}
+		price.Volume = volume.BigInt().String()
+	}
+	if s.timestamp != nil {
+		if price.Timestamp, err = extractTimestamp(doc, s.timestamp); err != nil {
+			return price, fmt.Errorf("timestamp: %w", err)
+		}
+	}
+	return price, nil
+}
+
+// extractDecimal extracts a decimal from a number or a string value.
+func extractDecimal(doc interface{}, steps []pathStep) (decimal.Decimal, error) {
+	value, err := evalPath(doc, steps)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	s, err := scalarString(value)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return decimal.NewFromString(s)
+}
+
+// extractTimestamp extracts a Unix time in seconds from a number of seconds or milliseconds, or from a RFC3339 string.
+func extractTimestamp(doc interface{}, steps []pathStep) (int64, error) {
+	value, err := evalPath(doc, steps)
+	if err != nil {
+		return 0, err
+	}
+	if s, ok := value.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	s, err := scalarString(value)
+	if err != nil {
+		return 0, err
+	}
+	ts, err := decimal.NewFromString(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a Unix time nor a RFC3339 time", s)
+	}
+	// the Unix times in milliseconds are beyond the year 33658 in seconds.
+	if ts.GreaterThan(decimal.NewFromInt(1e12)) {
+		ts = ts.Div(decimal.NewFromInt(1000))
+	}
+	return ts.IntPart(), nil
+}
+
+func (rc *RESTClient) AvailableSymbols() ([]string, error) {
+	symbols := make([]string, len(rc.symbols))
+	for i, s := range rc.symbols {
+		symbols[i] = s.symbol
+	}
+	return symbols, nil
+}
+
+func (rc *RESTClient) Close() {
+	rc.client.Conn.Close()
+}
+
+func main() {
+	conf := common.ResolveConf(os.Args[0], &defaultConfig)
+	client, err := NewRESTClient(conf)
+	if err != nil {
+		return
+	}
+
+	adapter := common.NewPlugin(conf, client, version, types.SrcCEX, nil)
+	defer adapter.Close()
+	common.PluginServe(adapter)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/plugins/common"
+	"encoding/json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePath(t *testing.T) {
+	var doc interface{}
+	decoder := json.NewDecoder(strings.NewReader(`{"result":{"USDC.USD":{"p":["0.9998",1.0001]}},
+		"data":[{"symbol":"EURUSD","price":1.08},{"symbol":"GBPUSD","price":"1.27"}]}`))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&doc))
+
+	tests := []struct {
+		path  string
+		value string
+	}{
+		{"$.result['USDC.USD'].p[0]", "0.9998"},
+		{`$["result"]["USDC.USD"].p[-1]`, "1.0001"},
+		{"$.data[?(@.symbol=='GBPUSD')].price", "1.27"},
+		{`data[?(@.symbol == "EURUSD")].price`, "1.08"},
+	}
+	for _, test := range tests {
+		steps, err := parsePath(test.path)
+		require.NoError(t, err, test.path)
+		value, err := evalPath(doc, steps)
+		require.NoError(t, err, test.path)
+		s, err := scalarString(value)
+		require.NoError(t, err)
+		require.Equal(t, test.value, s, test.path)
+	}
+
+	for _, path := range []string{"$.result..p", "$.data[0", "$.data[first]", "$.data[?(@.symbol)]"} {
+		_, err := parsePath(path)
+		require.Error(t, err, path)
+	}
+	for _, path := range []string{"$.result.USDT", "$.data[2]", "$.data[?(@.symbol=='JPYUSD')]", "$.result[0]"} {
+		steps, err := parsePath(path)
+		require.NoError(t, err, path)
+		_, err = evalPath(doc, steps)
+		require.Error(t, err, path)
+	}
+}
+
+func TestRESTClient(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/0/public/Ticker":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			pair := r.URL.Query().Get("pair")
+			if pair != "USDCUSD" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"error":[],"result":{"USDCUSD":{"p":["0.9998","0.9997"],"v":["1234.5","2000.7"]}}}`))
+		case "/v2.0/rates/latest":
+			if r.URL.Query().Get("apikey") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"date":"2024-01-02T03:04:05Z","base":"USD","rates":{"EUR":"0.8","JPY":"150"}}`))
+		case "/tickers":
+			_, _ = w.Write([]byte(`{"data":[{"symbol":"EURUSD","price":1.08,"ts":1700000000123}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newClient := func(t *testing.T, rest config.RESTConfig) *RESTClient {
+		conf := &config.PluginConfig{
+			Name:     "rest_json",
+			Key:      "secret",
+			Scheme:   "http",
+			Endpoint: strings.TrimPrefix(server.URL, "http://"),
+			Timeout:  10,
+			REST:     rest,
+		}
+		client, err := newRESTClient(conf, common.NewClient(conf.Key, 10*time.Second, conf.Endpoint), hclog.NewNullLogger())
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("symbol mode with the API key in the header", func(t *testing.T) {
+		requests = nil
+		client := newClient(t, config.RESTConfig{
+			Path:       "/0/public/Ticker?pair={symbol}",
+			AuthHeader: "Authorization",
+			AuthPrefix: "Bearer ",
+			Price:      "$.result.{symbol}.p[0]",
+			Volume:     "$.result.{symbol}.v[1]",
+			Symbols: []config.RESTSymbolConfig{
+				{Symbol: "USDC-USD", Remote: "USDCUSD"},
+				{Symbol: "USDT-USD", Remote: "USDTUSD"},
+			},
+		})
+		defer client.Close()
+		require.True(t, client.KeyRequired())
+
+		symbols, err := client.AvailableSymbols()
+		require.NoError(t, err)
+		require.Equal(t, []string{"USDC-USD", "USDT-USD"}, symbols)
+
+		// USDT-USD is not served by the provider, it is skipped.
+		prices, err := client.FetchPrice(symbols)
+		require.NoError(t, err)
+		require.Len(t, requests, 2)
+		require.Len(t, prices, 1)
+		require.Equal(t, common.Price{Symbol: "USDC-USD", Price: "0.9998", Volume: "2000", Venue: "USDCUSD"}, prices[0])
+	})
+
+	t.Run("batch mode with the API key in the query and the inverted rates", func(t *testing.T) {
+		requests = nil
+		client := newClient(t, config.RESTConfig{
+			Path:      "/v2.0/rates/latest?symbols={symbols}",
+			Mode:      config.RESTModeBatch,
+			AuthQuery: "apikey",
+			Price:     "$.rates.{base}",
+			Timestamp: "$.date",
+			Symbols: []config.RESTSymbolConfig{
+				{Symbol: "EUR-USD", Invert: true},
+				{Symbol: "JPY-USD", Invert: true},
+				{Symbol: "GBP-USD", Invert: true},
+			},
+		})
+		defer client.Close()
+
+		prices, err := client.FetchPrice([]string{"EUR-USD", "JPY-USD", "GBP-USD"})
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		require.Equal(t, "EUR-USD,JPY-USD,GBP-USD", requests[0].URL.Query().Get("symbols"))
+		require.Len(t, prices, 2)
+		require.Equal(t, "EUR-USD", prices[0].Symbol)
+		require.Equal(t, "1.25", prices[0].Price)
+		require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Unix(), prices[0].Timestamp)
+		require.Equal(t, "JPY-USD", prices[1].Symbol)
+		require.Equal(t, "0.006666666666666667", prices[1].Price)
+	})
+
+	t.Run("array filter with the timestamp in milliseconds", func(t *testing.T) {
+		client := newClient(t, config.RESTConfig{
+			Path:      "/tickers",
+			Mode:      config.RESTModeBatch,
+			Price:     "$.data[?(@.symbol=='{symbol}')].price",
+			Timestamp: "$.data[?(@.symbol=='{symbol}')].ts",
+			Symbols:   []config.RESTSymbolConfig{{Symbol: "EUR-USD", Remote: "EURUSD"}},
+		})
+		require.False(t, client.KeyRequired())
+
+		prices, err := client.FetchPrice([]string{"EUR-USD"})
+		require.NoError(t, err)
+		require.Len(t, prices, 1)
+		require.Equal(t, "1.08", prices[0].Price)
+		require.Equal(t, int64(1700000000), prices[0].Timestamp)
+	})
+
+	t.Run("failed batch request is an error", func(t *testing.T) {
+		client := newClient(t, config.RESTConfig{
+			Path:    "/unknown?symbols={symbols}",
+			Mode:    config.RESTModeBatch,
+			Price:   "$.rates.{base}",
+			Symbols: []config.RESTSymbolConfig{{Symbol: "EUR-USD"}},
+		})
+		_, err := client.FetchPrice([]string{"EUR-USD"})
+		require.Error(t, err)
+	})
+
+	t.Run("config is resolved on start", func(t *testing.T) {
+		conf := &config.PluginConfig{Name: "rest_json", Endpoint: "localhost"}
+		_, err := newRESTClient(conf, nil, hclog.NewNullLogger())
+		require.ErrorIs(t, err, errNoSymbols)
+
+		conf.REST = config.RESTConfig{Path: "/", Price: "$.rates[{symbol}]", Symbols: []config.RESTSymbolConfig{{Symbol: "EUR-USD"}}}
+		_, err = newRESTClient(conf, nil, hclog.NewNullLogger())
+		require.EqualError(t, err, `symbol EUR-USD: invalid index "EUR-USD" in path "$.rates[EUR-USD]"`)
+
+		conf.Endpoint = ""
+		_, err = newRESTClient(conf, nil, hclog.NewNullLogger())
+		require.ErrorIs(t, err, errNoEndpoint)
+	})
+}
@@ -0,0 +1,191 @@
+package replay
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/journal"
+	oracleserver "autonity-oracle/oracle_server"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/shopspring/decimal"
+	"io"
+	"math/big"
+	"os"
+	"text/tabwriter"
+)
+
+var (
+	// defaultOutlierThreshold is the default outlier detection threshold of the Autonity oracle protocol in percent.
+	defaultOutlierThreshold = 10.0
+
+	ErrNoJournal = errors.New("no round journal to replay, set it with -journal or -config")
+)
+
+// Config is the resolved configuration of a replay.
+type Config struct {
+	Journal   string // the journal directory, or a single journal file.
+	FromRound uint64
+	ToRound   uint64 // 0 replays to the last recorded round.
+	JSON      bool
+	Replay    oracleserver.ReplayConfig
+}
+
+// MakeConfig resolves the replay configuration from the arguments of the `replay` sub command. The confidence
+// settings take the flags first, then the oracle server config layered with the AUTORACLE_* environment variables,
+// otherwise the recorded settings of each round.
+func MakeConfig(args []string) (*Config, error) {
+	var configFile string
+	var strategy int
+	conf := &Config{}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&configFile, "config", "", "The oracle server config file to take the journal and the aggregation settings from, optional.")
+	fs.StringVar(&conf.Journal, "journal", "", "The round journal directory or file, it overrides the profileDir of the config file.")
+	fs.Uint64Var(&conf.FromRound, "from", 0, "The first round to be printed.")
+	fs.Uint64Var(&conf.ToRound, "to", 0, "The last round to be printed, 0 for the last recorded round.")
+	fs.IntVar(&strategy, "confidence-strategy", 0, "The confidence strategy to replay with: 0: linear, 1: fixed, 2: model.")
+	fs.Float64Var(&conf.Replay.SourceOutlierFilter, "source-outlier-filter", 0, "The max deviation in percent of a source from the median of the sources of a symbol, 0 disables the filter.")
+	fs.Float64Var(&conf.Replay.OutlierThreshold, "outlier-threshold", defaultOutlierThreshold, "The outlier detection threshold of the protocol in percent.")
+	fs.BoolVar(&conf.JSON, "json", false, "Print the replayed rounds in JSON lines instead of a table.")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if configFile != "" {
+		serverConf, err := config.LoadLayeredConfig(configFile, nil)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Journal == "" {
+			conf.Journal = serverConf.ProfileDir
+		}
+		conf.Replay.ConfidenceStrategy = &serverConf.ConfidenceStrategy
+		conf.Replay.ConfidenceModel = &serverConf.ConfidenceModel
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "confidence-strategy" {
+			conf.Replay.ConfidenceStrategy = &strategy
+		}
+	})
+
+	if conf.Journal == "" {
+		return nil, ErrNoJournal
+	}
+	return conf, nil
+}
+
+// Summary counts the changed rounds and the outliers of the replay.
+type Summary struct {
+	Rounds           int `json:"rounds"`
+	ChangedRounds    int `json:"changedRounds"`
+	RecordedOutliers int `json:"recordedOutliers"`
+	ReplayedOutliers int `json:"replayedOutliers"`
+}
+
+// Run replays the journal with the configured settings, and prints the replayed rounds with a summary.
+func Run(conf *Config, out io.Writer) error {
+	records, err := readJournal(conf.Journal)
+	if err != nil {
+		return err
+	}
+
+	var summary Summary
+	var printed []oracleserver.ReplayedRound
+	for _, round := range oracleserver.Replay(records, &conf.Replay, hclog.NewNullLogger()) {
+		if round.Round < conf.FromRound || (conf.ToRound != 0 && round.Round > conf.ToRound) {
+			continue
+		}
+		summary.Rounds++
+		if round.Changed {
+			summary.ChangedRounds++
+		}
+		for _, s := range round.Symbols {
+			if s.RecordedOutlier {
+				summary.RecordedOutliers++
+			}
+			if s.ReplayedOutlier {
+				summary.ReplayedOutliers++
+			}
+		}
+		printed = append(printed, round)
+	}
+
+	if conf.JSON {
+		encoder := json.NewEncoder(out)
+		for i := range printed {
+			if err = encoder.Encode(&printed[i]); err != nil {
+				return err
+			}
+		}
+		return encoder.Encode(&summary)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ROUND\tSYMBOL\tMEDIAN\tRECORDED\tDEVIATION\tREPLAYED\tDEVIATION\tCONFIDENCE\tOUTLIER\n")
+	for _, round := range printed {
+		for _, s := range round.Symbols {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d -> %d\t%s\n", round.Round, s.Symbol, formatInt(s.Median),
+				formatInt(s.Recorded), formatDeviation(s.RecordedDeviation), formatInt(s.Replayed),
+				formatDeviation(s.ReplayedDeviation), s.RecordedConfidence, s.ReplayedConfidence,
+				formatOutlier(s.RecordedOutlier, s.ReplayedOutlier))
+		}
+	}
+	fmt.Fprintf(w, "\nROUNDS\t%d\n", summary.Rounds)
+	fmt.Fprintf(w, "CHANGED ROUNDS\t%d\n", summary.ChangedRounds)
+	fmt.Fprintf(w, "OUTLIERS\t%d -> %d\n", summary.RecordedOutliers, summary.ReplayedOutliers)
+	return w.Flush()
+}
+
+func readJournal(path string) ([]*journal.Record, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return journal.Read(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return journal.Decode(file)
+}
+
+func formatInt(v *big.Int) string {
+	if v == nil {
+		return "-"
+	}
+	return v.String()
+}
+
+func formatDeviation(d *decimal.Decimal) string {
+	if d == nil {
+		return "-"
+	}
+	return d.StringFixed(2) + "%"
+}
+
+func formatOutlier(recorded, replayed bool) string {
+	switch {
+	case recorded && replayed:
+		return "yes"
+	case recorded:
+		return "fixed"
+	case replayed:
+		return "new"
+	}
+	return "no"
+}
@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"autonity-oracle/config"
+	"autonity-oracle/journal"
+	"autonity-oracle/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMakeConfig(t *testing.T) {
+	_, err := MakeConfig(nil)
+	require.ErrorIs(t, err, ErrNoJournal)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "oracle_config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte("profileDir: \""+dir+"\"\nconfidenceStrategy: 1\n"), 0600))
+
+	conf, err := MakeConfig([]string{"-config", configFile, "-source-outlier-filter", "5"})
+	require.NoError(t, err)
+	require.Equal(t, dir, conf.Journal)
+	require.Equal(t, 1, *conf.Replay.ConfidenceStrategy)
+	require.Equal(t, 5.0, conf.Replay.SourceOutlierFilter)
+	require.Equal(t, defaultOutlierThreshold, conf.Replay.OutlierThreshold)
+
+	// the environment variables are layered on the config file, while the flags take precedence.
+	t.Setenv(config.EnvPrefix+"_CONFIDENCE_STRATEGY", "2")
+	conf, err = MakeConfig([]string{"-config", configFile})
+	require.NoError(t, err)
+	require.Equal(t, 2, *conf.Replay.ConfidenceStrategy)
+	conf, err = MakeConfig([]string{"-config", configFile, "-confidence-strategy", "0"})
+	require.NoError(t, err)
+	require.Equal(t, 0, *conf.Replay.ConfidenceStrategy)
+
+	// the recorded settings are taken without the config file and the flags.
+	conf, err = MakeConfig([]string{"-journal", dir})
+	require.NoError(t, err)
+	require.Nil(t, conf.Replay.ConfidenceStrategy)
+	require.Zero(t, conf.Replay.SourceOutlierFilter)
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	w, err := journal.NewWriter(dir, 0, 0)
+	require.NoError(t, err)
+	defer w.Close() //nolint
+
+	report := decimal.RequireFromString("1.5").Mul(decimal.New(1, 18)).BigInt()
+	require.NoError(t, w.Append(&journal.Record{Kind: journal.KindVote, Round: 5, Vote: &journal.Vote{
+		SampleTS: 100,
+		Plugins: []journal.PluginSamples{{Plugin: "crypto_uniswap", SourceType: int(types.SrcAMM),
+			Samples: map[string][]journal.Sample{"NTN-USDC": {{TS: 99, Price: decimal.RequireFromString("1.5"), Volume: big.NewInt(10)}}}}},
+		Symbols: []journal.SymbolReport{{Symbol: "NTN-USDC", Report: report, Confidence: 100}},
+	}}))
+	require.NoError(t, w.Append(&journal.Record{Kind: journal.KindOutcome, Round: 6, Outcome: &journal.Outcome{CommitRound: 5,
+		Symbols: []journal.SymbolOutcome{{Symbol: "NTN-USDC", Price: new(big.Int).Mul(report, big.NewInt(2)), Success: true}}}}))
+
+	var out strings.Builder
+	require.NoError(t, Run(&Config{Journal: dir}, &out))
+	require.Contains(t, out.String(), "50.00%")
+	require.Contains(t, out.String(), "CHANGED ROUNDS  0")
+	require.Contains(t, out.String(), "OUTLIERS        1 -> 1")
+
+	out.Reset()
+	require.NoError(t, Run(&Config{Journal: filepath.Join(dir, journal.FileName), FromRound: 6, JSON: true}, &out))
+	require.Equal(t, `{"rounds":0,"changedRounds":0,"recordedOutliers":0,"replayedOutliers":0}`+"\n", out.String())
+}
@@ -76,3 +76,10 @@ type Blockchain interface {
 type SampleEventSubscriber interface {
 	WatchSampleEvent(sink chan<- *SampleEvent) event.Subscription
 }
+
+// PriceSource provides the aggregated price of a symbol from the samples of a data source. It is implemented by the
+// plugin wrappers, and by the recorded samples of the round journal for the offline replay.
+type PriceSource interface {
+	Name() string
+	AggregatedPrice(symbol string, target int64) (Price, error)
+}
@@ -33,6 +33,28 @@ type Price struct {
 	Price      decimal.Decimal
 	Volume     *big.Int // recent trade volume in quoto of USDCx.
 	Confidence uint8    // confidence resolved by the server.
+
+	SourceTimestamp int64            // TS of the quote on the data source side in Unix time, 0 if it is not provided.
+	Venue           string           // the venue or the market identifier of the quote on the data source side.
+	Bid             *decimal.Decimal // the optional best bid of the quote.
+	Ask             *decimal.Decimal // the optional best ask of the quote.
+}
+
+// SourceTime returns the TS of the quote on the data source side, or the sampling TS if the source does not provide it.
+func (p *Price) SourceTime() int64 {
+	if p.SourceTimestamp > 0 {
+		return p.SourceTimestamp
+	}
+	return p.Timestamp
+}
+
+// Provenance is the origin of a sample which is aggregated into the price of a round.
+type Provenance struct {
+	Plugin          string
+	Venue           string
+	SourceTimestamp int64
+	Bid             *decimal.Decimal
+	Ask             *decimal.Decimal
 }
 
 // PriceBySymbol group the price by symbols.
@@ -49,6 +71,12 @@ type RoundData struct {
 	Symbols        []string
 	Reports        []contract.IOracleReport
 	MissingData    bool
+	RevealTx       common.Hash             // the vote tx of the next round which reveals the reports, it could be a partial reveal.
+	Revealed       bool                    // the reveal tx is mined successfully.
+	Provenance     map[string][]Provenance // the origins of the aggregated samples by sampling symbol.
+	// the confidence before the decay of the historic fallback prices by sampling symbol, the later rounds which fall
+	// back to the same price decay it from this confidence again, thus the decay is not compounded over rounds.
+	FallbackConfidence map[string]uint8
 }
 
 // JSONRPCMessage is the JSON spec to carry those data response from the binance data simulator.